@@ -0,0 +1,70 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"sync"
+
+	"github.com/transparency-dev/merkle"
+)
+
+// Hasher is a merkle.LogHasher which memoizes the results of HashChildren in a
+// bounded cache, evicting the least recently used entries. All other methods
+// are passed through to the wrapped hasher. It is safe for concurrent use if
+// the wrapped hasher is.
+//
+// The slices returned by HashChildren are shared between callers, and must not
+// be modified.
+type Hasher struct {
+	merkle.LogHasher
+	mu    sync.Mutex
+	cache *lru
+}
+
+// childrenKey identifies the input of a HashChildren call.
+type childrenKey struct {
+	l, r string
+}
+
+// NewHasher returns a Hasher which wraps the given one, and caches up to size
+// most recently used HashChildren results.
+func NewHasher(h merkle.LogHasher, size int) *Hasher {
+	return &Hasher{LogHasher: h, cache: newLRU(size)}
+}
+
+// HashChildren returns the hash of the two child nodes, computing it with the
+// wrapped hasher only if it is not cached.
+func (h *Hasher) HashChildren(l, r []byte) []byte {
+	key := childrenKey{l: string(l), r: string(r)}
+	h.mu.Lock()
+	hash, ok := h.cache.get(key)
+	h.mu.Unlock()
+	if ok {
+		return hash.([]byte)
+	}
+
+	res := h.LogHasher.HashChildren(l, r)
+	h.mu.Lock()
+	h.cache.put(key, res)
+	h.mu.Unlock()
+	return res
+}
+
+// Len returns the number of cached hashes.
+func (h *Hasher) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.cache.len()
+}
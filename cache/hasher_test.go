@@ -0,0 +1,105 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// countingHasher counts the number of HashChildren calls.
+type countingHasher struct {
+	merkle.LogHasher
+	mu    sync.Mutex
+	calls int
+}
+
+func (h *countingHasher) HashChildren(l, r []byte) []byte {
+	h.mu.Lock()
+	h.calls++
+	h.mu.Unlock()
+	return h.LogHasher.HashChildren(l, r)
+}
+
+func TestHasher(t *testing.T) {
+	base := &countingHasher{LogHasher: rfc6962.DefaultHasher}
+	h := NewHasher(base, 3)
+	leaf := func(i int) []byte {
+		return rfc6962.DefaultHasher.HashLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	check := func(l, r []byte, wantCalls int) {
+		t.Helper()
+		if got, want := h.HashChildren(l, r), rfc6962.DefaultHasher.HashChildren(l, r); !bytes.Equal(got, want) {
+			t.Errorf("HashChildren: got %x, want %x", got, want)
+		}
+		if got := base.calls; got != wantCalls {
+			t.Errorf("HashChildren: got %d calls, want %d", got, wantCalls)
+		}
+	}
+
+	check(leaf(0), leaf(1), 1)
+	check(leaf(0), leaf(1), 1) // Cached.
+	check(leaf(1), leaf(0), 2) // The order of children matters.
+	check(leaf(2), leaf(3), 3)
+	check(leaf(0), leaf(1), 3) // Still cached, and becomes the most recent.
+	check(leaf(4), leaf(5), 4) // Evicts (1, 0).
+	check(leaf(0), leaf(1), 4)
+	check(leaf(1), leaf(0), 5)
+	if got, want := h.Len(), 3; got != want {
+		t.Errorf("Len: got %d, want %d", got, want)
+	}
+
+	// Other methods are passed through.
+	if got, want := h.EmptyRoot(), rfc6962.DefaultHasher.EmptyRoot(); !bytes.Equal(got, want) {
+		t.Errorf("EmptyRoot: got %x, want %x", got, want)
+	}
+	if got, want := h.Size(), rfc6962.DefaultHasher.Size(); got != want {
+		t.Errorf("Size: got %d, want %d", got, want)
+	}
+}
+
+func TestHasherZeroSize(t *testing.T) {
+	h := NewHasher(rfc6962.DefaultHasher, 0)
+	l, r := []byte("left"), []byte("right")
+	if got, want := h.HashChildren(l, r), rfc6962.DefaultHasher.HashChildren(l, r); !bytes.Equal(got, want) {
+		t.Errorf("HashChildren: got %x, want %x", got, want)
+	}
+	if got := h.Len(); got != 0 {
+		t.Errorf("Len: got %d, want 0", got)
+	}
+}
+
+func TestHasherConcurrent(t *testing.T) {
+	h := NewHasher(rfc6962.DefaultHasher, 16)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l, r := []byte{byte(i % 32)}, []byte{byte(g)}
+				if got, want := h.HashChildren(l, r), rfc6962.DefaultHasher.HashChildren(l, r); !bytes.Equal(got, want) {
+					t.Errorf("HashChildren: got %x, want %x", got, want)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cache provides caching helpers for Merkle tree hashing and proofs.
+package cache
+
+import "container/list"
+
+// lru is a bounded map which evicts the least recently used entries. It is not
+// safe for concurrent use.
+type lru struct {
+	size  int
+	order *list.List // Ordered from most to least recently used.
+	items map[interface{}]*list.Element
+}
+
+// entry is an element of the lru order list.
+type entry struct {
+	key   interface{}
+	value interface{}
+}
+
+// newLRU returns an empty lru cache which holds up to size entries.
+func newLRU(size int) *lru {
+	return &lru{size: size, order: list.New(), items: make(map[interface{}]*list.Element)}
+}
+
+// get returns the value for the given key, and marks it as recently used.
+func (c *lru) get(key interface{}) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*entry).value, true
+}
+
+// put adds or updates the value for the given key, and evicts the least
+// recently used entry if the cache is full.
+func (c *lru) put(key, value interface{}) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*entry).value = value
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.size <= 0 {
+		return
+	}
+	if c.order.Len() >= c.size {
+		last := c.order.Back()
+		delete(c.items, last.Value.(*entry).key)
+		c.order.Remove(last)
+	}
+	c.items[key] = c.order.PushFront(&entry{key: key, value: value})
+}
+
+// len returns the number of entries in the cache.
+func (c *lru) len() int {
+	return c.order.Len()
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// GetFn returns the hashes of the given nodes, in the same order, e.g. by
+// reading them from a storage.NodeStore.
+type GetFn func(ids []compact.NodeID) ([][]byte, error)
+
+// Prover builds inclusion proofs from the stored tree nodes, and memoizes the
+// recently built proofs and ephemeral node hashes, evicting the least recently
+// used entries. It is safe for concurrent use if the GetFn is.
+//
+// The proof hashes are shared between callers, and must not be modified.
+type Prover struct {
+	hasher merkle.LogHasher
+	get    GetFn
+	mu     sync.Mutex
+	size   uint64 // The current tree size.
+	cap    int    // The maximal number of cached entries of each kind.
+	proofs *lru   // Proofs keyed by proofKey.
+	ephem  *lru   // Ephemeral node hashes keyed by ephemKey.
+}
+
+// proofKey identifies an inclusion proof.
+type proofKey struct {
+	index, size uint64
+}
+
+// ephemKey identifies an ephemeral node hash. The same ephemeral node has
+// different hashes in trees of different sizes.
+type ephemKey struct {
+	id   compact.NodeID
+	size uint64
+}
+
+// NewProver returns a Prover for the tree of the given size, which reads the
+// nodes with the given function, and caches up to cacheSize proofs and
+// ephemeral node hashes.
+func NewProver(hasher merkle.LogHasher, get GetFn, size uint64, cacheSize int) *Prover {
+	return &Prover{hasher: hasher, get: get, size: size, cap: cacheSize,
+		proofs: newLRU(cacheSize), ephem: newLRU(cacheSize)}
+}
+
+// Grow notifies the Prover that the tree has grown to the given size. All the
+// cached entries are dropped. While they are still valid, the clients are most
+// likely to request proofs for the latest tree size, so the cache is freed up
+// for them. Does nothing if the size is not bigger than the current one.
+func (p *Prover) Grow(size uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if size <= p.size {
+		return
+	}
+	p.size = size
+	p.proofs, p.ephem = newLRU(p.cap), newLRU(p.cap)
+}
+
+// Inclusion returns the inclusion proof for the given leaf index in the tree
+// of the given size. Requires 0 <= index < size <= the current tree size.
+func (p *Prover) Inclusion(index, size uint64) ([][]byte, error) {
+	p.mu.Lock()
+	curSize := p.size
+	cached, ok := p.proofs.get(proofKey{index: index, size: size})
+	p.mu.Unlock()
+	if size > curSize {
+		return nil, fmt.Errorf("size %d exceeds the tree size %d", size, curSize)
+	} else if ok {
+		return append([][]byte(nil), cached.([][]byte)...), nil
+	}
+
+	nodes, err := proof.Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+	var res [][]byte
+	id, begin, end := nodes.Ephem()
+	eKey := ephemKey{id: id, size: size}
+	p.mu.Lock()
+	ephem, ok := p.ephem.get(eKey)
+	p.mu.Unlock()
+	if ok && begin < end {
+		// Fetch all the nodes except the ones under the cached ephemeral node.
+		ids := make([]compact.NodeID, 0, len(nodes.IDs)-(end-begin))
+		ids = append(append(ids, nodes.IDs[:begin]...), nodes.IDs[end:]...)
+		hashes, err := p.get(ids)
+		if err != nil {
+			return nil, err
+		} else if len(hashes) != len(ids) {
+			return nil, fmt.Errorf("got %d hashes, want %d", len(hashes), len(ids))
+		}
+		res = make([][]byte, 0, len(hashes)+1)
+		res = append(append(append(res, hashes[:begin]...), ephem.([]byte)), hashes[begin:]...)
+	} else {
+		hashes, err := p.get(nodes.IDs)
+		if err != nil {
+			return nil, err
+		}
+		if res, err = nodes.Rehash(hashes, p.hasher.HashChildren); err != nil {
+			return nil, err
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.size == curSize { // Don't cache if the tree has grown in the meantime.
+		p.proofs.put(proofKey{index: index, size: size}, res)
+		if begin < end {
+			p.ephem.put(eKey, res[begin])
+		}
+	}
+	return append([][]byte(nil), res...), nil
+}
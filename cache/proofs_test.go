@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/storage"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestProver(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	const size = 21
+	store := storage.NewMemoryStore()
+	tree, err := storage.NewTree(hasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	ref := testonly.New(hasher)
+	for i := 0; i < size; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		if err := tree.AppendData(data); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+		ref.AppendData(data)
+	}
+
+	var fetched int
+	get := func(ids []compact.NodeID) ([][]byte, error) {
+		fetched += len(ids)
+		return store.Get(ids)
+	}
+	p := NewProver(hasher, get, 20, 100)
+	check := func(index, size uint64, wantFetched int) {
+		t.Helper()
+		fetched = 0
+		got, err := p.Inclusion(index, size)
+		if err != nil {
+			t.Fatalf("Inclusion(%d, %d): %v", index, size, err)
+		}
+		want, _ := ref.InclusionProof(index, size)
+		if diff := cmp.Diff(got, want); diff != "" {
+			t.Errorf("Inclusion(%d, %d): diff(-got +want):\n%s", index, size, diff)
+		}
+		if fetched != wantFetched {
+			t.Errorf("Inclusion(%d, %d): fetched %d nodes, want %d", index, size, fetched, wantFetched)
+		}
+	}
+
+	// Tree of size 20 = 16 + 4 is made of perfect subtrees, so the proofs have
+	// no ephemeral nodes.
+	check(0, 20, 5)
+	check(0, 20, 0) // Cached.
+	// Tree of size 19 = 16 + 2 + 1. The ephemeral node 4:1 is computed from 2
+	// nodes, and then reused for the proofs of other leaves.
+	check(1, 19, 6)
+	check(2, 19, 4)
+	check(15, 19, 4)
+	check(16, 19, 3) // No ephemeral node.
+	check(1, 19, 0)
+
+	if _, err := p.Inclusion(0, 21); err == nil {
+		t.Error("Inclusion: want error for size beyond the tree")
+	}
+	// Growing the tree drops the cache.
+	p.Grow(size)
+	check(0, 20, 5)
+	check(0, 21, 6)
+	check(0, 21, 0)
+	p.Grow(size) // No-op.
+	check(0, 21, 0)
+}
+
+func TestProverShortGet(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	const size = 19
+	store := storage.NewMemoryStore()
+	tree, err := storage.NewTree(hasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	if err := tree.AppendData(testonly.GenLeaves(0, 0, size)...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	short := false
+	get := func(ids []compact.NodeID) ([][]byte, error) {
+		hashes, err := store.Get(ids)
+		if short && len(hashes) != 0 {
+			hashes = hashes[:len(hashes)-1]
+		}
+		return hashes, err
+	}
+	p := NewProver(hasher, get, size, 100)
+	// Cache the ephemeral node, so that the next proof reuses it.
+	if _, err := p.Inclusion(1, size); err != nil {
+		t.Fatalf("Inclusion: %v", err)
+	}
+	short = true
+	if _, err := p.Inclusion(2, size); err == nil {
+		t.Fatal("Inclusion: want error for missing hashes")
+	}
+	// The malformed proof is not cached.
+	short = false
+	got, err := p.Inclusion(2, size)
+	if err != nil {
+		t.Fatalf("Inclusion: %v", err)
+	}
+	want, _ := testonly.GenTree(hasher, 0, size).InclusionProof(2, size)
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Inclusion: diff(-got +want):\n%s", diff)
+	}
+}
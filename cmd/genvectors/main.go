@@ -0,0 +1,59 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The genvectors binary emits JSON test vectors for the RFC6962 Merkle tree
+// with SHA256 hashing. The output is deterministic for the given flags.
+//
+// Usage:
+//
+//	go run ./cmd/genvectors --seed=0 --size=16 --out=vectors.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var (
+	seed = flag.Uint64("seed", 0, "Seed for generating the leaf data")
+	size = flag.Uint64("size", 16, "Number of leaves in the tree")
+	out  = flag.String("out", "", "Output file, or stdout if empty")
+)
+
+func main() {
+	flag.Parse()
+	v, err := testonly.GenVectors(rfc6962.DefaultHasher, *seed, *size)
+	if err != nil {
+		log.Fatalf("GenVectors: %v", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalf("MarshalIndent: %v", err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		_, err = os.Stdout.Write(data)
+	} else {
+		err = os.WriteFile(*out, data, 0o644)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write vectors: %v", err)
+	}
+}
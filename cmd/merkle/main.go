@@ -0,0 +1,271 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// The merkle binary computes and verifies root hashes and proofs of RFC6962
+// Merkle trees with SHA256 hashing.
+//
+// Usage:
+//
+//	merkle <command> [flags] [files...]
+//
+// The input is read from the given files in order, or from stdin if there are
+// none. The root, inclusion and consistency commands read leaves, and the verify
+// commands read a proof. Each file is read separately, so a leaf or a proof hash
+// never spans two files, even if a file does not end with a newline. In the
+// "lines" format, the trailing "\r" of each line is stripped, as done by
+// bufio.ScanLines, so it is not a part of the leaf data. Hashes, both in the flags and in the input and output
+// proofs, are hex-encoded. Proofs contain one hash per line. Examples:
+//
+//	merkle root leaves.txt
+//	merkle inclusion --index=5 --size=10 leaves.txt | merkle verify-inclusion \
+//		--index=5 --size=10 --leaf_hash=... --root=...
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+const usage = `Usage: merkle <command> [flags] [files...]
+
+Commands:
+  root                Print the tree size and root hash of the leaves.
+  inclusion           Print the inclusion proof for a leaf.
+  consistency         Print the consistency proof between two tree sizes.
+  verify-inclusion    Verify the inclusion proof read from the input.
+  verify-consistency  Verify the consistency proof read from the input.
+
+Run "merkle <command> --help" for the command flags.
+`
+
+var hasher = rfc6962.DefaultHasher
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:], os.Stdin, os.Stdout); errors.Is(err, flag.ErrHelp) {
+		os.Exit(2)
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "merkle %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// run executes the given command with the given arguments.
+func run(cmd string, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	var exec func(inputs []io.Reader) error
+	switch cmd {
+	case "root":
+		format := formatFlag(fs)
+		size := fs.Int64("size", -1, "Tree size, or the number of leaves if negative")
+		exec = func(inputs []io.Reader) error {
+			t, err := buildTree(inputs, *format)
+			if err != nil {
+				return err
+			}
+			sz := t.size()
+			if *size >= 0 {
+				sz = uint64(*size)
+			}
+			root, err := t.rootAt(sz)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(stdout, "%d %x\n", sz, root)
+			return err
+		}
+	case "inclusion":
+		format := formatFlag(fs)
+		index := fs.Uint64("index", 0, "Leaf index")
+		size := fs.Int64("size", -1, "Tree size, or the number of leaves if negative")
+		exec = func(inputs []io.Reader) error {
+			t, err := buildTree(inputs, *format)
+			if err != nil {
+				return err
+			}
+			sz := t.size()
+			if *size >= 0 {
+				sz = uint64(*size)
+			}
+			p, err := t.inclusion(*index, sz)
+			if err != nil {
+				return err
+			}
+			return writeProof(stdout, p)
+		}
+	case "consistency":
+		format := formatFlag(fs)
+		size1 := fs.Uint64("size1", 0, "Smaller tree size")
+		size2 := fs.Int64("size2", -1, "Bigger tree size, or the number of leaves if negative")
+		exec = func(inputs []io.Reader) error {
+			t, err := buildTree(inputs, *format)
+			if err != nil {
+				return err
+			}
+			sz := t.size()
+			if *size2 >= 0 {
+				sz = uint64(*size2)
+			}
+			p, err := t.consistency(*size1, sz)
+			if err != nil {
+				return err
+			}
+			return writeProof(stdout, p)
+		}
+	case "verify-inclusion":
+		index := fs.Uint64("index", 0, "Leaf index")
+		size := fs.Uint64("size", 0, "Tree size")
+		leafHash := hexFlag(fs, "leaf_hash", "Leaf hash")
+		root := hexFlag(fs, "root", "Root hash of the tree")
+		exec = func(inputs []io.Reader) error {
+			p, err := readProof(inputs)
+			if err != nil {
+				return err
+			}
+			if err := proof.VerifyInclusion(hasher, *index, *size, *leafHash, p, *root); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, "OK")
+			return err
+		}
+	case "verify-consistency":
+		size1 := fs.Uint64("size1", 0, "Smaller tree size")
+		size2 := fs.Uint64("size2", 0, "Bigger tree size")
+		root1 := hexFlag(fs, "root1", "Root hash of the smaller tree")
+		root2 := hexFlag(fs, "root2", "Root hash of the bigger tree")
+		exec = func(inputs []io.Reader) error {
+			p, err := readProof(inputs)
+			if err != nil {
+				return err
+			}
+			if err := proof.VerifyConsistency(hasher, *size1, *size2, p, *root1, *root2); err != nil {
+				return err
+			}
+			_, err = fmt.Fprintln(stdout, "OK")
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown command\n%s", usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	inputs, closeAll, err := openInputs(fs.Args(), stdin)
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	return exec(inputs)
+}
+
+func formatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", "lines", `Leaf format: "lines" for newline-delimited leaves with any trailing "\r" stripped, or "len32" for leaves prefixed with a 4-byte big-endian length`)
+}
+
+// hexFlag defines a flag with a hex-encoded value.
+func hexFlag(fs *flag.FlagSet, name, usage string) *[]byte {
+	var value []byte
+	fs.Func(name, usage+", hex-encoded", func(s string) (err error) {
+		value, err = hex.DecodeString(s)
+		return err
+	})
+	return &value
+}
+
+// openInputs returns the readers of the given files, or stdin if there are
+// none, and the function which closes them.
+func openInputs(files []string, stdin io.Reader) ([]io.Reader, func(), error) {
+	if len(files) == 0 {
+		return []io.Reader{stdin}, func() {}, nil
+	}
+	readers := make([]io.Reader, 0, len(files))
+	closers := make([]io.Closer, 0, len(files))
+	closeAll := func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		readers = append(readers, f)
+		closers = append(closers, f)
+	}
+	return readers, closeAll, nil
+}
+
+// buildTree returns the tree of the leaves read from the inputs in order. The
+// inputs are split into leaves independently.
+func buildTree(inputs []io.Reader, format string) (*tree, error) {
+	split, err := splitFunc(format)
+	if err != nil {
+		return nil, err
+	}
+	t := newTree(hasher)
+	for _, in := range inputs {
+		if _, err := t.b.ReadLeaves(in, split); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func writeProof(w io.Writer, proof [][]byte) error {
+	for _, hash := range proof {
+		if _, err := fmt.Fprintf(w, "%x\n", hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readProof reads a proof with one hex-encoded hash per line from the inputs in
+// order. Empty lines are ignored.
+func readProof(inputs []io.Reader) ([][]byte, error) {
+	var proof [][]byte
+	for _, r := range inputs {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			hash, err := hex.DecodeString(line)
+			if err != nil {
+				return nil, fmt.Errorf("proof line %q: %v", line, err)
+			}
+			proof = append(proof, hash)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return proof, nil
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+const size = 13
+
+func leaves() ([]string, *testonly.Tree) {
+	tree := testonly.New(hasher)
+	data := make([]string, size)
+	for i := range data {
+		data[i] = fmt.Sprintf("leaf %d", i)
+		tree.AppendData([]byte(data[i]))
+	}
+	return data, tree
+}
+
+func runCmd(t *testing.T, cmd, input string, args ...string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := run(cmd, args, strings.NewReader(input), &out)
+	return out.String(), err
+}
+
+func TestRoot(t *testing.T) {
+	data, tree := leaves()
+	lines := strings.Join(data, "\n") + "\n"
+	var len32 bytes.Buffer
+	for _, leaf := range data {
+		var l [4]byte
+		binary.BigEndian.PutUint32(l[:], uint32(len(leaf)))
+		len32.Write(l[:])
+		len32.WriteString(leaf)
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		input string
+		args  []string
+		want  string
+	}{
+		{desc: "lines", input: lines, want: fmt.Sprintf("%d %x\n", size, tree.Hash())},
+		{desc: "len32", input: len32.String(), args: []string{"--format=len32"}, want: fmt.Sprintf("%d %x\n", size, tree.Hash())},
+		{desc: "size", input: lines, args: []string{"--size=5"}, want: fmt.Sprintf("5 %x\n", tree.HashAt(5))},
+		{desc: "empty", input: "", want: fmt.Sprintf("0 %x\n", tree.HashAt(0))},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got, err := runCmd(t, "root", tc.input, tc.args...)
+			if err != nil {
+				t.Fatalf("root: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("root: got %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := runCmd(t, "root", lines, "--size=14"); err == nil {
+		t.Error("root: want error for size beyond leaves")
+	}
+	if _, err := runCmd(t, "root", len32.String()[:len32.Len()-1], "--format=len32"); err == nil {
+		t.Error("root: want error for truncated input")
+	}
+	if _, err := runCmd(t, "root", lines, "--format=xml"); err == nil {
+		t.Error("root: want error for unknown format")
+	}
+	if _, err := runCmd(t, "unknown", lines); err == nil {
+		t.Error("unknown: want error")
+	}
+}
+
+func TestRootFiles(t *testing.T) {
+	data, tree := leaves()
+	dir := t.TempDir()
+	// The first file does not end with a newline, and the second one has CRLF
+	// line endings.
+	files := []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")}
+	contents := []string{strings.Join(data[:5], "\n"), strings.Join(data[5:], "\r\n") + "\r\n"}
+	for i, name := range files {
+		if err := os.WriteFile(name, []byte(contents[i]), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	if err := run("root", files, strings.NewReader(""), &out); err != nil {
+		t.Fatalf("root: %v", err)
+	}
+	if got, want := out.String(), fmt.Sprintf("%d %x\n", size, tree.Hash()); got != want {
+		t.Errorf("root: got %q, want %q", got, want)
+	}
+}
+
+func TestInclusion(t *testing.T) {
+	data, tree := leaves()
+	input := strings.Join(data, "\n")
+	root := fmt.Sprintf("--root=%x", tree.Hash())
+	for index := uint64(0); index < size; index++ {
+		p, err := runCmd(t, "inclusion", input, fmt.Sprintf("--index=%d", index))
+		if err != nil {
+			t.Fatalf("inclusion: %v", err)
+		}
+		want, err := tree.InclusionProof(index, size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		if got, want := p, proofString(want); got != want {
+			t.Errorf("inclusion(%d): got %q, want %q", index, got, want)
+		}
+
+		leafHash := fmt.Sprintf("--leaf_hash=%x", tree.LeafHash(index))
+		args := []string{fmt.Sprintf("--index=%d", index), fmt.Sprintf("--size=%d", size), leafHash, root}
+		if out, err := runCmd(t, "verify-inclusion", p, args...); err != nil || out != "OK\n" {
+			t.Errorf("verify-inclusion(%d): %q, %v", index, out, err)
+		}
+		args[0] = fmt.Sprintf("--index=%d", (index+1)%size)
+		if _, err := runCmd(t, "verify-inclusion", p, args...); err == nil {
+			t.Errorf("verify-inclusion(%d): want error for wrong index", index)
+		}
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	data, tree := leaves()
+	input := strings.Join(data, "\n")
+	for size1 := uint64(0); size1 <= size; size1++ {
+		for size2 := size1; size2 <= size; size2++ {
+			sizes := []string{fmt.Sprintf("--size1=%d", size1), fmt.Sprintf("--size2=%d", size2)}
+			p, err := runCmd(t, "consistency", input, sizes...)
+			if err != nil {
+				t.Fatalf("consistency: %v", err)
+			}
+			want, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			if got, want := p, proofString(want); got != want {
+				t.Errorf("consistency(%d, %d): got %q, want %q", size1, size2, got, want)
+			}
+
+			args := append(sizes, fmt.Sprintf("--root1=%x", tree.HashAt(size1)), fmt.Sprintf("--root2=%x", tree.HashAt(size2)))
+			if out, err := runCmd(t, "verify-consistency", p, args...); err != nil || out != "OK\n" {
+				t.Errorf("verify-consistency(%d, %d): %q, %v", size1, size2, out, err)
+			}
+		}
+	}
+	if _, err := runCmd(t, "verify-consistency", "zz\n", "--size1=1", "--size2=2"); err == nil {
+		t.Error("verify-consistency: want error for malformed proof")
+	}
+}
+
+func proofString(proof [][]byte) string {
+	var b strings.Builder
+	for _, hash := range proof {
+		fmt.Fprintf(&b, "%x\n", hash)
+	}
+	return b.String()
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/stream"
+)
+
+// tree is an in-memory Merkle tree, which stores the hashes of all the perfect
+// nodes.
+type tree struct {
+	hasher merkle.LogHasher
+	rf     *compact.RangeFactory
+	b      *stream.Builder
+	nodes  map[compact.NodeID][]byte
+}
+
+func newTree(hasher merkle.LogHasher) *tree {
+	t := &tree{
+		hasher: hasher,
+		rf:     &compact.RangeFactory{Hash: hasher.HashChildren},
+		nodes:  make(map[compact.NodeID][]byte),
+	}
+	t.b = stream.NewBuilder(hasher, func(id compact.NodeID, hash []byte) {
+		t.nodes[id] = hash
+	})
+	return t
+}
+
+func (t *tree) size() uint64 {
+	return t.b.Size()
+}
+
+// rootAt returns the root hash of the tree of the given size.
+func (t *tree) rootAt(size uint64) ([]byte, error) {
+	if size > t.size() {
+		return nil, fmt.Errorf("size %d exceeds the number of leaves %d", size, t.size())
+	} else if size == 0 {
+		return t.hasher.EmptyRoot(), nil
+	}
+	rng, err := t.rf.NewRange(0, size, t.getNodes(compact.RangeNodes(0, size, nil)))
+	if err != nil {
+		return nil, err
+	}
+	return rng.GetRootHash(nil)
+}
+
+func (t *tree) inclusion(index, size uint64) ([][]byte, error) {
+	if size > t.size() {
+		return nil, fmt.Errorf("size %d exceeds the number of leaves %d", size, t.size())
+	}
+	nodes, err := proof.Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Rehash(t.getNodes(nodes.IDs), t.hasher.HashChildren)
+}
+
+func (t *tree) consistency(size1, size2 uint64) ([][]byte, error) {
+	if size2 > t.size() {
+		return nil, fmt.Errorf("size %d exceeds the number of leaves %d", size2, t.size())
+	}
+	nodes, err := proof.Consistency(size1, size2)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Rehash(t.getNodes(nodes.IDs), t.hasher.HashChildren)
+}
+
+func (t *tree) getNodes(ids []compact.NodeID) [][]byte {
+	hashes := make([][]byte, len(ids))
+	for i, id := range ids {
+		hashes[i] = t.nodes[id]
+	}
+	return hashes
+}
+
+// splitFunc returns the function which splits the input into leaves of the
+// given format.
+func splitFunc(format string) (bufio.SplitFunc, error) {
+	switch format {
+	case "lines":
+		return bufio.ScanLines, nil
+	case "len32":
+		return stream.ScanLen32, nil
+	default:
+		return nil, fmt.Errorf("unknown leaf format %q", format)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compact
+
+import "sync"
+
+// Appender wraps a compact range, and makes it safe for concurrent use. Many
+// goroutines can append entries to it, and take snapshots of its state.
+type Appender struct {
+	mu sync.Mutex
+	r  *Range
+}
+
+// NewAppender returns an Appender which continues growing the given compact
+// range. The range must not be used directly after this call.
+func NewAppender(r *Range) *Appender {
+	return &Appender{r: r}
+}
+
+// Append extends the compact range by appending the passed in hash to it. The
+// visitor function (if non-nil) is called while holding the lock, so it must
+// not call the Appender methods.
+func (a *Appender) Append(hash []byte, visitor VisitFn) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.r.Append(hash, visitor)
+}
+
+// AppendRange extends the compact range by merging in the other compact range
+// from the right. The visitor function (if non-nil) is called while holding
+// the lock, so it must not call the Appender methods.
+func (a *Appender) AppendRange(other *Range, visitor VisitFn) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.r.AppendRange(other, visitor)
+}
+
+// Snapshot returns a copy of the current compact range.
+func (a *Appender) Snapshot() *Range {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.r.Clone()
+}
+
+// Root returns the current end of the range, and the corresponding root hash.
+// Requires the range to start at index 0. The hashing is done outside of the
+// lock, so it does not block the appends.
+func (a *Appender) Root() (uint64, []byte, error) {
+	r := a.Snapshot()
+	hash, err := r.GetRootHash(nil)
+	return r.End(), hash, err
+}
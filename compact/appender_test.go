@@ -0,0 +1,96 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package compact_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+func TestAppenderConcurrent(t *testing.T) {
+	const workers, perWorker = 8, 100
+	const size = workers * perWorker
+	// All leaves are the same, so the resulting tree does not depend on the
+	// order in which the workers append them.
+	leaf := hashLeaf([]byte("leaf"))
+	ref := factory.NewEmptyRange(0)
+	roots := make(map[uint64][]byte, size)
+	for i := uint64(1); i <= size; i++ {
+		if err := ref.Append(leaf, nil); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		root, err := ref.GetRootHash(nil)
+		if err != nil {
+			t.Fatalf("GetRootHash: %v", err)
+		}
+		roots[i] = root
+	}
+
+	a := compact.NewAppender(factory.NewEmptyRange(0))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				if err := a.Append(leaf, nil); err != nil {
+					t.Errorf("Append: %v", err)
+				}
+				// Snapshots are always consistent.
+				size, root, err := a.Root()
+				if err != nil {
+					t.Errorf("Root: %v", err)
+				} else if want := roots[size]; !bytes.Equal(root, want) {
+					t.Errorf("Root at size %d: got %x, want %x", size, root, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := a.Snapshot()
+	if !r.Equal(ref) {
+		t.Errorf("Snapshot: got [%d, %d) %x, want [%d, %d) %x", r.Begin(), r.End(), r.Hashes(), ref.Begin(), ref.End(), ref.Hashes())
+	}
+	// The snapshot is independent from the appender.
+	if err := r.Append(leaf, nil); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if size, _, _ := a.Root(); size != ref.End() {
+		t.Errorf("Root: got size %d, want %d", size, ref.End())
+	}
+}
+
+func TestAppenderAppendRange(t *testing.T) {
+	const size = uint64(30)
+	tree, visit := newTree(t, size)
+	a := compact.NewAppender(factory.NewEmptyRange(0))
+	for begin := uint64(0); begin < size; begin += 7 {
+		end := begin + 7
+		if end > size {
+			end = size
+		}
+		if err := a.AppendRange(tree.newRange(t, begin, end), visit); err != nil {
+			t.Fatalf("AppendRange: %v", err)
+		}
+	}
+	tree.verifyRange(t, a.Snapshot(), true)
+	if err := a.AppendRange(factory.NewEmptyRange(size+1), nil); err == nil {
+		t.Error("AppendRange: accepted a disjoint range")
+	}
+}
@@ -14,7 +14,13 @@
 
 package compact
 
-import "math/bits"
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 // NodeID identifies a node of a Merkle tree.
 //
@@ -41,6 +47,93 @@ func NewNodeID(level uint, index uint64) NodeID {
 	return NodeID{Level: level, Index: index}
 }
 
+// String returns the canonical textual form of the node ID, which is the level
+// and the index in decimal, separated by a colon, e.g. "3:17". It is the
+// inverse of ParseNodeID. Note that the %v and %+v verbs of the fmt package
+// print the same form.
+func (id NodeID) String() string {
+	return strconv.FormatUint(uint64(id.Level), 10) + ":" + strconv.FormatUint(id.Index, 10)
+}
+
+// ParseNodeID parses the node ID from the form returned by NodeID.String. The
+// level must be below 64. Only the canonical form is accepted, e.g. "03:017"
+// is rejected, so that each node ID has exactly one textual form.
+func ParseNodeID(s string) (NodeID, error) {
+	pos := strings.IndexByte(s, ':')
+	if pos < 0 {
+		return NodeID{}, fmt.Errorf("node ID %q: missing colon", s)
+	}
+	level, err := parseDecimal(s[:pos], 6)
+	if err != nil {
+		return NodeID{}, fmt.Errorf("node ID %q: bad level: %v", s, err)
+	}
+	index, err := parseDecimal(s[pos+1:], 64)
+	if err != nil {
+		return NodeID{}, fmt.Errorf("node ID %q: bad index: %v", s, err)
+	}
+	return NewNodeID(uint(level), index), nil
+}
+
+// parseDecimal parses an unsigned decimal number of the given bit size, and
+// rejects leading zeros.
+func parseDecimal(s string, bitSize int) (uint64, error) {
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("leading zero in %q", s)
+	}
+	return strconv.ParseUint(s, 10, bitSize)
+}
+
+// Compare returns -1, 0, or +1 depending on whether the node ID is less than,
+// equal to, or greater than the other one. The IDs are ordered by level, and
+// then by index.
+func (id NodeID) Compare(other NodeID) int {
+	if id.Level != other.Level {
+		return cmpUint(uint64(id.Level), uint64(other.Level))
+	}
+	return cmpUint(id.Index, other.Index)
+}
+
+// CompareInTiles is like Compare, but orders the IDs by the tiles of the given
+// height that contain them, so that the nodes of each tile are contiguous. The
+// tiles are ordered by the level and then the index of their roots, and the
+// nodes within a tile are ordered like in Compare. A tile of height h is a
+// perfect subtree of height h with the root at a level which is a multiple of
+// h, and it contains all the subtree nodes except the root. Requires height > 0.
+func (id NodeID) CompareInTiles(other NodeID, height uint) int {
+	if c := id.TileRoot(height).Compare(other.TileRoot(height)); c != 0 {
+		return c
+	}
+	return id.Compare(other)
+}
+
+// SortNodeIDs sorts the node IDs in the order defined by NodeID.Compare.
+func SortNodeIDs(ids []NodeID) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+}
+
+// SortNodeIDsInTiles sorts the node IDs in the order defined by
+// NodeID.CompareInTiles with the given tile height. Requires height > 0.
+func SortNodeIDsInTiles(ids []NodeID, height uint) {
+	sort.Slice(ids, func(i, j int) bool { return ids[i].CompareInTiles(ids[j], height) < 0 })
+}
+
+// TileRoot returns the ID of the root node of the tile of the given height
+// which contains this node. See CompareInTiles for the definition of a tile.
+// Requires height > 0.
+func (id NodeID) TileRoot(height uint) NodeID {
+	level := (id.Level/height + 1) * height
+	return NewNodeID(level, id.Index>>(level-id.Level))
+}
+
+func cmpUint(a, b uint64) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
 // Parent returns the ID of the parent node.
 func (id NodeID) Parent() NodeID {
 	return NewNodeID(id.Level+1, id.Index>>1)
@@ -52,13 +145,20 @@ func (id NodeID) Sibling() NodeID {
 }
 
 // Coverage returns the [begin, end) range of leaves covered by the node.
+//
+// Note that end wraps around to 0 for nodes whose coverage ends at 2^64, such
+// as the ephemeral nodes of a tree of size 2^64-1. Such nodes never occur in a
+// compact range, but code dealing with ephemeral nodes of huge trees should
+// not rely on the end value.
 func (id NodeID) Coverage() (uint64, uint64) {
 	return id.Index << id.Level, (id.Index + 1) << id.Level
 }
 
 // RangeNodes appends the IDs of the nodes that comprise the [begin, end)
 // compact range to the given slice, and returns the new slice. The caller may
-// pre-allocate space with the help of the RangeSize function.
+// pre-allocate space with the help of the RangeSize function. If the slice has
+// enough capacity, no allocations are made, so the caller can reuse the same
+// buffer across calls by passing in ids[:0].
 func RangeNodes(begin, end uint64, ids []NodeID) []NodeID {
 	left, right := Decompose(begin, end)
 
@@ -82,8 +182,104 @@ func RangeNodes(begin, end uint64, ids []NodeID) []NodeID {
 	return ids
 }
 
+// VisitRangeNodes calls the visit function for the IDs of the nodes that
+// comprise the [begin, end) compact range, ordered from left to right. Unlike
+// RangeNodes, it does not allocate memory for the list of IDs.
+func VisitRangeNodes(begin, end uint64, visit func(id NodeID)) {
+	left, right := Decompose(begin, end)
+
+	pos := begin
+	// See RangeNodes for the details of the iteration order.
+	for bit := uint64(0); left != 0; pos, left = pos+bit, left^bit {
+		level := uint(bits.TrailingZeros64(left))
+		bit = uint64(1) << level
+		visit(NewNodeID(level, pos>>level))
+	}
+	for bit := uint64(0); right != 0; pos, right = pos+bit, right^bit {
+		level := uint(bits.Len64(right)) - 1
+		bit = uint64(1) << level
+		visit(NewNodeID(level, pos>>level))
+	}
+}
+
 // RangeSize returns the number of nodes in the [begin, end) compact range.
 func RangeSize(begin, end uint64) int {
 	left, right := Decompose(begin, end)
 	return bits.OnesCount64(left) + bits.OnesCount64(right)
 }
+
+// AppendedNodes appends to the given slice the IDs of the perfect subtree
+// roots that are created when the tree grows from size1 to size2 leaves, and
+// returns the new slice. These are exactly the nodes reported by Range.Append
+// when the leaves [size1, size2) are appended to the range. The IDs are
+// ordered by level, and then by index.
+//
+// Together with EphemNodes(size2), this gives all the nodes whose hashes are
+// new or updated after the tree growth.
+//
+// The output is not specified if size1 > size2, but the function never panics.
+func AppendedNodes(size1, size2 uint64, ids []NodeID) []NodeID {
+	for level := uint(0); level < 64 && size2>>level != 0; level++ {
+		for index, end := size1>>level, size2>>level; index < end; index++ {
+			ids = append(ids, NewNodeID(level, index))
+		}
+	}
+	return ids
+}
+
+// EphemNodes appends to the given slice the IDs of the ephemeral nodes of the
+// tree of the given size, i.e. the roots of imperfect subtrees along its right
+// border, and returns the new slice. The IDs are ordered from lower to upper
+// levels, and match the nodes reported by Range.GetRootHash.
+//
+// Note that an ephemeral node with only one perfect child is not included,
+// because its hash is the same as the child's.
+func EphemNodes(size uint64, ids []NodeID) []NodeID {
+	if size == 0 {
+		return ids
+	}
+	// Skip the lowest perfect subtree, and report the parents of all the others.
+	for size &= size - 1; size != 0; size &= size - 1 {
+		level := uint(bits.TrailingZeros64(size)) + 1
+		ids = append(ids, NewNodeID(level, size>>level))
+	}
+	return ids
+}
+
+// Prunable returns the number of nodes at the given level that can be safely
+// discarded from storage if the tree is never queried for proofs below the
+// given boundary tree size, i.e. inclusion proofs for leaves with indices less
+// than boundary, and consistency proofs from tree sizes less than boundary.
+// Level 0 corresponds to leaf hashes.
+//
+// The prunable nodes at each level form a prefix [0, n) of indices. These are
+// exactly the nodes strictly below the perfect subtrees that comprise the
+// [0, boundary) compact range. All the remaining proofs can be built from the
+// roots of these subtrees instead, so they must be retained.
+func Prunable(boundary uint64, level uint) uint64 {
+	if level >= 63 {
+		return 0
+	}
+	return boundary >> (level + 1) << 1
+}
+
+// PrunableNodes appends to the given slice the IDs of the nodes that become
+// prunable when the boundary tree size moves from boundary1 to boundary2, and
+// returns the new slice. See Prunable for the definition. The IDs are ordered
+// by level, and then by index. Use boundary1 = 0 to enumerate all the nodes
+// prunable at boundary2.
+//
+// The output is not specified if boundary1 > boundary2, but the function never
+// panics.
+func PrunableNodes(boundary1, boundary2 uint64, ids []NodeID) []NodeID {
+	for level := uint(0); level < 63; level++ {
+		end := Prunable(boundary2, level)
+		if end == 0 {
+			break
+		}
+		for index := Prunable(boundary1, level); index < end; index++ {
+			ids = append(ids, NewNodeID(level, index))
+		}
+	}
+	return ids
+}
@@ -16,9 +16,11 @@ package compact
 
 import (
 	"fmt"
+	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestRangeNodesAndSize(t *testing.T) {
@@ -91,6 +93,19 @@ func TestRangeNodesAppend(t *testing.T) {
 	}
 }
 
+func TestRangeNodesReuseBuffer(t *testing.T) {
+	ids := make([]NodeID, 0, 128)
+	allocs := testing.AllocsPerRun(100, func() {
+		ids = RangeNodes(1, 0xFFFFFFFFFFFF, ids[:0])
+	})
+	if allocs != 0 {
+		t.Errorf("RangeNodes: got %v allocs, want 0", allocs)
+	}
+	if got, want := len(ids), RangeSize(1, 0xFFFFFFFFFFFF); got != want {
+		t.Errorf("RangeNodes: got %d IDs, want %d", got, want)
+	}
+}
+
 func TestGenRangeNodes(t *testing.T) {
 	const size = uint64(512)
 	for begin := uint64(0); begin <= size; begin++ {
@@ -118,3 +133,192 @@ func refRangeNodes(root NodeID, begin, end uint64) []NodeID {
 		refRangeNodes(NewNodeID(root.Level-1, root.Index*2), begin, end),
 		refRangeNodes(NewNodeID(root.Level-1, root.Index*2+1), begin, end)...)
 }
+
+func TestVisitRangeNodes(t *testing.T) {
+	for begin := uint64(0); begin <= 100; begin++ {
+		for end := begin; end <= 100; end++ {
+			var got []NodeID
+			VisitRangeNodes(begin, end, func(id NodeID) {
+				got = append(got, id)
+			})
+			want := refRangeNodes(NewNodeID(63, 0), begin, end)
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Fatalf("VisitRangeNodes(%d, %d): diff(-want +got):\n%s", begin, end, diff)
+			}
+		}
+	}
+}
+
+func TestVisitRangeNodesAllocs(t *testing.T) {
+	var count int
+	allocs := testing.AllocsPerRun(100, func() {
+		VisitRangeNodes(123, 456789, func(id NodeID) {
+			count++
+		})
+	})
+	if allocs != 0 {
+		t.Errorf("VisitRangeNodes: got %v allocs, want 0", allocs)
+	}
+}
+
+func BenchmarkRangeNodes(b *testing.B) {
+	ids := make([]NodeID, 0, 128)
+	for i := 0; i < b.N; i++ {
+		ids = RangeNodes(123, 0xFFFFFFFF, ids[:0])
+	}
+}
+
+func TestAppendedAndEphemNodes(t *testing.T) {
+	less := func(a, b NodeID) bool {
+		return a.Level < b.Level || a.Level == b.Level && a.Index < b.Index
+	}
+	const maxSize = uint64(40)
+	for size1 := uint64(0); size1 <= maxSize; size1++ {
+		for size2 := size1; size2 <= maxSize; size2++ {
+			rng := factory.NewEmptyRange(0)
+			var want []NodeID
+			visit := func(id NodeID, _ []byte) { want = append(want, id) }
+			for i := uint64(0); i < size2; i++ {
+				if i == size1 {
+					want = nil // Start recording the nodes added after size1.
+				}
+				if err := rng.Append(nil, visit); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+			if size1 == size2 {
+				want = nil
+			}
+			got := AppendedNodes(size1, size2, nil)
+			if diff := cmp.Diff(want, got, cmpopts.SortSlices(less), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("AppendedNodes(%d, %d): diff(-want +got):\n%s", size1, size2, diff)
+			}
+			if !sort.SliceIsSorted(got, func(i, j int) bool { return less(got[i], got[j]) }) {
+				t.Errorf("AppendedNodes(%d, %d): not sorted: %v", size1, size2, got)
+			}
+
+			want = nil
+			if _, err := rng.GetRootHash(visit); err != nil {
+				t.Fatalf("GetRootHash: %v", err)
+			}
+			if diff := cmp.Diff(want, EphemNodes(size2, nil), cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("EphemNodes(%d): diff(-want +got):\n%s", size2, diff)
+			}
+		}
+	}
+}
+
+func TestPrunable(t *testing.T) {
+	for boundary := uint64(0); boundary <= 300; boundary++ {
+		retained := RangeNodes(0, boundary, nil)
+		for level := uint(0); level < 12; level++ {
+			// Count the nodes strictly below the retained compact range nodes.
+			var want uint64
+			for _, id := range retained {
+				if id.Level > level {
+					want += uint64(1) << (id.Level - level)
+				}
+			}
+			if got := Prunable(boundary, level); got != want {
+				t.Errorf("Prunable(%d, %d): got %d, want %d", boundary, level, got, want)
+			}
+		}
+	}
+	if got := Prunable(^uint64(0), 63); got != 0 {
+		t.Errorf("Prunable(max, 63): got %d, want 0", got)
+	}
+}
+
+func TestPrunableNodes(t *testing.T) {
+	for boundary2 := uint64(0); boundary2 <= 100; boundary2++ {
+		for boundary1 := uint64(0); boundary1 <= boundary2; boundary1++ {
+			var want []NodeID
+			for level := uint(0); level < 8; level++ {
+				for index := Prunable(boundary1, level); index < Prunable(boundary2, level); index++ {
+					want = append(want, NewNodeID(level, index))
+				}
+			}
+			if diff := cmp.Diff(PrunableNodes(boundary1, boundary2, nil), want); diff != "" {
+				t.Errorf("PrunableNodes(%d, %d): diff(-got +want):\n%s", boundary1, boundary2, diff)
+			}
+		}
+	}
+}
+
+func TestNodeIDString(t *testing.T) {
+	for _, id := range []NodeID{
+		NewNodeID(0, 0),
+		NewNodeID(3, 17),
+		NewNodeID(0, ^uint64(0)),
+		NewNodeID(63, 1),
+	} {
+		s := id.String()
+		got, err := ParseNodeID(s)
+		if err != nil {
+			t.Errorf("ParseNodeID(%q): %v", s, err)
+		} else if got != id {
+			t.Errorf("ParseNodeID(%q): got %+v, want %+v", s, got, id)
+		}
+	}
+	if got, want := NewNodeID(3, 17).String(), "3:17"; got != want {
+		t.Errorf("String: got %q, want %q", got, want)
+	}
+	// The fmt verbs use the same form, e.g. in error messages.
+	if got, want := fmt.Sprintf("%v %+v", NewNodeID(3, 17), NewNodeID(0, 5)), "3:17 0:5"; got != want {
+		t.Errorf("Sprintf: got %q, want %q", got, want)
+	}
+	for _, s := range []string{"", "3", "3:", ":17", "3:17:1", "64:0", "-1:0", "0:-1", "0:18446744073709551616", " 3:17", "0x3:17", "+3:17", "03:17", "3:017", "03:017", "00:0", "0:00"} {
+		if _, err := ParseNodeID(s); err == nil {
+			t.Errorf("ParseNodeID(%q): want error", s)
+		}
+	}
+}
+
+func TestTileRoot(t *testing.T) {
+	for _, tc := range []struct {
+		id     NodeID
+		height uint
+		want   NodeID
+	}{
+		{id: NewNodeID(0, 0), height: 1, want: NewNodeID(1, 0)},
+		{id: NewNodeID(0, 5), height: 2, want: NewNodeID(2, 1)},
+		{id: NewNodeID(1, 3), height: 2, want: NewNodeID(2, 1)},
+		{id: NewNodeID(2, 1), height: 2, want: NewNodeID(4, 0)},
+		{id: NewNodeID(3, 7), height: 8, want: NewNodeID(8, 0)},
+		{id: NewNodeID(8, 300), height: 8, want: NewNodeID(16, 1)},
+	} {
+		if got := tc.id.TileRoot(tc.height); got != tc.want {
+			t.Errorf("TileRoot(%v, %d): got %v, want %v", tc.id, tc.height, got, tc.want)
+		}
+	}
+}
+
+func TestSortNodeIDs(t *testing.T) {
+	ids := []NodeID{
+		NewNodeID(2, 0), NewNodeID(0, 5), NewNodeID(1, 3), NewNodeID(0, 1),
+		NewNodeID(1, 0), NewNodeID(3, 0), NewNodeID(0, 4), NewNodeID(1, 2),
+	}
+	byLevel := append([]NodeID(nil), ids...)
+	SortNodeIDs(byLevel)
+	if diff := cmp.Diff(byLevel, []NodeID{
+		NewNodeID(0, 1), NewNodeID(0, 4), NewNodeID(0, 5), NewNodeID(1, 0),
+		NewNodeID(1, 2), NewNodeID(1, 3), NewNodeID(2, 0), NewNodeID(3, 0),
+	}); diff != "" {
+		t.Errorf("SortNodeIDs: diff(-got +want):\n%s", diff)
+	}
+
+	// With tiles of height 2, the tile rooted at 2:0 contains 0:0-0:3 and
+	// 1:0-1:1, the tile rooted at 2:1 contains 0:4-0:7 and 1:2-1:3, and the
+	// tile rooted at 4:0 contains 2:0-2:3 and 3:0-3:1.
+	inTiles := append([]NodeID(nil), ids...)
+	SortNodeIDsInTiles(inTiles, 2)
+	if diff := cmp.Diff(inTiles, []NodeID{
+		NewNodeID(0, 1), NewNodeID(1, 0), NewNodeID(0, 4), NewNodeID(0, 5),
+		NewNodeID(1, 2), NewNodeID(1, 3), NewNodeID(2, 0), NewNodeID(3, 0),
+	}); diff != "" {
+		t.Errorf("SortNodeIDsInTiles: diff(-got +want):\n%s", diff)
+	}
+	if got := NewNodeID(1, 1).CompareInTiles(NewNodeID(1, 1), 2); got != 0 {
+		t.Errorf("CompareInTiles: got %d, want 0", got)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"math"
 	"math/bits"
 )
 
@@ -28,6 +29,10 @@ type HashFn func(left, right []byte) []byte
 // VisitFn visits the node with the specified ID and hash.
 type VisitFn func(id NodeID, hash []byte)
 
+// FetchFn returns the hash of the node with the specified ID, e.g. by reading
+// it from storage.
+type FetchFn func(id NodeID) ([]byte, error)
+
 // RangeFactory allows creating compact ranges with the specified hash
 // function, which must not be nil, and must not be changed.
 type RangeFactory struct {
@@ -47,6 +52,50 @@ func (f *RangeFactory) NewRange(begin, end uint64, hashes [][]byte) (*Range, err
 	return &Range{f: f, begin: begin, end: end, hashes: hashes}, nil
 }
 
+// NewRangeFromNodes creates a Range from the given list of node IDs and the
+// corresponding hashes, e.g. received from an untrusted peer. It checks that
+// the IDs are exactly the nodes of a compact range for some [begin, end) range
+// of leaves, ordered left to right, and recovers begin and end. The hashes
+// must be non-empty and of the same size.
+//
+// The list of nodes must not be empty, because an empty compact range does not
+// define its begin index. Use NewEmptyRange for empty ranges instead.
+func (f *RangeFactory) NewRangeFromNodes(ids []NodeID, hashes [][]byte) (*Range, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("no nodes")
+	}
+	if got, want := len(hashes), len(ids); got != want {
+		return nil, fmt.Errorf("invalid hashes: got %d values, want %d", got, want)
+	}
+	for i, hash := range hashes {
+		if len(hash) == 0 {
+			return nil, fmt.Errorf("invalid hashes: hash %d is empty", i)
+		} else if got, want := len(hash), len(hashes[0]); got != want {
+			return nil, fmt.Errorf("invalid hashes: hash %d has size %d, want %d", i, got, want)
+		}
+	}
+
+	for _, id := range ids {
+		if b, e := id.Coverage(); id.Level >= 64 || e <= b {
+			return nil, fmt.Errorf("invalid node %+v", id)
+		}
+	}
+	begin, _ := ids[0].Coverage()
+	_, end := ids[len(ids)-1].Coverage()
+	if end < begin {
+		return nil, fmt.Errorf("invalid nodes: end=%d, want >= %d", end, begin)
+	}
+	if got, want := len(ids), RangeSize(begin, end); got != want {
+		return nil, fmt.Errorf("invalid nodes: got %d, want %d for range [%d, %d)", got, want, begin, end)
+	}
+	for i, id := range RangeNodes(begin, end, make([]NodeID, 0, len(ids))) {
+		if ids[i] != id {
+			return nil, fmt.Errorf("invalid nodes: got %+v at position %d, want %+v", ids[i], i, id)
+		}
+	}
+	return &Range{f: f, begin: begin, end: end, hashes: hashes}, nil
+}
+
 // NewEmptyRange returns a new Range for an empty [begin, begin) range. The
 // value of begin defines where the range will start growing from when entries
 // are appended to it.
@@ -54,6 +103,21 @@ func (f *RangeFactory) NewEmptyRange(begin uint64) *Range {
 	return &Range{f: f, begin: begin, end: begin}
 }
 
+// LoadRange returns the Range for [begin, end) with the hashes of its nodes
+// read by the fetch function, in left to right order. For example, with begin
+// set to 0, this restores the state needed to resume appending to a tree of
+// size end from the stored nodes, without reading the leaves.
+func (f *RangeFactory) LoadRange(begin, end uint64, fetch FetchFn) (*Range, error) {
+	if end < begin {
+		return nil, fmt.Errorf("invalid range: end=%d, want >= %d", end, begin)
+	}
+	hashes, err := f.NewEmptyRange(begin).subRange(begin, end, fetch)
+	if err != nil {
+		return nil, err
+	}
+	return f.NewRange(begin, end, hashes)
+}
+
 // Range represents a compact Merkle tree range for leaf indices [begin, end).
 //
 // It contains the minimal set of perfect subtrees whose leaves comprise this
@@ -85,9 +149,23 @@ func (r *Range) Hashes() [][]byte {
 	return r.hashes
 }
 
+// Clone returns a copy of the compact range, which can be modified
+// independently from the original. The hashes themselves are shared.
+func (r *Range) Clone() *Range {
+	c := *r
+	c.hashes = append([][]byte(nil), r.hashes...)
+	return &c
+}
+
 // Append extends the compact range by appending the passed in hash to it. It
 // reports all the added nodes through the visitor function (if non-nil).
+//
+// Returns an error if the range already ends at index 2^64-1, which is the
+// maximal supported tree size.
 func (r *Range) Append(hash []byte, visitor VisitFn) error {
+	if r.end == math.MaxUint64 {
+		return fmt.Errorf("range end %d: tree size overflow", r.end)
+	}
 	if visitor != nil {
 		visitor(NewNodeID(0, r.end), hash)
 	}
@@ -140,6 +218,159 @@ func (r *Range) GetRootHash(visitor VisitFn) ([]byte, error) {
 	return hash, nil
 }
 
+// SubtreeRoot returns the ID and the hash of the root node of the subtree
+// which covers the [begin, begin+len(hashes)) range of leaves with the given
+// hashes. The subtree may be imperfect, i.e. have ephemeral nodes along its
+// right border, in which case the hash is computed in the same way as the root
+// hash of a tree that ends at the last leaf.
+//
+// Requires that hashes is not empty, and begin is a multiple of the smallest
+// power of two not less than len(hashes), i.e. the range of leaves fits under a
+// single node.
+func SubtreeRoot(hash HashFn, begin uint64, hashes [][]byte) (NodeID, []byte, error) {
+	size := uint64(len(hashes))
+	if size == 0 {
+		return NodeID{}, nil, errors.New("no leaf hashes")
+	}
+	level := uint(bits.Len64(size - 1))
+	if mask := uint64(1)<<level - 1; begin&mask != 0 {
+		return NodeID{}, nil, fmt.Errorf("begin=%d is not a multiple of %d", begin, mask+1)
+	}
+
+	r := (&RangeFactory{Hash: hash}).NewEmptyRange(begin)
+	for _, h := range hashes {
+		if err := r.Append(h, nil); err != nil {
+			return NodeID{}, nil, err
+		}
+	}
+	// The range is aligned, so its nodes are ordered from upper to lower levels.
+	// Merge them right to left, like GetRootHash does.
+	root := r.hashes[len(r.hashes)-1]
+	for i := len(r.hashes) - 2; i >= 0; i-- {
+		root = hash(r.hashes[i], root)
+	}
+	return NewNodeID(level, begin>>level), root, nil
+}
+
+// GetRootHashAt returns the root hash of the Merkle tree of the given size,
+// i.e. of the [0, size) prefix of this compact range. Requires the range to
+// start at index 0, and 0 <= size <= end. If size is 0, returns nil.
+//
+// Like in Truncate, the hashes of nodes which are needed for the prefix, but
+// are not in this compact range, are requested from the fetch function in left
+// to right order. The range is not modified.
+func (r *Range) GetRootHashAt(size uint64, fetch FetchFn) ([]byte, error) {
+	if r.begin != 0 {
+		return nil, fmt.Errorf("begin=%d, want 0", r.begin)
+	} else if size > r.end {
+		return nil, fmt.Errorf("invalid size=%d, want in [0, %d]", size, r.end)
+	}
+	hashes, err := r.subRange(0, size, fetch)
+	if err != nil {
+		return nil, err
+	}
+	prefix := Range{f: r.f, end: size, hashes: hashes}
+	return prefix.GetRootHash(nil)
+}
+
+// Frontier returns the IDs and hashes of the nodes of the compact range
+// [begin, end), where begin is the beginning of this range, and begin <= end
+// <= r.End(). If the range starts at index 0, this is the frontier which
+// allows resuming appends at tree size end, e.g. after a restart. The returned
+// nodes can be passed in to RangeFactory.NewRangeFromNodes for restoring the
+// range.
+//
+// Like in Truncate, the hashes of nodes which are not in this compact range
+// are requested from the fetch function in left to right order. The range is
+// not modified.
+func (r *Range) Frontier(end uint64, fetch FetchFn) ([]NodeID, [][]byte, error) {
+	if end < r.begin || end > r.end {
+		return nil, nil, fmt.Errorf("invalid end=%d, want in [%d, %d]", end, r.begin, r.end)
+	}
+	hashes, err := r.subRange(r.begin, end, fetch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return RangeNodes(r.begin, end, nil), hashes, nil
+}
+
+// Truncate shrinks the compact range to [begin, end), i.e. rolls back all the
+// entries appended after the given end index. Requires begin <= end <= r.End().
+//
+// The nodes that are in both the old and the new compact ranges reuse their
+// hashes. The other nodes of the new range are strictly below the old range's
+// nodes, so their hashes can not be recomputed. They are requested from the
+// fetch function, in left to right order. If fetch is nil, and any such nodes
+// are needed, Truncate returns an error. The range is not modified if Truncate
+// returns an error.
+func (r *Range) Truncate(end uint64, fetch FetchFn) error {
+	if end < r.begin || end > r.end {
+		return fmt.Errorf("invalid end=%d, want in [%d, %d]", end, r.begin, r.end)
+	}
+	hashes, err := r.subRange(r.begin, end, fetch)
+	if err != nil {
+		return err
+	}
+	r.hashes, r.end = hashes, end
+	return nil
+}
+
+// Split splits the compact range into two compact ranges [begin, mid) and
+// [mid, end), which can be merged back with AppendRange. Requires that
+// begin <= mid <= end. The original range is not modified.
+//
+// Like in Truncate, the hashes of nodes that are not in the original compact
+// range are requested from the fetch function, in left to right order.
+func (r *Range) Split(mid uint64, fetch FetchFn) (*Range, *Range, error) {
+	if mid < r.begin || mid > r.end {
+		return nil, nil, fmt.Errorf("invalid mid=%d, want in [%d, %d]", mid, r.begin, r.end)
+	}
+	left, err := r.subRange(r.begin, mid, fetch)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := r.subRange(mid, r.end, fetch)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Range{f: r.f, begin: r.begin, end: mid, hashes: left},
+		&Range{f: r.f, begin: mid, end: r.end, hashes: right}, nil
+}
+
+// subRange returns the hashes of the [begin, end) compact range, which must be
+// within this range. The hashes of nodes that are not in this range are
+// requested from the fetch function.
+func (r *Range) subRange(begin, end uint64, fetch FetchFn) ([][]byte, error) {
+	ids := RangeNodes(r.begin, r.end, nil)
+	if got, want := len(r.hashes), len(ids); got != want {
+		return nil, fmt.Errorf("corrupted range: got %d hashes, want %d", got, want)
+	}
+	var hashes [][]byte
+	idx := 0
+	for _, id := range RangeNodes(begin, end, nil) {
+		// Both lists are ordered left to right, and each new node is under one of
+		// the old nodes. Find this node.
+		for b, _ := id.Coverage(); idx < len(ids); idx++ {
+			if _, e := ids[idx].Coverage(); e > b {
+				break
+			}
+		}
+		if idx < len(ids) && ids[idx] == id {
+			hashes = append(hashes, r.hashes[idx])
+			continue
+		}
+		if fetch == nil {
+			return nil, fmt.Errorf("hash of node %+v is needed", id)
+		}
+		hash, err := fetch(id)
+		if err != nil {
+			return nil, fmt.Errorf("fetch %+v: %v", id, err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
+
 // Equal compares two Ranges for equality.
 func (r *Range) Equal(other *Range) bool {
 	if r.f != other.f || r.begin != other.begin || r.end != other.end {
@@ -156,6 +387,58 @@ func (r *Range) Equal(other *Range) bool {
 	return true
 }
 
+// Contains reports whether the other range covers a subset of the leaves
+// covered by this range. The hashes are not compared, see CheckOverlap.
+func (r *Range) Contains(other *Range) bool {
+	return r.begin <= other.begin && other.end <= r.end
+}
+
+// CheckOverlap compares the hashes of the nodes that both compact ranges
+// consist of, i.e. the nodes with the same IDs. Returns a NodeMismatchError for
+// the leftmost node with different hashes.
+//
+// Note that the ranges can overlap without sharing any nodes, in which case
+// the check passes trivially. Comparing the rest of the overlap requires the
+// hashes of the inner nodes, which compact ranges do not store.
+func (r *Range) CheckOverlap(other *Range) error {
+	ids := RangeNodes(r.begin, r.end, nil)
+	otherIDs := RangeNodes(other.begin, other.end, nil)
+	// Both lists of nodes are ordered left to right, and the nodes in each of
+	// them are disjoint. Walk them in parallel to find the matching IDs.
+	for i, j := 0, 0; i < len(ids) && j < len(otherIDs); {
+		begin, _ := ids[i].Coverage()
+		otherBegin, _ := otherIDs[j].Coverage()
+		switch {
+		case begin < otherBegin:
+			i++
+		case otherBegin < begin:
+			j++
+		case ids[i].Level < otherIDs[j].Level:
+			i++
+		case otherIDs[j].Level < ids[i].Level:
+			j++
+		default:
+			if !bytes.Equal(r.hashes[i], other.hashes[j]) {
+				return NodeMismatchError{ID: ids[i], Hash: r.hashes[i], OtherHash: other.hashes[j]}
+			}
+			i, j = i+1, j+1
+		}
+	}
+	return nil
+}
+
+// NodeMismatchError occurs when two compact ranges have different hashes of
+// the same node.
+type NodeMismatchError struct {
+	ID        NodeID
+	Hash      []byte
+	OtherHash []byte
+}
+
+func (e NodeMismatchError) Error() string {
+	return fmt.Sprintf("node %+v: hash %x does not match %x", e.ID, e.Hash, e.OtherHash)
+}
+
 // appendImpl extends the compact range by merging the [r.end, end) compact
 // range into it. The other compact range is decomposed into a seed hash and
 // all the other hashes (possibly none). The method uses the tree hasher to
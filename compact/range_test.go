@@ -19,6 +19,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"math"
 	"math/bits"
 	"math/rand"
 	"reflect"
@@ -26,6 +27,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
 	"github.com/transparency-dev/merkle/rfc6962"
 	"github.com/transparency-dev/merkle/testonly"
 )
@@ -183,6 +185,30 @@ func TestAppend(t *testing.T) {
 	}
 }
 
+func TestAppendOverflow(t *testing.T) {
+	hash := []byte("hash")
+	cr, err := factory.NewRange(math.MaxUint64-1, math.MaxUint64-1, nil)
+	if err != nil {
+		t.Fatalf("NewRange: %v", err)
+	}
+	if err := cr.Append(hash, nil); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if got, want := cr.End(), uint64(math.MaxUint64); got != want {
+		t.Errorf("End: got %d, want %d", got, want)
+	}
+	visited := false
+	if err := cr.Append(hash, func(compact.NodeID, []byte) { visited = true }); err == nil {
+		t.Error("Append: expected error at tree size 2^64-1")
+	}
+	if visited {
+		t.Error("Append: visited a node on error")
+	}
+	if got, want := cr.End(), uint64(math.MaxUint64); got != want {
+		t.Errorf("End: got %d, want %d", got, want)
+	}
+}
+
 func TestGoldenRanges(t *testing.T) {
 	inputs := testonly.LeafInputs()
 	roots := testonly.RootHashes()
@@ -343,6 +369,64 @@ func TestNewRange(t *testing.T) {
 	tree.verifyRange(t, rng1, false)
 }
 
+func TestNewRangeFromNodes(t *testing.T) {
+	const size = uint64(50)
+	tree, _ := newTree(t, size)
+	getHashes := func(ids []compact.NodeID) [][]byte {
+		hashes := make([][]byte, len(ids))
+		for i, id := range ids {
+			hashes[i] = tree.nodes[id.Level][id.Index].hash
+		}
+		return hashes
+	}
+
+	for begin := uint64(0); begin < size; begin++ {
+		for end := begin + 1; end <= size; end++ {
+			ids := compact.RangeNodes(begin, end, nil)
+			rng, err := factory.NewRangeFromNodes(ids, getHashes(ids))
+			if err != nil {
+				t.Fatalf("NewRangeFromNodes([%d, %d)): %v", begin, end, err)
+			}
+			if rng.Begin() != begin || rng.End() != end {
+				t.Errorf("NewRangeFromNodes: got [%d, %d), want [%d, %d)", rng.Begin(), rng.End(), begin, end)
+			}
+			tree.verifyRange(t, rng, true)
+		}
+	}
+
+	id := compact.NewNodeID
+	hash := tree.leaf(0)
+	hashes := func(n int) [][]byte {
+		res := make([][]byte, n)
+		for i := range res {
+			res[i] = hash
+		}
+		return res
+	}
+	for _, tc := range []struct {
+		desc   string
+		ids    []compact.NodeID
+		hashes [][]byte
+	}{
+		{desc: "empty", ids: nil, hashes: nil},
+		{desc: "hashes-count", ids: []compact.NodeID{id(0, 1)}, hashes: hashes(2)},
+		{desc: "empty-hash", ids: []compact.NodeID{id(0, 1)}, hashes: [][]byte{{}}},
+		{desc: "hash-sizes", ids: []compact.NodeID{id(0, 1), id(1, 1)}, hashes: [][]byte{hash, hash[1:]}},
+		{desc: "gap", ids: []compact.NodeID{id(0, 1), id(2, 1)}, hashes: hashes(2)},
+		{desc: "overlap", ids: []compact.NodeID{id(1, 1), id(0, 3)}, hashes: hashes(2)},
+		{desc: "not-minimal", ids: []compact.NodeID{id(0, 2), id(0, 3)}, hashes: hashes(2)},
+		{desc: "wrong-order", ids: []compact.NodeID{id(1, 1), id(0, 1)}, hashes: hashes(2)},
+		{desc: "overflow", ids: []compact.NodeID{id(63, 1)}, hashes: hashes(1)},
+		{desc: "too-high", ids: []compact.NodeID{id(64, 0)}, hashes: hashes(1)},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := factory.NewRangeFromNodes(tc.ids, tc.hashes); err == nil {
+				t.Error("NewRangeFromNodes succeeded unexpectedly")
+			}
+		})
+	}
+}
+
 func TestNewRangeWithStorage(t *testing.T) {
 	const numNodes = uint64(777)
 	tree, _ := newTree(t, numNodes)
@@ -381,6 +465,149 @@ func TestNewRangeWithStorage(t *testing.T) {
 	}
 }
 
+// fetcher returns a FetchFn which reads node hashes from the tree, and records
+// the IDs of the requested nodes.
+func (tr *tree) fetcher(fetched *[]compact.NodeID) compact.FetchFn {
+	return func(id compact.NodeID) ([]byte, error) {
+		*fetched = append(*fetched, id)
+		if id.Level >= uint(len(tr.nodes)) || id.Index >= uint64(len(tr.nodes[id.Level])) {
+			return nil, errors.New("node does not exist")
+		}
+		return tr.nodes[id.Level][id.Index].hash, nil
+	}
+}
+
+// newRange returns the [begin, end) compact range of the tree.
+func (tr *tree) newRange(t *testing.T, begin, end uint64) *compact.Range {
+	t.Helper()
+	rng := factory.NewEmptyRange(begin)
+	for i := begin; i < end; i++ {
+		if err := rng.Append(tr.leaf(i), nil); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	return rng
+}
+
+func TestTruncate(t *testing.T) {
+	const size = uint64(40)
+	tree, _ := newTree(t, size)
+	for begin := uint64(0); begin <= size; begin++ {
+		for end := begin; end <= size; end++ {
+			for newEnd := begin; newEnd <= end; newEnd++ {
+				rng := tree.newRange(t, begin, end)
+				var fetched []compact.NodeID
+				if err := rng.Truncate(newEnd, tree.fetcher(&fetched)); err != nil {
+					t.Fatalf("Truncate: %v", err)
+				}
+				if got, want := rng.End(), newEnd; got != want {
+					t.Errorf("End: got %d, want %d", got, want)
+				}
+				tree.verifyRange(t, rng, true)
+
+				// Only the nodes which are not in the old range are fetched.
+				old := compact.RangeNodes(begin, end, nil)
+				var want []compact.NodeID
+				for _, id := range compact.RangeNodes(begin, newEnd, nil) {
+					found := false
+					for _, o := range old {
+						found = found || o == id
+					}
+					if !found {
+						want = append(want, id)
+					}
+				}
+				if diff := cmp.Diff(want, fetched); diff != "" {
+					t.Errorf("Truncate [%d, %d) to %d: fetched diff(-want +got):\n%s", begin, end, newEnd, diff)
+				}
+
+				// The truncated range continues to work.
+				for i := newEnd; i < end; i++ {
+					if err := rng.Append(tree.leaf(i), nil); err != nil {
+						t.Fatalf("Append: %v", err)
+					}
+				}
+				tree.verifyRange(t, rng, true)
+			}
+		}
+	}
+}
+
+func TestTruncateErrors(t *testing.T) {
+	tree, _ := newTree(t, 20)
+	rng := tree.newRange(t, 3, 17)
+	for _, end := range []uint64{0, 2, 18, 100} {
+		if err := rng.Truncate(end, nil); err == nil {
+			t.Errorf("Truncate(%d): succeeded unexpectedly", end)
+		}
+	}
+	// Truncating to [3, 15) requires nodes under [8, 16).
+	if err := rng.Truncate(15, nil); err == nil {
+		t.Error("Truncate(15): succeeded without fetching")
+	}
+	fetchErr := func(compact.NodeID) ([]byte, error) { return nil, errors.New("failure") }
+	if err := rng.Truncate(15, fetchErr); err == nil {
+		t.Error("Truncate(15): succeeded unexpectedly")
+	}
+	tree.verifyRange(t, rng, true) // The range is intact.
+	if rng.End() != 17 {
+		t.Errorf("End: got %d, want 17", rng.End())
+	}
+	// Truncating to [3, 8) reuses the old hashes.
+	if err := rng.Truncate(8, nil); err != nil {
+		t.Errorf("Truncate(8): %v", err)
+	}
+	tree.verifyRange(t, rng, true)
+}
+
+func TestSplit(t *testing.T) {
+	const size = uint64(40)
+	tree, _ := newTree(t, size)
+	for begin := uint64(0); begin <= size; begin++ {
+		for end := begin; end <= size; end++ {
+			rng := tree.newRange(t, begin, end)
+			for mid := begin; mid <= end; mid++ {
+				var fetched []compact.NodeID
+				left, right, err := rng.Split(mid, tree.fetcher(&fetched))
+				if err != nil {
+					t.Fatalf("Split: %v", err)
+				}
+				if left.Begin() != begin || left.End() != mid || right.Begin() != mid || right.End() != end {
+					t.Fatalf("Split(%d): got [%d, %d) and [%d, %d)", mid, left.Begin(), left.End(), right.Begin(), right.End())
+				}
+				tree.verifyRange(t, left, true)
+				tree.verifyRange(t, right, true)
+				// Nodes of the original range are never fetched.
+				for _, id := range fetched {
+					for _, o := range compact.RangeNodes(begin, end, nil) {
+						if id == o {
+							t.Errorf("Split(%d): fetched node %+v of the original range", mid, id)
+						}
+					}
+				}
+				// Merging the parts back gives the original range.
+				if err := left.AppendRange(right, nil); err != nil {
+					t.Fatalf("AppendRange: %v", err)
+				}
+				if !left.Equal(rng) {
+					t.Errorf("Split(%d): merged range differs from the original", mid)
+				}
+			}
+			tree.verifyRange(t, rng, true) // The original range is intact.
+		}
+	}
+
+	rng := tree.newRange(t, 5, 20)
+	for _, mid := range []uint64{4, 21} {
+		if _, _, err := rng.Split(mid, nil); err == nil {
+			t.Errorf("Split(%d): succeeded unexpectedly", mid)
+		}
+	}
+	if _, _, err := rng.Split(10, nil); err == nil {
+		t.Error("Split(10): succeeded without fetching")
+	}
+}
+
 func TestGetRootHash(t *testing.T) {
 	for size := uint64(0); size < 16; size++ {
 		t.Run(fmt.Sprintf("size:%d", size), func(t *testing.T) {
@@ -408,6 +635,202 @@ func TestGetRootHash(t *testing.T) {
 	}
 }
 
+func TestGetRootHashAt(t *testing.T) {
+	const size = uint64(40)
+	tree, _ := newTree(t, size)
+	for end := uint64(0); end <= size; end++ {
+		rng := tree.newRange(t, 0, end)
+		for at := uint64(0); at <= end; at++ {
+			var fetched []compact.NodeID
+			root, err := rng.GetRootHashAt(at, tree.fetcher(&fetched))
+			if err != nil {
+				t.Fatalf("GetRootHashAt(%d): %v", at, err)
+			}
+			want, _ := newTree(t, at)
+			if !bytes.Equal(root, want.rootHash()) {
+				t.Errorf("GetRootHashAt(%d): got %08x, want %08x", at, shorten(root), shorten(want.rootHash()))
+			}
+			// Nodes of the range are never fetched.
+			for _, id := range fetched {
+				for _, o := range compact.RangeNodes(0, end, nil) {
+					if id == o {
+						t.Errorf("GetRootHashAt(%d): fetched node %+v of the range", at, id)
+					}
+				}
+			}
+		}
+		tree.verifyRange(t, rng, true) // The range is intact.
+	}
+
+	rng := tree.newRange(t, 0, 13)
+	if _, err := rng.GetRootHashAt(14, nil); err == nil {
+		t.Error("GetRootHashAt(14): succeeded unexpectedly")
+	}
+	// The root at size 10 requires nodes under [8, 10).
+	if _, err := rng.GetRootHashAt(10, nil); err == nil {
+		t.Error("GetRootHashAt(10): succeeded without fetching")
+	}
+	// The root at size 8 is in the range.
+	if _, err := rng.GetRootHashAt(8, nil); err != nil {
+		t.Errorf("GetRootHashAt(8): %v", err)
+	}
+	if _, err := tree.newRange(t, 3, 13).GetRootHashAt(10, nil); err == nil {
+		t.Error("GetRootHashAt: succeeded for range not starting at 0")
+	}
+}
+
+func TestFrontier(t *testing.T) {
+	const size = uint64(40)
+	tree, _ := newTree(t, size)
+	for end := uint64(0); end <= size; end++ {
+		rng := tree.newRange(t, 0, end)
+		for at := uint64(0); at <= end; at++ {
+			var fetched []compact.NodeID
+			ids, hashes, err := rng.Frontier(at, tree.fetcher(&fetched))
+			if err != nil {
+				t.Fatalf("Frontier(%d): %v", at, err)
+			}
+			if diff := cmp.Diff(ids, compact.RangeNodes(0, at, nil)); diff != "" {
+				t.Errorf("Frontier(%d): IDs diff(-got +want):\n%s", at, diff)
+			}
+			// Resume appending to the restored range.
+			restored := factory.NewEmptyRange(0)
+			if len(ids) != 0 {
+				if restored, err = factory.NewRangeFromNodes(ids, hashes); err != nil {
+					t.Fatalf("NewRangeFromNodes: %v", err)
+				}
+			}
+			for i := at; i < end; i++ {
+				if err := restored.Append(tree.leaf(i), nil); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+			if !restored.Equal(rng) {
+				t.Errorf("Frontier(%d): restored range differs from the original", at)
+			}
+		}
+	}
+	rng := tree.newRange(t, 5, 20)
+	for _, end := range []uint64{4, 21} {
+		if _, _, err := rng.Frontier(end, nil); err == nil {
+			t.Errorf("Frontier(%d): succeeded unexpectedly", end)
+		}
+	}
+}
+
+func TestLoadRange(t *testing.T) {
+	const size = uint64(40)
+	tree, _ := newTree(t, size)
+	for begin := uint64(0); begin <= size; begin++ {
+		for end := begin; end <= size; end++ {
+			var fetched []compact.NodeID
+			rng, err := factory.LoadRange(begin, end, tree.fetcher(&fetched))
+			if err != nil {
+				t.Fatalf("LoadRange(%d, %d): %v", begin, end, err)
+			}
+			if !rng.Equal(tree.newRange(t, begin, end)) {
+				t.Errorf("LoadRange(%d, %d): wrong range", begin, end)
+			}
+			if diff := cmp.Diff(fetched, compact.RangeNodes(begin, end, nil)); diff != "" {
+				t.Errorf("LoadRange(%d, %d): fetched diff(-got +want):\n%s", begin, end, diff)
+			}
+		}
+	}
+	if _, err := factory.LoadRange(5, 4, nil); err == nil {
+		t.Error("LoadRange(5, 4): succeeded unexpectedly")
+	}
+	if _, err := factory.LoadRange(0, 4, nil); err == nil {
+		t.Error("LoadRange(0, 4): succeeded without fetching")
+	}
+}
+
+func TestContains(t *testing.T) {
+	for _, tc := range []struct {
+		begin, end uint64
+		want       bool
+	}{
+		{begin: 3, end: 10, want: true},
+		{begin: 3, end: 3, want: true},
+		{begin: 5, end: 7, want: true},
+		{begin: 10, end: 10, want: true},
+		{begin: 2, end: 10, want: false},
+		{begin: 3, end: 11, want: false},
+		{begin: 11, end: 12, want: false},
+	} {
+		rng := factory.NewEmptyRange(3)
+		for i := 0; i < 7; i++ {
+			if err := rng.Append([]byte("hash"), nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		other := factory.NewEmptyRange(tc.begin)
+		for i := tc.begin; i < tc.end; i++ {
+			if err := other.Append([]byte("other"), nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		if got, want := rng.Contains(other), tc.want; got != want {
+			t.Errorf("Contains([%d, %d)): got %v, want %v", tc.begin, tc.end, got, want)
+		}
+	}
+}
+
+func TestCheckOverlap(t *testing.T) {
+	const size = uint64(20)
+	const fork = uint64(11) // The leaf which differs in the forked tree.
+	tree, _ := newTree(t, size)
+	forkedRange := func(begin, end uint64) *compact.Range {
+		rng := factory.NewEmptyRange(begin)
+		for i := begin; i < end; i++ {
+			hash := tree.leaf(i)
+			if i == fork {
+				hash = []byte("fork")
+			}
+			if err := rng.Append(hash, nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		return rng
+	}
+
+	for begin1 := uint64(0); begin1 <= size; begin1++ {
+		for end1 := begin1; end1 <= size; end1++ {
+			rng := tree.newRange(t, begin1, end1)
+			for begin2 := uint64(0); begin2 <= size; begin2++ {
+				for end2 := begin2; end2 <= size; end2++ {
+					if err := rng.CheckOverlap(tree.newRange(t, begin2, end2)); err != nil {
+						t.Fatalf("CheckOverlap([%d, %d), [%d, %d)): %v", begin1, end1, begin2, end2, err)
+					}
+
+					// The only shared node that can differ is the one covering the fork.
+					var want compact.NodeID
+					shared := false
+					for _, id := range compact.RangeNodes(begin2, end2, nil) {
+						if b, e := id.Coverage(); fork >= b && fork < e {
+							for _, id1 := range compact.RangeNodes(begin1, end1, nil) {
+								if id1 == id {
+									want, shared = id, true
+								}
+							}
+						}
+					}
+					err := rng.CheckOverlap(forkedRange(begin2, end2))
+					var mismatch compact.NodeMismatchError
+					if !shared {
+						if err != nil {
+							t.Errorf("CheckOverlap([%d, %d), forked [%d, %d)): %v", begin1, end1, begin2, end2, err)
+						}
+					} else if !errors.As(err, &mismatch) {
+						t.Errorf("CheckOverlap([%d, %d), forked [%d, %d)): got %v, want NodeMismatchError", begin1, end1, begin2, end2, err)
+					} else if got := mismatch.ID; got != want {
+						t.Errorf("CheckOverlap([%d, %d), forked [%d, %d)): got node %+v, want %+v", begin1, end1, begin2, end2, got, want)
+					}
+				}
+			}
+		}
+	}
+}
+
 func TestGetRootHashGolden(t *testing.T) {
 	type node struct {
 		level uint
@@ -494,6 +917,101 @@ func TestGetRootHashGolden(t *testing.T) {
 	}
 }
 
+func TestSubtreeRoot(t *testing.T) {
+	const size = uint64(200)
+	tree, _ := newTree(t, size)
+	leaves := make([][]byte, size)
+	for i := range leaves {
+		leaves[i] = tree.leaf(uint64(i))
+	}
+
+	for _, tc := range []struct {
+		begin, end uint64
+		wantID     compact.NodeID
+		wantErr    bool
+	}{
+		{begin: 0, end: 1, wantID: compact.NewNodeID(0, 0)},
+		{begin: 5, end: 6, wantID: compact.NewNodeID(0, 5)},
+		{begin: 0, end: 7, wantID: compact.NewNodeID(3, 0)},
+		{begin: 0, end: 8, wantID: compact.NewNodeID(3, 0)},
+		{begin: 8, end: 11, wantID: compact.NewNodeID(2, 2)},
+		{begin: 64, end: 100, wantID: compact.NewNodeID(6, 1)},
+		{begin: 128, end: 200, wantID: compact.NewNodeID(7, 1)},
+		// Errors.
+		{begin: 0, end: 0, wantErr: true},
+		{begin: 1, end: 3, wantErr: true},
+		{begin: 4, end: 9, wantErr: true},
+		{begin: 100, end: 200, wantErr: true},
+	} {
+		t.Run(fmt.Sprintf("[%d,%d)", tc.begin, tc.end), func(t *testing.T) {
+			id, hash, err := compact.SubtreeRoot(factory.Hash, tc.begin, leaves[tc.begin:tc.end])
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("SubtreeRoot: accepted bad params")
+				}
+				return
+			} else if err != nil {
+				t.Fatalf("SubtreeRoot: %v", err)
+			}
+			if id != tc.wantID {
+				t.Errorf("SubtreeRoot: got ID %+v, want %+v", id, tc.wantID)
+			}
+			// The range is aligned, so its hash is the same as the root hash of the
+			// tree consisting of the same leaves.
+			rng := factory.NewEmptyRange(0)
+			for _, leaf := range leaves[tc.begin:tc.end] {
+				if err := rng.Append(leaf, nil); err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+			want, err := rng.GetRootHash(nil)
+			if err != nil {
+				t.Fatalf("GetRootHash: %v", err)
+			}
+			if !bytes.Equal(hash, want) {
+				t.Errorf("SubtreeRoot: got hash %08x, want %08x", shorten(hash), shorten(want))
+			}
+			if b, e := id.Coverage(); tc.end == e {
+				if want := tree.nodes[id.Level][b>>id.Level].hash; !bytes.Equal(hash, want) {
+					t.Errorf("SubtreeRoot: got hash %08x, want perfect %08x", shorten(hash), shorten(want))
+				}
+			}
+		})
+	}
+}
+
+// TestPrunableNotInProofs checks that proofs above the pruning boundary do not
+// use any of the prunable nodes.
+func TestPrunableNotInProofs(t *testing.T) {
+	const size = uint64(70)
+	check := func(desc string, boundary uint64, ids []compact.NodeID) {
+		t.Helper()
+		for _, id := range ids {
+			if id.Index < compact.Prunable(boundary, id.Level) {
+				t.Errorf("%s: uses node %+v pruned at boundary %d", desc, id, boundary)
+			}
+		}
+	}
+	for boundary := uint64(0); boundary <= size; boundary++ {
+		for size2 := boundary; size2 <= size; size2++ {
+			for index := boundary; index < size2; index++ {
+				nodes, err := proof.Inclusion(index, size2)
+				if err != nil {
+					t.Fatalf("Inclusion: %v", err)
+				}
+				check(fmt.Sprintf("Inclusion(%d, %d)", index, size2), boundary, nodes.IDs)
+			}
+			for size1 := boundary; size1 <= size2; size1++ {
+				nodes, err := proof.Consistency(size1, size2)
+				if err != nil {
+					t.Fatalf("Consistency: %v", err)
+				}
+				check(fmt.Sprintf("Consistency(%d, %d)", size1, size2), boundary, nodes.IDs)
+			}
+		}
+	}
+}
+
 func TestDecomposeCases(t *testing.T) {
 	for _, tc := range []struct {
 		begin, end   uint64
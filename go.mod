@@ -2,4 +2,7 @@ module github.com/transparency-dev/merkle
 
 go 1.17
 
-require github.com/google/go-cmp v0.5.9
+require (
+	github.com/google/go-cmp v0.5.9
+	golang.org/x/mod v0.12.0
+)
@@ -15,6 +15,8 @@
 // Package merkle provides Merkle tree interfaces and implementation.
 package merkle
 
+import "context"
+
 // TODO(pavelkalinnikov): Remove this root package. The only interface provided
 // here does not have to exist, and can be [re-]defined on the user side, such
 // as in compact or proof package.
@@ -30,3 +32,118 @@ type LogHasher interface {
 	// Size returns the number of bytes the Hash* functions will return.
 	Size() int
 }
+
+// ContextLogHasher is like LogHasher, but its hash computations can block and
+// fail. This allows computing hashes remotely, e.g. keyed hashes in an HSM or a
+// KMS which never exposes the key.
+type ContextLogHasher interface {
+	// EmptyRootContext returns the root hash of an empty tree.
+	EmptyRootContext(ctx context.Context) ([]byte, error)
+	// HashLeafContext computes the hash of a leaf that exists.
+	HashLeafContext(ctx context.Context, leaf []byte) ([]byte, error)
+	// HashChildrenContext computes interior nodes.
+	HashChildrenContext(ctx context.Context, l, r []byte) ([]byte, error)
+	// Size returns the number of bytes the Hash* functions will return.
+	Size() int
+}
+
+// WithContext returns a ContextLogHasher which computes hashes using the given
+// LogHasher. The returned hasher fails only if the context is done.
+func WithContext(hasher LogHasher) ContextLogHasher {
+	return contextHasher{LogHasher: hasher}
+}
+
+type contextHasher struct {
+	LogHasher
+}
+
+func (h contextHasher) EmptyRootContext(ctx context.Context) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.EmptyRoot(), nil
+}
+
+func (h contextHasher) HashLeafContext(ctx context.Context, leaf []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.HashLeaf(leaf), nil
+}
+
+func (h contextHasher) HashChildrenContext(ctx context.Context, l, r []byte) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return h.HashChildren(l, r), nil
+}
+
+// BoundHasher is a LogHasher which computes hashes using a ContextLogHasher
+// bound to a context. It allows passing a ContextLogHasher to code that works
+// with a LogHasher, such as proof verification or compact ranges (through the
+// compact.RangeFactory.Hash function set to HashChildren).
+//
+// The first error returned by the underlying hasher is remembered, and all the
+// hashes computed after it are zeros. The caller must check Err after the
+// computation to find out whether its result is valid. BoundHasher is not safe
+// for concurrent use.
+type BoundHasher struct {
+	ctx    context.Context
+	hasher ContextLogHasher
+	err    error
+}
+
+// Bind returns a BoundHasher which computes hashes using the given hasher and
+// context.
+func Bind(ctx context.Context, hasher ContextLogHasher) *BoundHasher {
+	return &BoundHasher{ctx: ctx, hasher: hasher}
+}
+
+// Err returns the first error that occurred in hash computations, or nil.
+func (b *BoundHasher) Err() error {
+	return b.err
+}
+
+// EmptyRoot returns the root hash of an empty tree.
+func (b *BoundHasher) EmptyRoot() []byte {
+	if b.err != nil {
+		return b.zero()
+	}
+	hash, err := b.hasher.EmptyRootContext(b.ctx)
+	return b.check(hash, err)
+}
+
+// HashLeaf computes the hash of a leaf that exists.
+func (b *BoundHasher) HashLeaf(leaf []byte) []byte {
+	if b.err != nil {
+		return b.zero()
+	}
+	hash, err := b.hasher.HashLeafContext(b.ctx, leaf)
+	return b.check(hash, err)
+}
+
+// HashChildren computes interior nodes.
+func (b *BoundHasher) HashChildren(l, r []byte) []byte {
+	if b.err != nil {
+		return b.zero()
+	}
+	hash, err := b.hasher.HashChildrenContext(b.ctx, l, r)
+	return b.check(hash, err)
+}
+
+// Size returns the number of bytes the Hash* functions will return.
+func (b *BoundHasher) Size() int {
+	return b.hasher.Size()
+}
+
+func (b *BoundHasher) check(hash []byte, err error) []byte {
+	if err != nil {
+		b.err = err
+		return b.zero()
+	}
+	return hash
+}
+
+func (b *BoundHasher) zero() []byte {
+	return make([]byte, b.hasher.Size())
+}
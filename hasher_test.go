@@ -0,0 +1,103 @@
+// Copyright 2016 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkle_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// failingHasher is a ContextLogHasher which fails after the given number of
+// hash computations.
+type failingHasher struct {
+	merkle.ContextLogHasher
+	left int
+}
+
+func (f *failingHasher) HashChildrenContext(ctx context.Context, l, r []byte) ([]byte, error) {
+	if f.left == 0 {
+		return nil, errors.New("hasher unavailable")
+	}
+	f.left--
+	return f.ContextLogHasher.HashChildrenContext(ctx, l, r)
+}
+
+func TestBoundHasher(t *testing.T) {
+	ctx := context.Background()
+	hasher := rfc6962.NewHMAC(rfc6962.DefaultHasher.Hash, []byte("key"))
+	b := merkle.Bind(ctx, merkle.WithContext(hasher))
+
+	// Build a compact range with the bound hasher, and compare against the
+	// range built with the plain hasher.
+	f := &compact.RangeFactory{Hash: b.HashChildren}
+	want := (&compact.RangeFactory{Hash: hasher.HashChildren}).NewEmptyRange(0)
+	rng := f.NewEmptyRange(0)
+	for i := 0; i < 13; i++ {
+		leaf := []byte{byte(i)}
+		if err := rng.Append(b.HashLeaf(leaf), nil); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if err := want.Append(hasher.HashLeaf(leaf), nil); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	root, err := rng.GetRootHash(nil)
+	if err != nil {
+		t.Fatalf("GetRootHash: %v", err)
+	}
+	wantRoot, err := want.GetRootHash(nil)
+	if err != nil {
+		t.Fatalf("GetRootHash: %v", err)
+	}
+	if !bytes.Equal(root, wantRoot) {
+		t.Errorf("GetRootHash: got %x, want %x", root, wantRoot)
+	}
+
+	// The first error is remembered, and the subsequent hashes are zeros.
+	fh := &failingHasher{ContextLogHasher: merkle.WithContext(hasher), left: 2}
+	b = merkle.Bind(ctx, fh)
+	for i := 0; i < 2; i++ {
+		b.HashChildren(root, root)
+	}
+	if err := b.Err(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if got := b.HashChildren(root, root); !bytes.Equal(got, make([]byte, hasher.Size())) {
+		t.Errorf("HashChildren: got %x, want zeros", got)
+	}
+	if b.Err() == nil {
+		t.Error("Err: want error")
+	}
+	fh.left = 10
+	if got := b.HashLeaf(nil); !bytes.Equal(got, make([]byte, hasher.Size())) {
+		t.Errorf("HashLeaf after error: got %x, want zeros", got)
+	}
+
+	// A done context fails the hashing.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := merkle.WithContext(hasher).HashLeafContext(cctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("HashLeafContext: got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics defines the metrics which the proof builders and verifiers
+// of this module can report.
+//
+// By default, nothing is reported. To export the metrics, e.g. to Prometheus
+// or OpenTelemetry, implement the Recorder interface on top of the monitoring
+// library of choice, and pass it to the components which report them, e.g.
+// proof.Verifier and proof.Fetcher. The metric names are defined by the
+// constants in this package.
+package metrics
+
+// The names of the reported metrics.
+const (
+	// ProofsGenerated is the counter of proofs built from the fetched node
+	// hashes, see proof.Fetcher.Proof.
+	ProofsGenerated = "merkle_proofs_generated_total"
+	// ProofSize is the histogram of the number of hashes in generated proofs.
+	ProofSize = "merkle_proof_size"
+	// NodesFetched is the counter of node hashes fetched for building proofs.
+	NodesFetched = "merkle_nodes_fetched_total"
+	// HashesComputed is the counter of node hashes computed while building and
+	// verifying proofs.
+	HashesComputed = "merkle_hashes_computed_total"
+	// VerifyLatency is the histogram of proof verification latency, in seconds.
+	VerifyLatency = "merkle_verify_latency_seconds"
+	// VerifyFailures is the counter of failed proof verifications.
+	VerifyFailures = "merkle_verify_failures_total"
+)
+
+// Recorder receives the metrics. Its methods can be called concurrently, and
+// should be cheap because they are called on the hot paths.
+type Recorder interface {
+	// Add increments the counter with the given name by delta.
+	Add(name string, delta uint64)
+	// Observe adds the value to the histogram with the given name.
+	Observe(name string, value float64)
+}
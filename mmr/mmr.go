@@ -0,0 +1,165 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mmr implements a Merkle Mountain Range (MMR).
+//
+// An MMR is an append-only list of perfect Merkle trees (mountains) of strictly
+// decreasing sizes, which is exactly the compact range [0, size) of a Merkle
+// tree. Its nodes are stored in post-order, i.e. each node is appended right
+// after both its children. The node addresses are shared with the compact
+// package, and the post-order positions can be converted to/from NodeIDs with
+// the Pos and FromPos functions.
+//
+// The MMR root is computed by "bagging" the peaks from right to left. With this
+// choice, the MMR root and inclusion proofs are identical to those of the
+// RFC 6962 Merkle tree over the same leaves, so the proofs can be verified with
+// proof.VerifyInclusion.
+package mmr
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// Size returns the number of nodes in an MMR with the given number of leaves.
+func Size(leaves uint64) uint64 {
+	return 2*leaves - uint64(bits.OnesCount64(leaves))
+}
+
+// Pos returns the post-order position of the given node in the MMR.
+func Pos(id compact.NodeID) uint64 {
+	// The node is created when appending the last leaf of its subtree, along
+	// with its ancestors up to the level of trailing zeros of end.
+	_, end := id.Coverage()
+	return Size(end) - 1 - uint64(uint(bits.TrailingZeros64(end))-id.Level)
+}
+
+// FromPos returns the ID of the node at the given post-order position. This is
+// the inverse of Pos.
+func FromPos(pos uint64) compact.NodeID {
+	var begin uint64
+	for {
+		// Find the highest perfect tree which fits into [0, pos], it is the prefix
+		// of the post-order. The node is either its root, or is further right.
+		level := uint(bits.Len64(pos+2)) - 2
+		size := uint64(2)<<level - 1
+		if pos == size-1 {
+			return compact.NewNodeID(level, begin>>level)
+		}
+		pos -= size
+		begin += uint64(1) << level
+	}
+}
+
+// Peaks returns the IDs of the MMR peaks, i.e. the roots of its mountains,
+// ordered from left to right.
+func Peaks(leaves uint64) []compact.NodeID {
+	return compact.RangeNodes(0, leaves, nil)
+}
+
+// Bag returns the MMR root hash computed from the given peak hashes ordered from
+// left to right. The peaks are bagged from right to left.
+func Bag(hasher merkle.LogHasher, peaks [][]byte) []byte {
+	if len(peaks) == 0 {
+		return hasher.EmptyRoot()
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = hasher.HashChildren(peaks[i], root)
+	}
+	return root
+}
+
+// MMR is an in-memory Merkle Mountain Range. It is not safe for concurrent use.
+type MMR struct {
+	hasher merkle.LogHasher
+	nodes  [][]byte // Node hashes in post-order.
+	leaves uint64
+}
+
+// New returns an empty MMR which uses the given hasher.
+func New(hasher merkle.LogHasher) *MMR {
+	return &MMR{hasher: hasher}
+}
+
+// Leaves returns the number of leaves in the MMR.
+func (m *MMR) Leaves() uint64 {
+	return m.leaves
+}
+
+// Size returns the number of nodes in the MMR.
+func (m *MMR) Size() uint64 {
+	return uint64(len(m.nodes))
+}
+
+// Append adds a leaf with the given data to the MMR, and returns its index.
+func (m *MMR) Append(data []byte) uint64 {
+	return m.AppendHash(m.hasher.HashLeaf(data))
+}
+
+// AppendHash adds a leaf with the given hash to the MMR, and returns its index.
+func (m *MMR) AppendHash(hash []byte) uint64 {
+	index := m.leaves
+	m.nodes = append(m.nodes, hash)
+	m.leaves++
+	// Merge the mountains of equal size, one per trailing zero of the new size.
+	for id, n := compact.NewNodeID(0, index), bits.TrailingZeros64(m.leaves); n > 0; n-- {
+		left := m.nodes[Pos(id.Sibling())]
+		hash = m.hasher.HashChildren(left, hash)
+		m.nodes = append(m.nodes, hash)
+		id = id.Parent()
+	}
+	return index
+}
+
+// Node returns the hash of the node at the given post-order position.
+func (m *MMR) Node(pos uint64) ([]byte, error) {
+	if pos >= m.Size() {
+		return nil, fmt.Errorf("position %d out of range, size %d", pos, m.Size())
+	}
+	return m.nodes[pos], nil
+}
+
+// Peaks returns the hashes of the MMR peaks, ordered from left to right.
+func (m *MMR) Peaks() [][]byte {
+	ids := Peaks(m.leaves)
+	hashes := make([][]byte, len(ids))
+	for i, id := range ids {
+		hashes[i] = m.nodes[Pos(id)]
+	}
+	return hashes
+}
+
+// Root returns the MMR root hash.
+func (m *MMR) Root() []byte {
+	return Bag(m.hasher, m.Peaks())
+}
+
+// InclusionProof returns the inclusion proof for the given leaf index in the
+// current state of the MMR.
+func (m *MMR) InclusionProof(index uint64) ([][]byte, error) {
+	nodes, err := proof.Inclusion(index, m.leaves)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([][]byte, len(nodes.IDs))
+	for i, id := range nodes.IDs {
+		hashes[i] = m.nodes[Pos(id)]
+	}
+	return nodes.Rehash(hashes, m.hasher.HashChildren)
+}
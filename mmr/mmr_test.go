@@ -0,0 +1,149 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mmr
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestPos(t *testing.T) {
+	id := compact.NewNodeID
+	// The post-order of an MMR with 11 leaves:
+	//
+	//	L3:               14
+	//	L2:       6               13
+	//	L1:   2       5       9       12      17
+	//	L0: 0   1   3   4   7   8  10  11  15  16  18
+	want := []compact.NodeID{
+		id(0, 0), id(0, 1), id(1, 0), id(0, 2), id(0, 3), id(1, 1), id(2, 0),
+		id(0, 4), id(0, 5), id(1, 2), id(0, 6), id(0, 7), id(1, 3), id(2, 1),
+		id(3, 0), id(0, 8), id(0, 9), id(1, 4), id(0, 10),
+	}
+	if got, want := Size(11), uint64(len(want)); got != want {
+		t.Errorf("Size(11): got %d, want %d", got, want)
+	}
+	for pos, id := range want {
+		if got, want := Pos(id), uint64(pos); got != want {
+			t.Errorf("Pos(%+v): got %d, want %d", id, got, want)
+		}
+		if got := FromPos(uint64(pos)); got != id {
+			t.Errorf("FromPos(%d): got %+v, want %+v", pos, got, id)
+		}
+	}
+}
+
+func TestPosRoundTrip(t *testing.T) {
+	for pos := uint64(0); pos < 1<<12; pos++ {
+		if got := Pos(FromPos(pos)); got != pos {
+			t.Fatalf("Pos(FromPos(%d)): got %d", pos, got)
+		}
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	id := compact.NewNodeID
+	for _, tc := range []struct {
+		leaves uint64
+		want   []compact.NodeID
+	}{
+		{leaves: 0, want: nil},
+		{leaves: 1, want: []compact.NodeID{id(0, 0)}},
+		{leaves: 4, want: []compact.NodeID{id(2, 0)}},
+		{leaves: 11, want: []compact.NodeID{id(3, 0), id(1, 4), id(0, 10)}},
+	} {
+		t.Run(fmt.Sprintf("%d", tc.leaves), func(t *testing.T) {
+			if diff := cmp.Diff(Peaks(tc.leaves), tc.want); diff != "" {
+				t.Errorf("Peaks: diff(-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMMR(t *testing.T) {
+	const size = 70
+	hasher := rfc6962.DefaultHasher
+	m := New(hasher)
+	tree := testonly.New(hasher)
+	if got, want := m.Root(), hasher.EmptyRoot(); !bytes.Equal(got, want) {
+		t.Errorf("Root: got %x, want %x", got, want)
+	}
+
+	for i := uint64(0); i < size; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		if got := m.Append(data); got != i {
+			t.Fatalf("Append: got index %d, want %d", got, i)
+		}
+		tree.AppendData(data)
+
+		leaves := i + 1
+		if got, want := m.Leaves(), leaves; got != want {
+			t.Errorf("Leaves: got %d, want %d", got, want)
+		}
+		if got, want := m.Size(), Size(leaves); got != want {
+			t.Errorf("Size: got %d, want %d", got, want)
+		}
+		root := m.Root()
+		if want := tree.Hash(); !bytes.Equal(root, want) {
+			t.Fatalf("Root at %d: got %x, want %x", leaves, root, want)
+		}
+		for index := uint64(0); index < leaves; index++ {
+			p, err := m.InclusionProof(index)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d): %v", index, err)
+			}
+			if err := proof.VerifyInclusion(hasher, index, leaves, tree.LeafHash(index), p, root); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d): %v", index, leaves, err)
+			}
+		}
+	}
+
+	for pos := uint64(0); pos < m.Size(); pos++ {
+		hash, err := m.Node(pos)
+		if err != nil {
+			t.Fatalf("Node(%d): %v", pos, err)
+		}
+		id := FromPos(pos)
+		begin, end := id.Coverage()
+		_, want, err := compact.SubtreeRoot(hasher.HashChildren, begin, leafHashes(tree, begin, end))
+		if err != nil {
+			t.Fatalf("SubtreeRoot: %v", err)
+		}
+		if !bytes.Equal(hash, want) {
+			t.Errorf("Node(%d): got %x, want %x", pos, hash, want)
+		}
+	}
+	if _, err := m.Node(m.Size()); err == nil {
+		t.Error("Node: want error for out of range position")
+	}
+	if _, err := m.InclusionProof(size); err == nil {
+		t.Error("InclusionProof: want error for out of range index")
+	}
+}
+
+func leafHashes(tree *testonly.Tree, begin, end uint64) [][]byte {
+	hashes := make([][]byte, 0, end-begin)
+	for i := begin; i < end; i++ {
+		hashes = append(hashes, tree.LeafHash(i))
+	}
+	return hashes
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package monitor provides a helper for following a verifiable log.
+//
+// The Monitor keeps the compact range of all the log entries it has verified.
+// Each new tree head is verified by appending the new entries to this range,
+// and checking the resulting root hash, which also proves that the new tree is
+// consistent with all the previous ones. No consistency proofs are needed.
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// TreeHead is a log tree head. The caller is responsible for checking its
+// signature before passing it to the Monitor.
+type TreeHead struct {
+	Size     uint64
+	RootHash []byte
+}
+
+// State is the persistent state of the Monitor.
+type State struct {
+	TreeHead          // The latest verified tree head.
+	Range    [][]byte // The hashes of the [0, TreeHead.Size) compact range.
+}
+
+// EntryFn is called for each verified log entry, in order. The delivery is
+// at-least-once: if an Update fails after some entries have been delivered,
+// e.g. because EntryFn or SaveFn failed, retrying it delivers them again. The
+// callback should be idempotent, or deduplicate the entries by index.
+type EntryFn func(index uint64, data []byte) error
+
+// SaveFn persists the given monitor state.
+type SaveFn func(State) error
+
+// Monitor follows a verifiable log. It is not safe for concurrent use.
+type Monitor struct {
+	hasher  merkle.LogHasher
+	rng     *compact.Range
+	root    []byte
+	onEntry EntryFn
+	save    SaveFn
+}
+
+// New returns a Monitor which starts from the given state, e.g. the one loaded
+// from persistent storage, or the zero State for a fresh start. The onEntry and
+// save callbacks are optional, and can be nil.
+func New(hasher merkle.LogHasher, state State, onEntry EntryFn, save SaveFn) (*Monitor, error) {
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	rng, err := rf.NewRange(0, state.Size, state.Range)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state: %v", err)
+	}
+	root, err := proof.RootAfterAppend(hasher, rng, nil)
+	if err != nil {
+		return nil, err
+	}
+	// The root hash can be omitted in the zero state.
+	if (state.Size != 0 || state.RootHash != nil) && !bytes.Equal(root, state.RootHash) {
+		return nil, fmt.Errorf("invalid state: root hash %x, want %x", state.RootHash, root)
+	}
+	return &Monitor{hasher: hasher, rng: rng, root: root, onEntry: onEntry, save: save}, nil
+}
+
+// State returns a copy of the current state of the Monitor.
+func (m *Monitor) State() State {
+	var rng [][]byte
+	for _, hash := range m.rng.Hashes() {
+		rng = append(rng, append([]byte(nil), hash...))
+	}
+	return State{
+		TreeHead: TreeHead{Size: m.rng.End(), RootHash: append([]byte(nil), m.root...)},
+		Range:    rng,
+	}
+}
+
+// Update verifies the given tree head, using the data of all the entries in
+// [m.State().Size, th.Size) which must be provided.
+//
+// On success, the onEntry callback is called for all the new entries in order,
+// then the new state is saved with the save callback, and only then the
+// Monitor advances to it. If any of the callbacks fails, the state is not
+// changed, so the same update can be retried. Note that the retry delivers the
+// new entries to onEntry again, see EntryFn.
+func (m *Monitor) Update(th TreeHead, entries [][]byte) error {
+	size := m.rng.End()
+	if th.Size < size {
+		return fmt.Errorf("tree head size %d is smaller than the verified size %d", th.Size, size)
+	}
+	hashes := make([][]byte, len(entries))
+	for i, data := range entries {
+		hashes[i] = m.hasher.HashLeaf(data)
+	}
+	rng, err := proof.VerifyAppend(m.hasher, m.rng, hashes, th.Size, th.RootHash)
+	if err != nil {
+		return fmt.Errorf("tree head %d: %w", th.Size, err)
+	}
+
+	if m.onEntry != nil {
+		for i, data := range entries {
+			if err := m.onEntry(size+uint64(i), data); err != nil {
+				return err
+			}
+		}
+	}
+	if m.save != nil {
+		if err := m.save(State{TreeHead: th, Range: rng.Hashes()}); err != nil {
+			return err
+		}
+	}
+	m.rng, m.root = rng, th.RootHash
+	return nil
+}
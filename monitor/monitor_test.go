@@ -0,0 +1,203 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func TestMonitor(t *testing.T) {
+	const size = 50
+	leaves := testonly.GenLeaves(1, 0, size)
+	tree := testonly.GenTree(hasher, 1, size)
+	head := func(size uint64) TreeHead {
+		return TreeHead{Size: size, RootHash: tree.HashAt(size)}
+	}
+
+	var saved State
+	var seen []uint64
+	onEntry := func(index uint64, data []byte) error {
+		if diff := cmp.Diff(data, leaves[index]); diff != "" {
+			t.Errorf("onEntry(%d): diff(-got +want):\n%s", index, diff)
+		}
+		seen = append(seen, index)
+		return nil
+	}
+	save := func(s State) error {
+		saved = s
+		return nil
+	}
+
+	m, err := New(hasher, State{}, onEntry, save)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if diff := cmp.Diff(m.State(), State{TreeHead: head(0)}); diff != "" {
+		t.Errorf("State: diff(-got +want):\n%s", diff)
+	}
+
+	var prev uint64
+	for _, next := range []uint64{0, 1, 3, 3, 8, 21, 33} {
+		if err := m.Update(head(next), leaves[prev:next]); err != nil {
+			t.Fatalf("Update(%d): %v", next, err)
+		}
+		prev = next
+	}
+	if diff := cmp.Diff(m.State(), saved); diff != "" {
+		t.Errorf("saved state: diff(-got +want):\n%s", diff)
+	}
+	if got, want := m.State().TreeHead, head(33); !cmp.Equal(got, want) {
+		t.Errorf("TreeHead: got %+v, want %+v", got, want)
+	}
+
+	// Restart from the saved state.
+	m, err = New(hasher, saved, onEntry, save)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if err := m.Update(head(size), leaves[33:]); err != nil {
+		t.Fatalf("Update(%d): %v", size, err)
+	}
+	for i, index := range seen {
+		if index != uint64(i) {
+			t.Fatalf("onEntry called for %v, want all indices in order", seen)
+		}
+	}
+	if got, want := len(seen), size; got != want {
+		t.Errorf("onEntry called %d times, want %d", got, want)
+	}
+}
+
+func TestMonitorErrors(t *testing.T) {
+	const size = 20
+	leaves := testonly.GenLeaves(2, 0, size)
+	tree := testonly.GenTree(hasher, 2, size)
+	head := func(size uint64) TreeHead {
+		return TreeHead{Size: size, RootHash: tree.HashAt(size)}
+	}
+	newMonitor := func(onEntry EntryFn, save SaveFn) *Monitor {
+		m, err := New(hasher, State{}, onEntry, save)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		if err := m.Update(head(10), leaves[:10]); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		return m
+	}
+
+	t.Run("split-view", func(t *testing.T) {
+		m := newMonitor(nil, nil)
+		bad := TreeHead{Size: 15, RootHash: tree.HashAt(14)}
+		err := m.Update(bad, leaves[10:15])
+		var mismatch proof.RootMismatchError
+		if !errors.As(err, &mismatch) {
+			t.Errorf("Update: got %v, want RootMismatchError", err)
+		}
+		if err := m.Update(head(15), leaves[10:15]); err != nil {
+			t.Errorf("Update after failure: %v", err)
+		}
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		m := newMonitor(nil, nil)
+		if err := m.Update(head(9), nil); err == nil {
+			t.Error("Update: want error for smaller tree head")
+		}
+	})
+
+	t.Run("missing-entries", func(t *testing.T) {
+		m := newMonitor(nil, nil)
+		if err := m.Update(head(15), leaves[10:14]); err == nil {
+			t.Error("Update: want error for missing entries")
+		}
+	})
+
+	t.Run("callback-errors", func(t *testing.T) {
+		var failEntry, failSave bool
+		delivered := map[uint64]int{}
+		m := newMonitor(func(index uint64, data []byte) error {
+			if index == 12 && failEntry {
+				return errors.New("entry failed")
+			}
+			delivered[index]++
+			return nil
+		}, func(State) error {
+			if failSave {
+				return errors.New("save failed")
+			}
+			return nil
+		})
+		for _, tc := range []struct {
+			failEntry, failSave bool
+			wantSize            uint64
+		}{
+			{failEntry: true, wantSize: 10},
+			{failSave: true, wantSize: 10},
+			{wantSize: 15},
+		} {
+			failEntry, failSave = tc.failEntry, tc.failSave
+			err := m.Update(head(15), leaves[10:15])
+			if got, want := err != nil, tc.wantSize == 10; got != want {
+				t.Fatalf("Update: %v, want error %v", err, want)
+			}
+			if got, want := m.State().Size, tc.wantSize; got != want {
+				t.Errorf("Size: got %d, want %d", got, want)
+			}
+		}
+		// The delivery is at-least-once: the entries before the failures are
+		// delivered again on each retry.
+		if diff := cmp.Diff(delivered, map[uint64]int{10: 3, 11: 3, 12: 2, 13: 2, 14: 2}, cmpopts.IgnoreMapEntries(func(index uint64, _ int) bool {
+			return index < 10
+		})); diff != "" {
+			t.Errorf("onEntry deliveries: diff(-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("state-copy", func(t *testing.T) {
+		m := newMonitor(nil, nil)
+		want := m.State()
+		state := m.State()
+		state.RootHash[0] ^= 1
+		state.Range[0][0] ^= 1
+		if diff := cmp.Diff(m.State(), want); diff != "" {
+			t.Errorf("State modified through a copy: diff(-got +want):\n%s", diff)
+		}
+	})
+
+	t.Run("bad-state", func(t *testing.T) {
+		state := newMonitor(nil, nil).State()
+		state.RootHash = tree.HashAt(9)
+		if _, err := New(hasher, state, nil, nil); err == nil {
+			t.Error("New: want error for wrong root hash")
+		}
+		state.Range = state.Range[1:]
+		if _, err := New(hasher, state, nil, nil); err == nil {
+			t.Error("New: want error for corrupt range")
+		}
+		if _, err := New(hasher, State{TreeHead: TreeHead{RootHash: []byte("root")}}, nil, nil); err == nil {
+			t.Error("New: want error for wrong empty root")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+)
+
+// Map is a verifiable key-value map with revisions, built on top of the prefix
+// Tree. The updates are staged with Set and Delete, and then committed as a
+// new revision. Each revision has a root hash, and supports proofs of
+// inclusion and absence of keys, and proofs of how the value of a key changed
+// between two revisions.
+//
+// Revision 0 is the empty map. The revisions share the unchanged tree nodes,
+// so each revision costs memory proportional to the number of updated keys. It
+// is not safe for concurrent use.
+type Map struct {
+	staged *Tree   // The tree with the staged updates.
+	revs   []*Tree // The committed revisions, indexed by revision number.
+}
+
+// NewMap returns an empty map which uses the given hasher, and keys of the
+// given size in bytes.
+func NewMap(hasher merkle.LogHasher, keySize int) (*Map, error) {
+	tree, err := New(hasher, keySize)
+	if err != nil {
+		return nil, err
+	}
+	return &Map{staged: tree.Clone(), revs: []*Tree{tree}}, nil
+}
+
+// Set stages setting the value of the given key in the next revision. The map
+// keeps references to the passed in slices, so they must not be modified.
+func (m *Map) Set(key, value []byte) error {
+	return m.staged.Set(key, value)
+}
+
+// Delete stages deleting the given key in the next revision.
+func (m *Map) Delete(key []byte) error {
+	return m.staged.Delete(key)
+}
+
+// Commit commits the staged updates as a new revision, and returns its number.
+// The new revision is created even if there are no staged updates.
+func (m *Map) Commit() uint64 {
+	m.revs = append(m.revs, m.staged.Clone())
+	return m.Revision()
+}
+
+// Revision returns the number of the latest committed revision.
+func (m *Map) Revision() uint64 {
+	return uint64(len(m.revs) - 1)
+}
+
+// Root returns the root hash of the given revision.
+func (m *Map) Root(rev uint64) ([]byte, error) {
+	tree, err := m.tree(rev)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Root(), nil
+}
+
+// Get returns the value of the given key in the given revision, and whether
+// it is present.
+func (m *Map) Get(rev uint64, key []byte) ([]byte, bool, error) {
+	tree, err := m.tree(rev)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found := tree.Get(key)
+	return value, found, nil
+}
+
+// Prove returns the proof of inclusion or absence of the given key in the
+// given revision.
+func (m *Map) Prove(rev uint64, key []byte) (Proof, error) {
+	tree, err := m.tree(rev)
+	if err != nil {
+		return Proof{}, err
+	}
+	return tree.Prove(key)
+}
+
+// ProveChange returns the proof of the values of the given key in the two
+// given revisions. See VerifyChange.
+func (m *Map) ProveChange(key []byte, rev1, rev2 uint64) (ChangeProof, error) {
+	p1, err := m.Prove(rev1, key)
+	if err != nil {
+		return ChangeProof{}, err
+	}
+	p2, err := m.Prove(rev2, key)
+	if err != nil {
+		return ChangeProof{}, err
+	}
+	return ChangeProof{Old: p1, New: p2}, nil
+}
+
+func (m *Map) tree(rev uint64) (*Tree, error) {
+	if rev > m.Revision() {
+		return nil, fmt.Errorf("revision %d not found, latest is %d", rev, m.Revision())
+	}
+	return m.revs[rev], nil
+}
+
+// ChangeProof is a proof of the values of a key in two map revisions.
+type ChangeProof struct {
+	Old, New Proof // The proofs for the key in the two revisions.
+}
+
+// VerifyChange verifies that the value of the key differs between the two map
+// revisions with the given root hashes, i.e. the key is added, deleted, or set
+// to another value. The values can then be obtained with Proof.Value.
+func VerifyChange(hasher merkle.LogHasher, key, root1, root2 []byte, p ChangeProof) error {
+	if err := verify(hasher, root1, key, p.Old); err != nil {
+		return fmt.Errorf("old revision: %v", err)
+	}
+	if err := verify(hasher, root2, key, p.New); err != nil {
+		return fmt.Errorf("new revision: %v", err)
+	}
+	value1, found1 := p.Old.Value(key)
+	value2, found2 := p.New.Value(key)
+	if found1 == found2 && bytes.Equal(value1, value2) {
+		return errors.New("value did not change")
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefix
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMap(t *testing.T) {
+	const keySize = 2
+	m, err := NewMap(hasher, keySize)
+	if err != nil {
+		t.Fatalf("NewMap: %v", err)
+	}
+	// The expected contents of each revision.
+	revs := []map[string][]byte{{}}
+	cur := map[string][]byte{}
+	for rev := 1; rev <= 10; rev++ {
+		for i := 0; i < 30; i++ {
+			key := genKey(rev*7+i*3, keySize)
+			if i%4 == 3 {
+				if err := m.Delete(key); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+				delete(cur, string(key))
+			} else {
+				value := []byte(fmt.Sprintf("value %d", rev))
+				if err := m.Set(key, value); err != nil {
+					t.Fatalf("Set: %v", err)
+				}
+				cur[string(key)] = value
+			}
+		}
+		if got, want := m.Commit(), uint64(rev); got != want {
+			t.Fatalf("Commit: got revision %d, want %d", got, want)
+		}
+		snapshot := make(map[string][]byte, len(cur))
+		for k, v := range cur {
+			snapshot[k] = v
+		}
+		revs = append(revs, snapshot)
+	}
+	// Staged updates do not affect the committed revisions.
+	if err := m.Set(genKey(1000, keySize), []byte("staged")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	roots := make([][]byte, len(revs))
+	for rev, contents := range revs {
+		// Each revision has the same root as the tree built from scratch.
+		tree := newTree(t, keySize, nil)
+		for k, v := range contents {
+			if err := tree.Set([]byte(k), v); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+		}
+		root, err := m.Root(uint64(rev))
+		if err != nil {
+			t.Fatalf("Root(%d): %v", rev, err)
+		}
+		if diff := cmp.Diff(root, tree.Root()); diff != "" {
+			t.Errorf("Root(%d): diff(-got +want):\n%s", rev, diff)
+		}
+		roots[rev] = root
+
+		for i := 0; i < 100; i++ {
+			key := genKey(i, keySize)
+			want, wantFound := contents[string(key)]
+			value, found, err := m.Get(uint64(rev), key)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			if found != wantFound || !bytes.Equal(value, want) {
+				t.Errorf("Get(%d, %x): got %q, %v; want %q, %v", rev, key, value, found, want, wantFound)
+			}
+			p, err := m.Prove(uint64(rev), key)
+			if err != nil {
+				t.Fatalf("Prove: %v", err)
+			}
+			if wantFound {
+				err = VerifyInclusion(hasher, root, key, want, p)
+			} else {
+				err = VerifyAbsence(hasher, root, key, p)
+			}
+			if err != nil {
+				t.Errorf("Verify(%d, %x): %v", rev, key, err)
+			}
+		}
+	}
+
+	for rev1 := range revs {
+		for rev2 := range revs {
+			for i := 0; i < 100; i++ {
+				key := genKey(i, keySize)
+				p, err := m.ProveChange(key, uint64(rev1), uint64(rev2))
+				if err != nil {
+					t.Fatalf("ProveChange: %v", err)
+				}
+				value1, found1 := revs[rev1][string(key)]
+				value2, found2 := revs[rev2][string(key)]
+				changed := found1 != found2 || !bytes.Equal(value1, value2)
+				err = VerifyChange(hasher, key, roots[rev1], roots[rev2], p)
+				if got, want := err == nil, changed; got != want {
+					t.Fatalf("VerifyChange(%x, %d, %d): %v, want changed %v", key, rev1, rev2, err, want)
+				}
+				if !changed {
+					continue
+				}
+				if value, found := p.New.Value(key); found != found2 || !bytes.Equal(value, value2) {
+					t.Errorf("Value(%x): got %q, %v; want %q, %v", key, value, found, value2, found2)
+				}
+				if err := VerifyChange(hasher, key, roots[rev2], roots[rev1], p); err == nil {
+					t.Errorf("VerifyChange(%x, %d, %d): want error for swapped roots", key, rev1, rev2)
+				}
+			}
+		}
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	m, err := NewMap(hasher, 4)
+	if err != nil {
+		t.Fatalf("NewMap: %v", err)
+	}
+	if _, err := NewMap(hasher, 0); err == nil {
+		t.Error("NewMap: want error for zero key size")
+	}
+	if err := m.Set(genKey(0, 3), nil); err == nil {
+		t.Error("Set: want error for wrong key size")
+	}
+	if err := m.Delete(genKey(0, 5)); err == nil {
+		t.Error("Delete: want error for wrong key size")
+	}
+	if _, err := m.Root(1); err == nil {
+		t.Error("Root: want error for uncommitted revision")
+	}
+	if _, _, err := m.Get(1, genKey(0, 4)); err == nil {
+		t.Error("Get: want error for uncommitted revision")
+	}
+	if _, err := m.ProveChange(genKey(0, 4), 0, 1); err == nil {
+		t.Error("ProveChange: want error for uncommitted revision")
+	}
+}
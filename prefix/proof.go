@@ -0,0 +1,112 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefix
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+)
+
+// Proof is a proof of inclusion or absence of a key in the prefix tree. The
+// path of the key from the root ends in either a leaf or an empty node. The key
+// is present iff this is a leaf with the same key.
+type Proof struct {
+	// Siblings contains the hashes of the siblings of the nodes on the path,
+	// ordered from lower to upper levels. Its length is the depth at which the
+	// path ends.
+	Siblings [][]byte
+	// LeafKey and LeafValue are the key and value of the leaf in which the path
+	// ends. LeafKey is nil if the path ends in an empty node.
+	LeafKey, LeafValue []byte
+}
+
+// Value returns the value of the given key in the tree that the proof is for,
+// and whether the key is present. The result is only meaningful after the
+// proof is verified for this key.
+func (p Proof) Value(key []byte) ([]byte, bool) {
+	if p.LeafKey == nil || !bytes.Equal(p.LeafKey, key) {
+		return nil, false
+	}
+	return p.LeafValue, true
+}
+
+// VerifyInclusion verifies that the key with the given value is present in
+// the tree with the given root hash.
+func VerifyInclusion(hasher merkle.LogHasher, root, key, value []byte, p Proof) error {
+	if !bytes.Equal(p.LeafKey, key) {
+		return errors.New("proof is for another key")
+	} else if !bytes.Equal(p.LeafValue, value) {
+		return errors.New("proof is for another value")
+	}
+	return verify(hasher, root, key, p)
+}
+
+// VerifyAbsence verifies that the key is not present in the tree with the
+// given root hash.
+func VerifyAbsence(hasher merkle.LogHasher, root, key []byte, p Proof) error {
+	if bytes.Equal(p.LeafKey, key) {
+		return errors.New("proof shows the key is present")
+	}
+	return verify(hasher, root, key, p)
+}
+
+// RootFromProof calculates the root hash of the tree from the given proof for
+// the given key.
+func RootFromProof(hasher merkle.LogHasher, key []byte, p Proof) ([]byte, error) {
+	if l := len(key); l == 0 || l > maxKeySize {
+		return nil, fmt.Errorf("key size %d out of range [1, %d]", l, maxKeySize)
+	}
+	depth := len(p.Siblings)
+	if max := 8 * len(key); depth > max {
+		return nil, fmt.Errorf("proof has %d hashes, want at most %d", depth, max)
+	}
+	var hash []byte
+	if p.LeafKey == nil {
+		hash = emptyHash(hasher, key, depth)
+	} else {
+		if got, want := len(p.LeafKey), len(key); got != want {
+			return nil, fmt.Errorf("leaf key has size %d, want %d", got, want)
+		}
+		// The leaf must be on the path of the key.
+		for i := 0; i < depth; i++ {
+			if bit(p.LeafKey, i) != bit(key, i) {
+				return nil, fmt.Errorf("leaf key diverges from the key at bit %d", i)
+			}
+		}
+		hash = leafHash(hasher, p.LeafKey, p.LeafValue, depth)
+	}
+	for i, sibling := range p.Siblings {
+		if bit(key, depth-1-i) == 0 {
+			hash = hasher.HashChildren(hash, sibling)
+		} else {
+			hash = hasher.HashChildren(sibling, hash)
+		}
+	}
+	return hash, nil
+}
+
+func verify(hasher merkle.LogHasher, root, key []byte, p Proof) error {
+	hash, err := RootFromProof(hasher, key, p)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, root) {
+		return fmt.Errorf("calculated root %x does not match expected root %x", hash, root)
+	}
+	return nil
+}
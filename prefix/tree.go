@@ -0,0 +1,317 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prefix implements a compressed binary prefix tree, which maps
+// fixed-size keys to values, and supports proofs of inclusion and absence of
+// keys. It follows the design of the CONIKS prefix tree.
+//
+// Each key corresponds to the path from the root, in which the i-th step goes
+// to the left or right child depending on the i-th bit of the key, starting
+// from the most significant bit of the first byte. Unlike a full sparse Merkle
+// tree, each leaf is stored at the shortest prefix of its key which no other
+// key shares, and the subtrees without keys are represented by empty nodes.
+// Therefore, the proofs have about log2(N) hashes for N keys, regardless of
+// the key size.
+//
+// The node hashes are computed as follows:
+//   - leaf: HashLeaf(0x01 || key || depth || value)
+//   - empty node: HashLeaf(0x00 || prefix || depth)
+//   - interior node: HashChildren(left, right)
+//
+// where depth is the 2-byte big-endian number of bits in the node's prefix,
+// and prefix is the key size bytes containing the node's prefix bits, with all
+// the other bits set to zero. The root of an empty tree is the hash of the
+// empty node with a zero-length prefix.
+//
+// The Map type builds a verifiable key-value map with revisions on top of the
+// Tree, e.g. for key transparency applications.
+package prefix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+)
+
+const (
+	emptyTag = 0
+	leafTag  = 1
+	// maxKeySize is the maximal key size for which the depth fits 2 bytes.
+	maxKeySize = (1<<16 - 1) / 8
+)
+
+// node is a node of the prefix tree. A nil node is an empty node.
+type node struct {
+	// For leaves, the key and the value. The key is nil for interior nodes.
+	key, value []byte
+	// For interior nodes, the children.
+	left, right *node
+	// hash is the cached hash of the node, or nil if it needs recomputing.
+	hash []byte
+}
+
+// Tree is a compressed binary prefix tree. It is not safe for concurrent use.
+//
+// The nodes are never modified once added to the tree, except for caching
+// their hashes: the updates copy the nodes on the path to the updated key.
+// This allows cloning the tree cheaply, see Clone.
+type Tree struct {
+	hasher  merkle.LogHasher
+	keySize int
+	root    *node
+}
+
+// New returns an empty tree which uses the given hasher, and keys of the given
+// size in bytes.
+func New(hasher merkle.LogHasher, keySize int) (*Tree, error) {
+	if keySize <= 0 || keySize > maxKeySize {
+		return nil, fmt.Errorf("key size %d out of range [1, %d]", keySize, maxKeySize)
+	}
+	return &Tree{hasher: hasher, keySize: keySize}, nil
+}
+
+// Clone returns a copy of the tree. It takes constant time, because the two
+// trees share the nodes until either of them is updated. The copies must not
+// be used concurrently, because they share the cached node hashes.
+func (t *Tree) Clone() *Tree {
+	c := *t
+	return &c
+}
+
+// Get returns the value of the given key, and whether it is present. A key of
+// a wrong size is never present.
+func (t *Tree) Get(key []byte) ([]byte, bool) {
+	if t.checkKey(key) != nil {
+		return nil, false
+	}
+	n := t.root
+	for depth := 0; n != nil && n.key == nil; depth++ {
+		n = n.child(bit(key, depth))
+	}
+	if n == nil || !bytes.Equal(n.key, key) {
+		return nil, false
+	}
+	return n.value, true
+}
+
+// Set sets the value of the given key. The tree keeps references to the passed
+// in slices, so they must not be modified.
+func (t *Tree) Set(key, value []byte) error {
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	t.root = set(t.root, 0, &node{key: key, value: value})
+	return nil
+}
+
+// Delete deletes the given key from the tree, if it is present.
+func (t *Tree) Delete(key []byte) error {
+	if err := t.checkKey(key); err != nil {
+		return err
+	}
+	t.root, _ = del(t.root, 0, key)
+	return nil
+}
+
+// Root returns the root hash of the tree.
+func (t *Tree) Root() []byte {
+	return t.hashNode(t.root, 0, make([]byte, t.keySize))
+}
+
+// Prove returns the proof of inclusion or absence of the given key.
+func (t *Tree) Prove(key []byte) (Proof, error) {
+	if err := t.checkKey(key); err != nil {
+		return Proof{}, err
+	}
+	var siblings [][]byte
+	n := t.root
+	for depth := 0; n != nil && n.key == nil; depth++ {
+		b := bit(key, depth)
+		sibling := withBit(key, depth, 1-b)
+		siblings = append(siblings, t.hashNode(n.child(1-b), depth+1, sibling))
+		n = n.child(b)
+	}
+	reverse(siblings)
+	p := Proof{Siblings: siblings}
+	if n != nil {
+		p.LeafKey, p.LeafValue = n.key, n.value
+	}
+	return p, nil
+}
+
+func (t *Tree) checkKey(key []byte) error {
+	if got, want := len(key), t.keySize; got != want {
+		return fmt.Errorf("key has size %d, want %d", got, want)
+	}
+	return nil
+}
+
+// hashNode returns the hash of the given node at the given depth. The prefix
+// is any key which has the node's prefix.
+func (t *Tree) hashNode(n *node, depth int, prefix []byte) []byte {
+	if n == nil {
+		return emptyHash(t.hasher, prefix, depth)
+	} else if n.hash != nil {
+		return n.hash
+	}
+	if n.key != nil {
+		n.hash = leafHash(t.hasher, n.key, n.value, depth)
+	} else {
+		left := t.hashNode(n.left, depth+1, withBit(prefix, depth, 0))
+		right := t.hashNode(n.right, depth+1, withBit(prefix, depth, 1))
+		n.hash = t.hasher.HashChildren(left, right)
+	}
+	return n.hash
+}
+
+// set inserts the given leaf into the subtree rooted at the given node at the
+// given depth, and returns the new root of this subtree. The existing nodes are
+// not modified, the changed ones are copied.
+func set(n *node, depth int, leaf *node) *node {
+	if n == nil {
+		return leaf
+	}
+	if n.key == nil { // Interior node.
+		b := bit(leaf.key, depth)
+		return n.with(b, set(n.child(b), depth+1, leaf))
+	}
+	if bytes.Equal(n.key, leaf.key) {
+		return leaf
+	}
+	// Two different leaves share the prefix: push both of them down to the
+	// depth at which their keys diverge.
+	return split(n.moved(), leaf, depth)
+}
+
+// split returns the subtree at the given depth containing the two leaves.
+func split(a, b *node, depth int) *node {
+	res := &node{}
+	ba, bb := bit(a.key, depth), bit(b.key, depth)
+	switch {
+	case ba != bb:
+		res.setChild(ba, a)
+		res.setChild(bb, b)
+	default:
+		res.setChild(ba, split(a, b, depth+1))
+	}
+	return res
+}
+
+// del deletes the given key from the subtree rooted at the given node at the
+// given depth, and returns the new root of this subtree, and whether the key
+// was found. The existing nodes are not modified, the changed ones are copied.
+func del(n *node, depth int, key []byte) (*node, bool) {
+	if n == nil {
+		return nil, false
+	} else if n.key != nil {
+		if bytes.Equal(n.key, key) {
+			return nil, true
+		}
+		return n, false
+	}
+	b := bit(key, depth)
+	child, found := del(n.child(b), depth+1, key)
+	if !found {
+		return n, false
+	}
+	// A leaf with no siblings moves up to the shortest unique prefix.
+	other := n.child(1 - b)
+	if child == nil && (other == nil || other.key != nil) {
+		if other != nil {
+			other = other.moved()
+		}
+		return other, true
+	} else if other == nil && child.key != nil {
+		return child.moved(), true
+	}
+	return n.with(b, child), true
+}
+
+func (n *node) child(b int) *node {
+	if b == 0 {
+		return n.left
+	}
+	return n.right
+}
+
+// with returns a copy of the interior node, with the given child replaced.
+func (n *node) with(b int, child *node) *node {
+	c := &node{left: n.left, right: n.right}
+	c.setChild(b, child)
+	return c
+}
+
+// moved returns a copy of the leaf, for placing it at another depth, which
+// changes its hash.
+func (n *node) moved() *node {
+	return &node{key: n.key, value: n.value}
+}
+
+func (n *node) setChild(b int, child *node) {
+	if b == 0 {
+		n.left = child
+	} else {
+		n.right = child
+	}
+}
+
+// bit returns the bit of the key at the given depth.
+func bit(key []byte, depth int) int {
+	return int(key[depth/8]>>(7-depth%8)) & 1
+}
+
+// withBit returns a copy of the key with the bit at the given depth set to b.
+func withBit(key []byte, depth, b int) []byte {
+	res := append([]byte(nil), key...)
+	mask := byte(1) << (7 - depth%8)
+	if b == 0 {
+		res[depth/8] &^= mask
+	} else {
+		res[depth/8] |= mask
+	}
+	return res
+}
+
+// leafHash returns the hash of the leaf with the given key and value located
+// at the given depth.
+func leafHash(hasher merkle.LogHasher, key, value []byte, depth int) []byte {
+	data := make([]byte, 0, 1+len(key)+2+len(value))
+	data = append(data, leafTag)
+	data = append(data, key...)
+	data = append(data, byte(depth>>8), byte(depth))
+	data = append(data, value...)
+	return hasher.HashLeaf(data)
+}
+
+// emptyHash returns the hash of the empty node at the given depth, which has
+// the same prefix as the given key.
+func emptyHash(hasher merkle.LogHasher, key []byte, depth int) []byte {
+	data := make([]byte, 1+len(key)+2)
+	data[0] = emptyTag
+	prefix := data[1 : 1+len(key)]
+	copy(prefix, key[:(depth+7)/8])
+	if rem := depth % 8; rem != 0 {
+		prefix[depth/8] &= ^byte(0) << (8 - rem)
+	}
+	binary.BigEndian.PutUint16(data[1+len(key):], uint16(depth))
+	return hasher.HashLeaf(data)
+}
+
+func reverse(hashes [][]byte) {
+	for i, j := 0, len(hashes)-1; i < j; i, j = i+1, j-1 {
+		hashes[i], hashes[j] = hashes[j], hashes[i]
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prefix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+// genKey returns the test key number i of the given size, up to 32 bytes.
+func genKey(i, size int) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(i))
+	sum := sha256.Sum256(b[:])
+	return sum[:size]
+}
+
+func newTree(t *testing.T, keySize int, keys [][]byte) *Tree {
+	t.Helper()
+	tree, err := New(hasher, keySize)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, key := range keys {
+		if err := tree.Set(key, []byte(fmt.Sprintf("value %x", key))); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+	}
+	return tree
+}
+
+func TestTree(t *testing.T) {
+	for _, keySize := range []int{1, 2, 32} {
+		t.Run(fmt.Sprintf("key-size:%d", keySize), func(t *testing.T) {
+			const numKeys = 100
+			var keys, absent [][]byte
+			seen := make(map[string]bool)
+			for i := 0; len(keys) < numKeys && i < 300; i++ {
+				key := genKey(i, keySize)
+				if seen[string(key)] {
+					continue // Short keys can repeat.
+				}
+				seen[string(key)] = true
+				if i%3 == 2 {
+					absent = append(absent, key)
+				} else {
+					keys = append(keys, key)
+				}
+			}
+			tree := newTree(t, keySize, keys)
+			root := tree.Root()
+
+			// The root does not depend on the order of insertions.
+			reversed := make([][]byte, 0, len(keys))
+			for i := len(keys) - 1; i >= 0; i-- {
+				reversed = append(reversed, keys[i])
+			}
+			if diff := cmp.Diff(newTree(t, keySize, reversed).Root(), root); diff != "" {
+				t.Errorf("Root: diff(-got +want):\n%s", diff)
+			}
+
+			for _, key := range keys {
+				value, found := tree.Get(key)
+				if want := []byte(fmt.Sprintf("value %x", key)); !found || !bytes.Equal(value, want) {
+					t.Fatalf("Get(%x): got %q, %v; want %q", key, value, found, want)
+				}
+				p, err := tree.Prove(key)
+				if err != nil {
+					t.Fatalf("Prove: %v", err)
+				}
+				if err := VerifyInclusion(hasher, root, key, value, p); err != nil {
+					t.Errorf("VerifyInclusion(%x): %v", key, err)
+				}
+				if err := VerifyInclusion(hasher, root, key, []byte("wrong"), p); err == nil {
+					t.Errorf("VerifyInclusion(%x): want error for wrong value", key)
+				}
+				if err := VerifyAbsence(hasher, root, key, p); err == nil {
+					t.Errorf("VerifyAbsence(%x): want error", key)
+				}
+			}
+			for _, key := range absent {
+				if _, found := tree.Get(key); found {
+					t.Fatalf("Get(%x): want not found", key)
+				}
+				p, err := tree.Prove(key)
+				if err != nil {
+					t.Fatalf("Prove: %v", err)
+				}
+				if err := VerifyAbsence(hasher, root, key, p); err != nil {
+					t.Errorf("VerifyAbsence(%x): %v", key, err)
+				}
+				if err := VerifyInclusion(hasher, root, key, nil, p); err == nil {
+					t.Errorf("VerifyInclusion(%x): want error", key)
+				}
+			}
+
+			// Deleting keys results in the same tree as never inserting them.
+			for i, key := range keys {
+				if i%2 == 0 {
+					if err := tree.Delete(key); err != nil {
+						t.Fatalf("Delete: %v", err)
+					}
+				}
+			}
+			for _, key := range absent {
+				if err := tree.Delete(key); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			}
+			var rest [][]byte
+			for i := 1; i < len(keys); i += 2 {
+				rest = append(rest, keys[i])
+			}
+			if diff := cmp.Diff(tree.Root(), newTree(t, keySize, rest).Root()); diff != "" {
+				t.Errorf("Root after Delete: diff(-got +want):\n%s", diff)
+			}
+			for _, key := range rest {
+				if err := tree.Delete(key); err != nil {
+					t.Fatalf("Delete: %v", err)
+				}
+			}
+			if diff := cmp.Diff(tree.Root(), newTree(t, keySize, nil).Root()); diff != "" {
+				t.Errorf("Root of emptied tree: diff(-got +want):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	keys := [][]byte{genKey(0, 4), genKey(1, 4), genKey(2, 4)}
+	tree := newTree(t, 4, keys)
+	root := tree.Root()
+	if err := tree.Set(keys[1], []byte("new value")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if bytes.Equal(tree.Root(), root) {
+		t.Error("Root: not changed after update")
+	}
+	p, err := tree.Prove(keys[1])
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+	if err := VerifyInclusion(hasher, tree.Root(), keys[1], []byte("new value"), p); err != nil {
+		t.Errorf("VerifyInclusion: %v", err)
+	}
+	if err := VerifyInclusion(hasher, root, keys[1], []byte("new value"), p); err == nil {
+		t.Error("VerifyInclusion: want error for old root")
+	}
+}
+
+func TestClone(t *testing.T) {
+	keys := [][]byte{genKey(0, 4), genKey(1, 4), genKey(2, 4), genKey(3, 4)}
+	tree := newTree(t, 4, keys[:3])
+	root := tree.Root()
+	clone := tree.Clone()
+	// Updates of either tree do not affect the other one.
+	if err := tree.Set(keys[3], []byte("new")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := tree.Delete(keys[0]); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := clone.Set(keys[1], []byte("changed")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := clone.Set(keys[1], []byte(fmt.Sprintf("value %x", keys[1]))); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if diff := cmp.Diff(clone.Root(), root); diff != "" {
+		t.Errorf("Root of clone: diff(-got +want):\n%s", diff)
+	}
+	want := newTree(t, 4, keys[1:3])
+	if err := want.Set(keys[3], []byte("new")); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if diff := cmp.Diff(tree.Root(), want.Root()); diff != "" {
+		t.Errorf("Root: diff(-got +want):\n%s", diff)
+	}
+}
+
+func TestEmptyTree(t *testing.T) {
+	tree := newTree(t, 8, nil)
+	key := genKey(0, 8)
+	p, err := tree.Prove(key)
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+	if err := VerifyAbsence(hasher, tree.Root(), key, p); err != nil {
+		t.Errorf("VerifyAbsence: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for _, size := range []int{0, -1, maxKeySize + 1} {
+		if _, err := New(hasher, size); err == nil {
+			t.Errorf("New(%d): want error", size)
+		}
+	}
+	tree := newTree(t, 4, [][]byte{genKey(0, 4), genKey(1, 4)})
+	if err := tree.Set(genKey(2, 3), nil); err == nil {
+		t.Error("Set: want error for wrong key size")
+	}
+	if err := tree.Delete(genKey(2, 5)); err == nil {
+		t.Error("Delete: want error for wrong key size")
+	}
+	if _, err := tree.Prove(nil); err == nil {
+		t.Error("Prove: want error for wrong key size")
+	}
+	for _, key := range [][]byte{nil, genKey(0, 3), genKey(0, 5)} {
+		if _, found := tree.Get(key); found {
+			t.Errorf("Get(%x): want not found for wrong key size", key)
+		}
+	}
+
+	key := genKey(0, 4)
+	p, err := tree.Prove(key)
+	if err != nil {
+		t.Fatalf("Prove: %v", err)
+	}
+	root := tree.Root()
+	for _, tc := range []struct {
+		desc string
+		p    Proof
+	}{
+		{desc: "no-siblings", p: Proof{LeafKey: p.LeafKey, LeafValue: p.LeafValue}},
+		{desc: "extra-sibling", p: Proof{Siblings: append(p.Siblings, root), LeafKey: p.LeafKey, LeafValue: p.LeafValue}},
+		{desc: "wrong-sibling", p: Proof{Siblings: [][]byte{root}, LeafKey: p.LeafKey, LeafValue: p.LeafValue}},
+		{desc: "too-long", p: Proof{Siblings: make([][]byte, 33), LeafKey: p.LeafKey, LeafValue: p.LeafValue}},
+	} {
+		if err := VerifyInclusion(hasher, root, key, p.LeafValue, tc.p); err == nil {
+			t.Errorf("VerifyInclusion(%s): want error", tc.desc)
+		}
+	}
+	// A leaf which is not on the path of the key does not prove its absence.
+	other := genKey(1, 4)
+	if err := VerifyAbsence(hasher, root, other, p); err == nil {
+		t.Error("VerifyAbsence: want error for a leaf off the path")
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+)
+
+// Checkpoint is a log tree head. The caller is responsible for checking its
+// authenticity, e.g. the log's signature, before trusting it.
+type Checkpoint struct {
+	Size     uint64 `json:"size"`
+	RootHash []byte `json:"root_hash"`
+}
+
+// Bundle is a self-contained proof that a leaf is included into a log, which
+// can be verified offline, e.g. in an air-gapped environment. It can be
+// encoded as JSON.
+type Bundle struct {
+	// Checkpoint is the tree head which the leaf is included into.
+	Checkpoint Checkpoint `json:"checkpoint"`
+	// Index is the index of the leaf.
+	Index uint64 `json:"index"`
+	// LeafData is the data of the leaf. Can be nil if LeafHash is set. Note
+	// that an empty leaf is non-nil, and encoded as an empty string.
+	LeafData []byte `json:"leaf_data"`
+	// LeafHash is the hash of the leaf. Can be omitted if LeafData is set.
+	LeafHash []byte `json:"leaf_hash,omitempty"`
+	// Inclusion is the inclusion proof of the leaf into the Checkpoint tree.
+	Inclusion [][]byte `json:"inclusion"`
+	// Consistency is the optional consistency proof between the pinned
+	// checkpoint of the verifier and the Checkpoint.
+	Consistency [][]byte `json:"consistency,omitempty"`
+}
+
+// VerifyBundle verifies that the leaf in the bundle is included into the
+// bundle's checkpoint. If the pinned checkpoint is not nil, it also verifies
+// that the bundle's checkpoint is consistent with it, i.e. the log has not
+// forked since the pinned checkpoint was observed. The pinned checkpoint must
+// not be bigger than the bundle's one.
+//
+// If both the leaf data and hash are set, they must match.
+func VerifyBundle(hasher merkle.LogHasher, b *Bundle, pinned *Checkpoint) error {
+	leafHash := b.LeafHash
+	if b.LeafData != nil {
+		leafHash = hasher.HashLeaf(b.LeafData)
+		if b.LeafHash != nil && !bytes.Equal(leafHash, b.LeafHash) {
+			return errors.New("leaf hash does not match leaf data")
+		}
+	} else if leafHash == nil {
+		return errors.New("no leaf data or hash")
+	}
+
+	cp := b.Checkpoint
+	if err := VerifyInclusion(hasher, b.Index, cp.Size, leafHash, b.Inclusion, cp.RootHash); err != nil {
+		return fmt.Errorf("inclusion: %w", err)
+	}
+	if pinned == nil {
+		if len(b.Consistency) != 0 {
+			return errors.New("consistency proof without pinned checkpoint")
+		}
+		return nil
+	}
+	if pinned.Size > cp.Size {
+		return fmt.Errorf("pinned checkpoint size %d > %d", pinned.Size, cp.Size)
+	}
+	if err := VerifyConsistency(hasher, pinned.Size, cp.Size, b.Consistency, pinned.RootHash, cp.RootHash); err != nil {
+		return fmt.Errorf("consistency: %w", err)
+	}
+	return nil
+}
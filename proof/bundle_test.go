@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestVerifyBundle(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := newTree(20)
+	pinned := &proof.Checkpoint{Size: 13, RootHash: tree.HashAt(13)}
+	checkpoint := proof.Checkpoint{Size: 20, RootHash: tree.Hash()}
+	inclusion, err := tree.InclusionProof(15, 20)
+	if err != nil {
+		t.Fatalf("InclusionProof: %v", err)
+	}
+	consistency, err := tree.ConsistencyProof(13, 20)
+	if err != nil {
+		t.Fatalf("ConsistencyProof: %v", err)
+	}
+	leaf := []byte("leaf: 15")
+	bundle := func(edit func(b *proof.Bundle)) *proof.Bundle {
+		b := &proof.Bundle{
+			Checkpoint:  checkpoint,
+			Index:       15,
+			LeafData:    leaf,
+			Inclusion:   inclusion,
+			Consistency: consistency,
+		}
+		edit(b)
+		return b
+	}
+
+	for _, tc := range []struct {
+		desc    string
+		b       *proof.Bundle
+		pinned  *proof.Checkpoint
+		wantErr bool
+	}{
+		{desc: "ok", b: bundle(func(*proof.Bundle) {}), pinned: pinned},
+		{desc: "leaf-hash", b: bundle(func(b *proof.Bundle) { b.LeafData, b.LeafHash = nil, hasher.HashLeaf(leaf) }), pinned: pinned},
+		{desc: "leaf-data-and-hash", b: bundle(func(b *proof.Bundle) { b.LeafHash = hasher.HashLeaf(leaf) }), pinned: pinned},
+		{desc: "not-pinned", b: bundle(func(b *proof.Bundle) { b.Consistency = nil })},
+		{desc: "same-size", b: bundle(func(b *proof.Bundle) { b.Consistency = nil }), pinned: &checkpoint},
+		{desc: "empty-leaf", b: &proof.Bundle{Checkpoint: proof.Checkpoint{Size: 1, RootHash: hasher.HashLeaf(nil)}, LeafData: []byte{}}},
+		{desc: "no-leaf", b: bundle(func(b *proof.Bundle) { b.LeafData = nil }), pinned: pinned, wantErr: true},
+		{desc: "leaf-mismatch", b: bundle(func(b *proof.Bundle) { b.LeafHash = hasher.HashLeaf(nil) }), pinned: pinned, wantErr: true},
+		{desc: "wrong-leaf", b: bundle(func(b *proof.Bundle) { b.LeafData = []byte("leaf: 14") }), pinned: pinned, wantErr: true},
+		{desc: "wrong-index", b: bundle(func(b *proof.Bundle) { b.Index = 14 }), pinned: pinned, wantErr: true},
+		{desc: "unused-consistency", b: bundle(func(*proof.Bundle) {}), wantErr: true},
+		{desc: "no-consistency", b: bundle(func(b *proof.Bundle) { b.Consistency = nil }), pinned: pinned, wantErr: true},
+		{desc: "wrong-pinned", b: bundle(func(*proof.Bundle) {}), pinned: &proof.Checkpoint{Size: 13, RootHash: tree.HashAt(12)}, wantErr: true},
+		{desc: "newer-pinned", b: bundle(func(*proof.Bundle) {}), pinned: &proof.Checkpoint{Size: 21}, wantErr: true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			// The bundle survives a JSON round trip.
+			data, err := json.Marshal(tc.b)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var b proof.Bundle
+			if err := json.Unmarshal(data, &b); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if diff := cmp.Diff(&b, tc.b); diff != "" {
+				t.Errorf("JSON round trip: diff(-got +want):\n%s", diff)
+			}
+			err = proof.VerifyBundle(hasher, &b, tc.pinned)
+			if got, want := err != nil, tc.wantErr; got != want {
+				t.Errorf("VerifyBundle: %v, wantErr %v", err, want)
+			}
+		})
+	}
+}
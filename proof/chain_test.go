@@ -0,0 +1,100 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+// newTree returns a test tree of the given size.
+func newTree(size uint64) *testonly.Tree {
+	tree := testonly.New(rfc6962.DefaultHasher)
+	for i := uint64(0); i < size; i++ {
+		tree.AppendData([]byte(fmt.Sprintf("leaf: %d", i)))
+	}
+	return tree
+}
+
+func TestVerifyConsistencyChain(t *testing.T) {
+	tree := newTree(100)
+	chain := func(sizes ...uint64) ([][]byte, [][][]byte) {
+		roots := make([][]byte, 0, len(sizes))
+		var proofs [][][]byte
+		for i, size := range sizes {
+			roots = append(roots, tree.HashAt(size))
+			if i == 0 {
+				continue
+			}
+			p, err := tree.ConsistencyProof(sizes[i-1], size)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			proofs = append(proofs, p)
+		}
+		return roots, proofs
+	}
+
+	for _, sizes := range [][]uint64{
+		nil,
+		{10},
+		{0, 1},
+		{1, 2, 3, 7, 7, 8, 16, 21, 100},
+		{0, 0, 5, 64, 99},
+	} {
+		t.Run(fmt.Sprintf("%v", sizes), func(t *testing.T) {
+			roots, proofs := chain(sizes...)
+			if err := proof.VerifyConsistencyChain(rfc6962.DefaultHasher, sizes, roots, proofs); err != nil {
+				t.Fatalf("VerifyConsistencyChain: %v", err)
+			}
+		})
+	}
+
+	t.Run("bad-root", func(t *testing.T) {
+		sizes := []uint64{3, 7, 20, 55}
+		roots, proofs := chain(sizes...)
+		roots[2] = roots[1]
+		err := proof.VerifyConsistencyChain(rfc6962.DefaultHasher, sizes, roots, proofs)
+		var chainErr *proof.ChainError
+		if !errors.As(err, &chainErr) {
+			t.Fatalf("VerifyConsistencyChain: got %v, want ChainError", err)
+		}
+		if got, want := chainErr.Index, 1; got != want {
+			t.Errorf("Index: got %d, want %d", got, want)
+		}
+		if got, want := chainErr.Size2, sizes[2]; got != want {
+			t.Errorf("Size2: got %d, want %d", got, want)
+		}
+	})
+
+	t.Run("bad-args", func(t *testing.T) {
+		sizes := []uint64{3, 7, 20}
+		roots, proofs := chain(sizes...)
+		if err := proof.VerifyConsistencyChain(rfc6962.DefaultHasher, sizes, roots[1:], proofs); err == nil {
+			t.Error("accepted too few roots")
+		}
+		if err := proof.VerifyConsistencyChain(rfc6962.DefaultHasher, sizes, roots, proofs[1:]); err == nil {
+			t.Error("accepted too few proofs")
+		}
+		if err := proof.VerifyConsistencyChain(rfc6962.DefaultHasher, nil, nil, proofs); err == nil {
+			t.Error("accepted proofs for empty chain")
+		}
+	})
+}
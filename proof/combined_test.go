@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestVerifyInclusionAndConsistency(t *testing.T) {
+	const size = 40
+	tree := newTree(size)
+	hasher := rfc6962.DefaultHasher
+	for size1 := uint64(1); size1 <= size; size1++ {
+		for size2 := size1; size2 <= size; size2++ {
+			root2 := tree.HashAt(size2)
+			for index := uint64(0); index < size1; index++ {
+				p, err := tree.CombinedProof(index, size1, size2)
+				if err != nil {
+					t.Fatalf("CombinedProof(%d, %d, %d): %v", index, size1, size2, err)
+				}
+				leafHash := tree.LeafHash(index)
+				root1, err := proof.VerifyInclusionAndConsistency(hasher, index, size1, size2, leafHash, p, root2)
+				if err != nil {
+					t.Fatalf("VerifyInclusionAndConsistency(%d, %d, %d): %v", index, size1, size2, err)
+				}
+				if want := tree.HashAt(size1); !bytes.Equal(root1, want) {
+					t.Errorf("VerifyInclusionAndConsistency(%d, %d, %d): got root1 %x, want %x", index, size1, size2, root1, want)
+				}
+
+				other := tree.LeafHash((index + 1) % size)
+				if _, err := proof.VerifyInclusionAndConsistency(hasher, index, size1, size2, other, p, root2); err == nil {
+					t.Errorf("VerifyInclusionAndConsistency(%d, %d, %d): want error for another leaf", index, size1, size2)
+				}
+			}
+		}
+	}
+
+	if _, _, err := proof.InclusionAndConsistency(5, 10, 9); err == nil {
+		t.Error("InclusionAndConsistency: want error for size1 > size2")
+	}
+	if _, _, err := proof.InclusionAndConsistency(10, 10, 20); err == nil {
+		t.Error("InclusionAndConsistency: want error for index >= size1")
+	}
+	if _, err := proof.VerifyInclusionAndConsistency(hasher, 0, 2, 1, tree.LeafHash(0), proof.Combined{}, tree.HashAt(1)); err == nil {
+		t.Error("VerifyInclusionAndConsistency: want error for size1 > size2")
+	}
+}
+
+func TestVerifyShardedInclusion(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	shardSizes := []uint64{1, 5, 8, 13}
+	shards := make([]*testonly.Tree, len(shardSizes))
+	super := testonly.New(hasher)
+	for i, size := range shardSizes {
+		shards[i] = newTree(size)
+		super.AppendData(shards[i].Hash())
+	}
+	superSize, superRoot := super.Size(), super.Hash()
+	inclusion := func(tree *testonly.Tree, index, size uint64) [][]byte {
+		t.Helper()
+		p, err := tree.InclusionProof(index, size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		return p
+	}
+
+	for shard, tree := range shards {
+		size := tree.Size()
+		for index := uint64(0); index < size; index++ {
+			leafNodes, shardNodes, err := proof.ShardedInclusion(index, size, uint64(shard), superSize)
+			if err != nil {
+				t.Fatalf("ShardedInclusion: %v", err)
+			}
+			if want, _ := proof.Inclusion(index, size); !cmp.Equal(leafNodes.IDs, want.IDs) {
+				t.Errorf("ShardedInclusion: got leaf nodes %v, want %v", leafNodes.IDs, want.IDs)
+			}
+			if want, _ := proof.Inclusion(uint64(shard), superSize); !cmp.Equal(shardNodes.IDs, want.IDs) {
+				t.Errorf("ShardedInclusion: got shard nodes %v, want %v", shardNodes.IDs, want.IDs)
+			}
+			p := proof.Sharded{
+				Leaf:  inclusion(tree, index, size),
+				Shard: inclusion(super, uint64(shard), superSize),
+			}
+			leafHash := tree.LeafHash(index)
+			shardRoot, err := proof.VerifyShardedInclusion(hasher, index, size, uint64(shard), superSize, leafHash, p, superRoot)
+			if err != nil {
+				t.Fatalf("VerifyShardedInclusion(%d, %d): %v", shard, index, err)
+			}
+			if !bytes.Equal(shardRoot, tree.Hash()) {
+				t.Errorf("VerifyShardedInclusion(%d, %d): got shard root %x, want %x", shard, index, shardRoot, tree.Hash())
+			}
+			if _, err := proof.VerifyShardedInclusion(hasher, index, size, uint64(shard+1)%superSize, superSize, leafHash, p, superRoot); err == nil {
+				t.Errorf("VerifyShardedInclusion(%d, %d): want error for wrong shard", shard, index)
+			}
+			if _, err := proof.VerifyShardedInclusion(hasher, index, size, uint64(shard), superSize, leafHash, p, shardRoot); err == nil {
+				t.Errorf("VerifyShardedInclusion(%d, %d): want error for wrong root", shard, index)
+			}
+		}
+	}
+	if _, _, err := proof.ShardedInclusion(0, 1, 4, 4); err == nil {
+		t.Error("ShardedInclusion: want error for shard beyond the super-tree")
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	const maxSize = 20
+	hasher := rfc6962.DefaultHasher
+	tree := newTree(maxSize)
+	// The fork has the same leaves, except the last one.
+	fork := newTree(maxSize - 1)
+	fork.AppendData([]byte("fork"))
+
+	for size1 := uint64(0); size1 <= maxSize; size1++ {
+		for size2 := size1; size2 <= maxSize; size2++ {
+			p, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			root1, root2 := tree.HashAt(size1), tree.HashAt(size2)
+			if ev, err := proof.CheckConsistency(hasher, size1, size2, p, root1, root2); err != nil || ev != nil {
+				t.Fatalf("CheckConsistency(%d, %d): %+v, %v; want no evidence", size1, size2, ev, err)
+			}
+
+			forkRoot := fork.HashAt(size2)
+			ev, err := proof.CheckConsistency(hasher, size1, size2, p, root1, forkRoot)
+			if err != nil {
+				t.Fatalf("CheckConsistency(%d, %d): %v", size1, size2, err)
+			}
+			if size2 != maxSize || size1 == 0 {
+				// The fork is indistinguishable, or any tree is consistent with size 0.
+				if ev != nil {
+					t.Errorf("CheckConsistency(%d, %d): got evidence %+v, want none", size1, size2, ev)
+				}
+				continue
+			}
+			if ev == nil {
+				t.Fatalf("CheckConsistency(%d, %d): want evidence", size1, size2)
+			}
+
+			data, err := json.Marshal(ev)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var got proof.ConsistencyEvidence
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if err := got.Verify(hasher); err != nil {
+				t.Errorf("Verify(%d, %d): %v", size1, size2, err)
+			}
+			got.Root2 = root2
+			if err := got.Verify(hasher); err == nil {
+				t.Errorf("Verify(%d, %d): want error for valid proof", size1, size2)
+			}
+			got.Root2, got.CalculatedRoot2 = forkRoot, forkRoot
+			if err := got.Verify(hasher); err == nil {
+				t.Errorf("Verify(%d, %d): want error for tampered evidence", size1, size2)
+			}
+		}
+	}
+
+	if _, err := proof.CheckConsistency(hasher, 1, 2, nil, tree.HashAt(1), tree.HashAt(2)); err == nil {
+		t.Error("CheckConsistency: want error for malformed proof")
+	}
+}
+
+func TestRootFromConsistencyProof(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	const size = uint64(33)
+	tree := newTree(size)
+	for size1 := uint64(1); size1 <= size; size1++ {
+		for size2 := size1; size2 <= size; size2++ {
+			p, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			root1 := tree.HashAt(size1)
+			root2, err := proof.RootFromConsistencyProof(hasher, size1, size2, p, root1)
+			if err != nil {
+				t.Fatalf("RootFromConsistencyProof(%d, %d): %v", size1, size2, err)
+			}
+			if want := tree.HashAt(size2); !bytes.Equal(root2, want) {
+				t.Errorf("RootFromConsistencyProof(%d, %d): got %x, want %x", size1, size2, root2, want)
+			}
+			// A wrong root1 either fails, or implies a wrong root2.
+			if got, err := proof.RootFromConsistencyProof(hasher, size1, size2, p, root2[:8]); err == nil && bytes.Equal(got, root2) {
+				t.Errorf("RootFromConsistencyProof(%d, %d): got the right root from a wrong root1", size1, size2)
+			}
+		}
+	}
+	if _, err := proof.RootFromConsistencyProof(hasher, 0, 10, nil, hasher.EmptyRoot()); err == nil {
+		t.Error("RootFromConsistencyProof(0, 10): want error")
+	}
+}
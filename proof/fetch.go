@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/metrics"
+	"github.com/transparency-dev/merkle/trace"
+)
+
+// FetchFunc returns the hashes of the given nodes, in the same order.
+type FetchFunc func(ctx context.Context, ids []compact.NodeID) ([][]byte, error)
+
+// Fetcher fetches node hashes from a slow storage. It splits the requests into
+// batches, fetches them concurrently, and retries the failed batches.
+//
+// The zero values of all the optional fields disable the corresponding
+// feature, i.e. by default all nodes are fetched in one batch with no retries.
+type Fetcher struct {
+	// Fetch fetches a batch of nodes. Must be safe for concurrent use if
+	// Parallelism is greater than 1.
+	Fetch FetchFunc
+	// BatchSize is the maximal number of nodes in one Fetch call. If zero, the
+	// nodes are not split into batches.
+	BatchSize int
+	// Parallelism is the maximal number of concurrent Fetch calls. Values below
+	// 1 mean 1.
+	Parallelism int
+	// Retries is the number of times a failed batch is retried.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles with each retry.
+	Backoff time.Duration
+	// IsTransient returns whether the Fetch error is worth retrying. If nil, all
+	// errors are retried, except those caused by the context cancellation.
+	IsTransient func(error) bool
+	// Recorder receives the metrics of the fetched nodes and the generated
+	// proofs, if not nil.
+	Recorder metrics.Recorder
+	// Tracer receives the details of the generated proofs, if not nil.
+	Tracer trace.Tracer
+}
+
+// Get returns the hashes of the given nodes, in the same order. Returns an
+// error if any batch fails after all the retries, or the context is done.
+func (f *Fetcher) Get(ctx context.Context, ids []compact.NodeID) ([][]byte, error) {
+	hashes := make([][]byte, len(ids))
+	batch := f.BatchSize
+	if batch <= 0 || batch > len(ids) {
+		batch = len(ids)
+	}
+	if batch == 0 {
+		return hashes, ctx.Err()
+	}
+	parallel := f.Parallelism
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		fetchErr error
+	)
+	sem := make(chan struct{}, parallel)
+	for begin := 0; begin < len(ids); begin += batch {
+		end := begin + batch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(begin, end int) {
+			defer func() { <-sem; wg.Done() }()
+			if err := f.fetch(ctx, ids[begin:end], hashes[begin:end]); err != nil {
+				once.Do(func() { fetchErr = err; cancel() })
+			}
+		}(begin, end)
+	}
+	wg.Wait()
+	if fetchErr != nil {
+		return nil, fetchErr
+	}
+	// The context is only cancelled by the caller at this point.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return hashes, nil
+}
+
+// Proof fetches the nodes needed for the given proof, and returns the proof.
+// The hc function computes a node's hash based on hashes of its children.
+func (f *Fetcher) Proof(ctx context.Context, nodes Nodes, hc func(left, right []byte) []byte) ([][]byte, error) {
+	hashes, err := f.Get(ctx, nodes.IDs)
+	if err != nil {
+		return nil, err
+	}
+	if f.Tracer != nil {
+		hc = nodes.Trace(f.Tracer, hc)
+	}
+	proof, err := nodes.Rehash(hashes, hc)
+	if err != nil {
+		return nil, err
+	}
+	if f.Recorder != nil {
+		f.Recorder.Add(metrics.ProofsGenerated, 1)
+		// The hashes of the ephemeral node's children are merged into one.
+		f.Recorder.Add(metrics.HashesComputed, uint64(len(nodes.IDs)-len(proof)))
+		f.Recorder.Observe(metrics.ProofSize, float64(len(proof)))
+	}
+	return proof, nil
+}
+
+// fetch fetches one batch of nodes into dst, with retries.
+func (f *Fetcher) fetch(ctx context.Context, ids []compact.NodeID, dst [][]byte) error {
+	backoff := f.Backoff
+	for attempt := 0; ; attempt++ {
+		hashes, err := f.Fetch(ctx, ids)
+		if err == nil && len(hashes) != len(ids) {
+			err = fmt.Errorf("fetched %d hashes, want %d", len(hashes), len(ids))
+		}
+		if err == nil {
+			copy(dst, hashes)
+			if f.Recorder != nil {
+				f.Recorder.Add(metrics.NodesFetched, uint64(len(ids)))
+			}
+			return nil
+		}
+		if attempt >= f.Retries || !f.transient(ctx, err) {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (f *Fetcher) transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return f.IsTransient == nil || f.IsTransient(err)
+}
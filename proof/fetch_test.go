@@ -0,0 +1,182 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/storage"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+// newStore returns a MemoryStore with the nodes of the tree of the given size.
+func newStore(t *testing.T, size uint64) *storage.MemoryStore {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	tree, err := storage.NewTree(rfc6962.DefaultHasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	if err := tree.AppendData(testonly.GenLeaves(0, 0, size)...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	return store
+}
+
+func TestFetcherProof(t *testing.T) {
+	const size = 1000
+	store := newStore(t, size)
+	ref := testonly.GenTree(rfc6962.DefaultHasher, 0, size)
+	hc := rfc6962.DefaultHasher.HashChildren
+
+	for _, tc := range []struct {
+		batch    int
+		parallel int
+	}{
+		{batch: 0, parallel: 0},
+		{batch: 1, parallel: 1},
+		{batch: 2, parallel: 3},
+		{batch: 3, parallel: 100},
+		{batch: 100, parallel: 2},
+	} {
+		t.Run(fmt.Sprintf("%d:%d", tc.batch, tc.parallel), func(t *testing.T) {
+			var mu sync.Mutex
+			active, maxActive := 0, 0
+			f := &proof.Fetcher{
+				Fetch: func(_ context.Context, ids []compact.NodeID) ([][]byte, error) {
+					mu.Lock()
+					if active++; active > maxActive {
+						maxActive = active
+					}
+					mu.Unlock()
+					defer func() { mu.Lock(); active--; mu.Unlock() }()
+					if tc.batch != 0 && len(ids) > tc.batch {
+						return nil, fmt.Errorf("batch of %d nodes", len(ids))
+					}
+					time.Sleep(time.Millisecond)
+					return store.Get(ids)
+				},
+				BatchSize:   tc.batch,
+				Parallelism: tc.parallel,
+			}
+			nodes, err := proof.Consistency(3, size)
+			if err != nil {
+				t.Fatalf("Consistency: %v", err)
+			}
+			got, err := f.Proof(context.Background(), nodes, hc)
+			if err != nil {
+				t.Fatalf("Proof: %v", err)
+			}
+			want, _ := ref.ConsistencyProof(3, size)
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Errorf("Proof: diff(-got +want):\n%s", diff)
+			}
+			parallel := tc.parallel
+			if parallel < 1 {
+				parallel = 1
+			}
+			if maxActive > parallel {
+				t.Errorf("got %d concurrent fetches, want at most %d", maxActive, parallel)
+			}
+		})
+	}
+}
+
+func TestFetcherRetries(t *testing.T) {
+	store := newStore(t, 20)
+	errTransient := errors.New("transient")
+	errPermanent := errors.New("permanent")
+	ids := compact.RangeNodes(0, 20, nil)
+
+	for _, tc := range []struct {
+		desc      string
+		failures  int // The number of failures of each batch before it succeeds.
+		err       error
+		retries   int
+		wantCalls int
+		wantErr   error
+	}{
+		{desc: "no-failures", retries: 2, wantCalls: 2},
+		{desc: "recovered", failures: 2, err: errTransient, retries: 2, wantCalls: 6},
+		{desc: "exhausted", failures: 3, err: errTransient, retries: 2, wantErr: errTransient},
+		{desc: "permanent", failures: 1, err: errPermanent, retries: 2, wantErr: errPermanent},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var mu sync.Mutex
+			failed := make(map[compact.NodeID]int)
+			calls := 0
+			f := &proof.Fetcher{
+				Fetch: func(_ context.Context, ids []compact.NodeID) ([][]byte, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					calls++
+					if failed[ids[0]] < tc.failures {
+						failed[ids[0]]++
+						return nil, tc.err
+					}
+					return store.Get(ids)
+				},
+				BatchSize:   1,
+				Retries:     tc.retries,
+				Backoff:     time.Microsecond,
+				IsTransient: func(err error) bool { return err == errTransient },
+			}
+			hashes, err := f.Get(context.Background(), ids)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Get: %v, want %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want, _ := store.Get(ids)
+			if diff := cmp.Diff(hashes, want); diff != "" {
+				t.Errorf("Get: diff(-got +want):\n%s", diff)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("got %d Fetch calls, want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestFetcherCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &proof.Fetcher{
+		Fetch: func(ctx context.Context, ids []compact.NodeID) ([][]byte, error) {
+			cancel()
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		BatchSize: 1,
+		Retries:   10,
+		Backoff:   time.Hour,
+	}
+	ids := compact.RangeNodes(0, 20, nil)
+	if _, err := f.Get(ctx, ids); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get: %v, want %v", err, context.Canceled)
+	}
+	if _, err := f.Get(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get: %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/storage"
+)
+
+func TestVerifyInclusionScratch(t *testing.T) {
+	const maxSize = 70
+	tree := newTree(maxSize)
+	scratch := make([]byte, rfc6962.DefaultHasher.Size())
+	for size := uint64(1); size <= maxSize; size++ {
+		root := tree.HashAt(size)
+		for index := uint64(0); index < size; index++ {
+			p, err := tree.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("InclusionProof: %v", err)
+			}
+			leaf := tree.LeafHash(index)
+			if err := proof.VerifyInclusionScratch(rfc6962.DefaultHasher, index, size, leaf, p, root, scratch); err != nil {
+				t.Errorf("VerifyInclusionScratch(%d, %d): %v", index, size, err)
+			}
+			if err := proof.VerifyInclusionScratch(rfc6962.DefaultHasher, index, size, leaf, p, tree.HashAt(size-1), scratch); err == nil {
+				t.Errorf("VerifyInclusionScratch(%d, %d): accepted wrong root", index, size)
+			}
+		}
+	}
+}
+
+func TestVerifyInclusionScratchAllocs(t *testing.T) {
+	tree := newTree(1000)
+	const index, size = 123, 999
+	p, err := tree.InclusionProof(index, size)
+	if err != nil {
+		t.Fatalf("InclusionProof: %v", err)
+	}
+	leaf, root := tree.LeafHash(index), tree.HashAt(size)
+	scratch := make([]byte, rfc6962.DefaultHasher.Size())
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := proof.VerifyInclusionScratch(rfc6962.DefaultHasher, index, size, leaf, p, root, scratch); err != nil {
+			t.Fatalf("VerifyInclusionScratch: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("VerifyInclusionScratch: got %v allocs, want 0", allocs)
+	}
+}
+
+func TestVerifyNodeInclusion(t *testing.T) {
+	const maxSize = 40
+	tree := newTree(maxSize)
+	hasher := rfc6962.DefaultHasher
+	nodeHash := func(id compact.NodeID) []byte {
+		begin, end := id.Coverage()
+		hashes := make([][]byte, 0, end-begin)
+		for i := begin; i < end; i++ {
+			hashes = append(hashes, tree.LeafHash(i))
+		}
+		_, hash, err := compact.SubtreeRoot(hasher.HashChildren, begin, hashes)
+		if err != nil {
+			t.Fatalf("SubtreeRoot: %v", err)
+		}
+		return hash
+	}
+
+	for size := uint64(1); size <= maxSize; size++ {
+		root := tree.HashAt(size)
+		for level := uint(0); uint64(1)<<level <= size; level++ {
+			for index := uint64(0); index < size>>level; index++ {
+				id := compact.NewNodeID(level, index)
+				hash := nodeHash(id)
+				p, err := tree.NodeInclusionProof(id, size)
+				if err != nil {
+					t.Fatalf("NodeInclusionProof(%+v, %d): %v", id, size, err)
+				}
+				if err := proof.VerifyNodeInclusion(hasher, id, size, hash, p, root); err != nil {
+					t.Errorf("VerifyNodeInclusion(%+v, %d): %v", id, size, err)
+				}
+				if err := proof.VerifyNodeInclusion(hasher, id.Sibling(), size, hash, p, root); err == nil && id.Sibling().Index < size>>level {
+					t.Errorf("VerifyNodeInclusion(%+v, %d): want error for sibling", id, size)
+				}
+			}
+		}
+		if err := proof.VerifyNodeInclusion(hasher, compact.NewNodeID(1, size/2), size, nodeHash(compact.NewNodeID(0, 0)), nil, root); err == nil {
+			t.Errorf("VerifyNodeInclusion: want error for node beyond size %d", size)
+		}
+	}
+}
+
+func TestVerifyNonInclusion(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	key := func(i uint64) []byte { return []byte(fmt.Sprintf("key %03d", i)) }
+	for size := uint64(0); size <= 10; size++ {
+		// The log contains the even keys.
+		store := storage.NewMemoryStore()
+		tree, err := storage.NewTree(hasher, store)
+		if err != nil {
+			t.Fatalf("NewTree: %v", err)
+		}
+		leaves := make([][]byte, size)
+		for i := range leaves {
+			leaves[i] = key(uint64(i) * 2)
+		}
+		if err := tree.AppendData(leaves...); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+		root, err := tree.Hash()
+		if err != nil {
+			t.Fatalf("Hash: %v", err)
+		}
+		prove := func(pos uint64) ([][]byte, [][]byte) {
+			nodes, err := proof.NonInclusion(pos, size)
+			if err != nil {
+				t.Fatalf("NonInclusion(%d, %d): %v", pos, size, err)
+			}
+			hashes, err := store.Get(nodes.IDs)
+			if err != nil {
+				t.Fatalf("Get: %v", err)
+			}
+			p, err := nodes.Rehash(hashes, hasher.HashChildren)
+			if err != nil {
+				t.Fatalf("Rehash: %v", err)
+			}
+			begin, end := pos, pos
+			if pos > 0 {
+				begin--
+			}
+			if pos < size {
+				end++
+			}
+			return p, leaves[begin:end]
+		}
+
+		for pos := uint64(0); pos <= size; pos++ {
+			p, neighbours := prove(pos)
+			// The odd key at this position is absent.
+			value := key(pos*2 - 1)
+			if pos == 0 {
+				value = []byte("key")
+			}
+			if err := proof.VerifyNonInclusion(hasher, pos, size, value, neighbours, p, root); err != nil {
+				t.Errorf("VerifyNonInclusion(%d, %d): %v", pos, size, err)
+			}
+			// The keys in the log are present.
+			for _, leaf := range neighbours {
+				if err := proof.VerifyNonInclusion(hasher, pos, size, leaf, neighbours, p, root); err == nil {
+					t.Errorf("VerifyNonInclusion(%d, %d): want error for present %q", pos, size, leaf)
+				}
+			}
+			// Wrong neighbours.
+			if len(neighbours) != 0 {
+				wrong := append([][]byte{}, neighbours...)
+				wrong[0] = append([]byte{}, "key"...)
+				if pos == 0 {
+					wrong[0] = key(pos*2 + 1)
+				}
+				if err := proof.VerifyNonInclusion(hasher, pos, size, value, wrong, p, root); err == nil {
+					t.Errorf("VerifyNonInclusion(%d, %d): want error for wrong neighbours", pos, size)
+				}
+			}
+		}
+		if _, err := proof.NonInclusion(size+1, size); err == nil {
+			t.Errorf("NonInclusion(%d, %d): want error", size+1, size)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/metrics"
+	"github.com/transparency-dev/merkle/trace"
+)
+
+// ErrLimitExceeded is wrapped by the errors returned by Verifier when the input
+// exceeds the configured Limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// Limits bounds the inputs which a Verifier accepts. The zero value of each
+// field means no limit.
+type Limits struct {
+	// MaxProofLen is the maximal number of hashes in a proof.
+	MaxProofLen int
+	// MaxDepth is the maximal depth of the tree, i.e. the number of levels
+	// above the leaves. The tree of size N has depth ceil(log2(N)).
+	MaxDepth uint
+}
+
+// check returns an error if the proof length or any of the tree sizes exceed
+// the limits.
+func (l Limits) check(proofLen int, sizes ...uint64) error {
+	if l.MaxProofLen != 0 && proofLen > l.MaxProofLen {
+		return fmt.Errorf("%w: proof size %d exceeds %d", ErrLimitExceeded, proofLen, l.MaxProofLen)
+	}
+	if l.MaxDepth == 0 {
+		return nil
+	}
+	for _, size := range sizes {
+		if size == 0 {
+			continue
+		}
+		if depth := uint(bits.Len64(size - 1)); depth > l.MaxDepth {
+			return fmt.Errorf("%w: tree size %d has depth %d exceeding %d", ErrLimitExceeded, size, depth, l.MaxDepth)
+		}
+	}
+	return nil
+}
+
+// Verifier verifies proofs with the given hasher, and rejects the inputs which
+// exceed the limits or are malformed before doing any hashing. Use it for
+// proofs coming from untrusted sources.
+type Verifier struct {
+	Hasher merkle.LogHasher
+	Limits Limits
+	// Recorder receives the metrics of the verifications, if not nil.
+	Recorder metrics.Recorder
+	// Tracer receives the details of the verifications, if not nil.
+	Tracer trace.Tracer
+}
+
+// VerifyInclusion is like the VerifyInclusion function, but checks the limits
+// and the proof shape first.
+func (v Verifier) VerifyInclusion(index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	o := v.observer()
+	if err := v.Limits.check(len(proof), size); err != nil {
+		return o.rejected(err)
+	} else if err := CheckInclusionShape(index, size, proof, v.Hasher.Size()); err != nil {
+		return o.rejected(err)
+	}
+	return o.inclusion(v.Hasher, index, size, leafHash, proof, root)
+}
+
+// VerifyConsistency is like the VerifyConsistency function, but checks the
+// limits and the proof shape first.
+func (v Verifier) VerifyConsistency(size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
+	o := v.observer()
+	if err := v.Limits.check(len(proof), size1, size2); err != nil {
+		return o.rejected(err)
+	} else if err := CheckConsistencyShape(size1, size2, proof, v.Hasher.Size()); err != nil {
+		return o.rejected(err)
+	}
+	return o.consistency(v.Hasher, size1, size2, proof, root1, root2)
+}
+
+func (v Verifier) observer() observer {
+	return observer{rec: v.Recorder, tracer: v.Tracer}
+}
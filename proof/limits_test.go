@@ -0,0 +1,66 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestVerifierLimits(t *testing.T) {
+	const size = 100
+	tree := newTree(size)
+	hasher := rfc6962.DefaultHasher
+	incl, err := tree.InclusionProof(10, size) // Has 7 hashes.
+	if err != nil {
+		t.Fatalf("InclusionProof: %v", err)
+	}
+	cons, err := tree.ConsistencyProof(96, size) // Has 3 hashes.
+	if err != nil {
+		t.Fatalf("ConsistencyProof: %v", err)
+	}
+
+	for _, tc := range []struct {
+		limits      proof.Limits
+		wantIncl    bool
+		wantConsist bool
+	}{
+		{limits: proof.Limits{}, wantIncl: true, wantConsist: true},
+		{limits: proof.Limits{MaxProofLen: 7, MaxDepth: 7}, wantIncl: true, wantConsist: true},
+		{limits: proof.Limits{MaxProofLen: 6}, wantIncl: false, wantConsist: true},
+		{limits: proof.Limits{MaxProofLen: 2}, wantIncl: false, wantConsist: false},
+		{limits: proof.Limits{MaxDepth: 6}, wantIncl: false, wantConsist: false},
+	} {
+		t.Run(fmt.Sprintf("%d:%d", tc.limits.MaxProofLen, tc.limits.MaxDepth), func(t *testing.T) {
+			v := proof.Verifier{Hasher: hasher, Limits: tc.limits}
+			err := v.VerifyInclusion(10, size, tree.LeafHash(10), incl, tree.Hash())
+			if got, want := err == nil, tc.wantIncl; got != want {
+				t.Errorf("VerifyInclusion: %v, want success %v", err, want)
+			} else if err != nil && !errors.Is(err, proof.ErrLimitExceeded) {
+				t.Errorf("VerifyInclusion: %v, want %v", err, proof.ErrLimitExceeded)
+			}
+			err = v.VerifyConsistency(96, size, cons, tree.HashAt(96), tree.Hash())
+			if got, want := err == nil, tc.wantConsist; got != want {
+				t.Errorf("VerifyConsistency: %v, want success %v", err, want)
+			} else if err != nil && !errors.Is(err, proof.ErrLimitExceeded) {
+				t.Errorf("VerifyConsistency: %v, want %v", err, proof.ErrLimitExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2017 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lite
+
+import (
+	"strconv"
+	"strings"
+)
+
+// lazyError is an error which formats its message only when requested. The
+// message can contain up to two %d verbs, which are substituted with args.
+type lazyError struct {
+	wrap error // The wrapped error, if any. Prefixes the message.
+	msg  string
+	args [2]uint64
+}
+
+// newError returns a lazyError with the given message and arguments.
+func newError(wrap error, msg string, args ...uint64) error {
+	e := &lazyError{wrap: wrap, msg: msg}
+	copy(e.args[:], args)
+	return e
+}
+
+func (e *lazyError) Error() string {
+	var b []byte
+	if e.wrap != nil {
+		b = append(append(b, e.wrap.Error()...), ": "...)
+	}
+	msg := e.msg
+	for _, arg := range e.args {
+		i := strings.Index(msg, "%d")
+		if i < 0 {
+			break
+		}
+		b = strconv.AppendUint(append(b, msg[:i]...), arg, 10)
+		msg = msg[i+2:]
+	}
+	return string(append(b, msg...))
+}
+
+func (e *lazyError) Unwrap() error {
+	return e.wrap
+}
+
+// formatBytes formats the byte slice like fmt does with the %v verb, e.g.
+// [1 2 3].
+func formatBytes(data []byte) []byte {
+	b := append(make([]byte, 0, 2+4*len(data)), '[')
+	for i, v := range data {
+		if i != 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendUint(b, uint64(v), 10)
+	}
+	return append(b, ']')
+}
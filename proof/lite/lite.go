@@ -0,0 +1,211 @@
+// Copyright 2017 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lite verifies inclusion and consistency proofs of log Merkle trees
+// with minimal dependencies. It does not depend on fmt, and error messages are
+// only formatted when requested, so that the verification code is small and
+// cheap, e.g. when compiled with TinyGo to WebAssembly or for embedded devices.
+//
+// The proof package uses this package for the same verifications, and adds
+// metrics, tracing and other features on top.
+package lite
+
+import (
+	"bytes"
+	"errors"
+	"math/bits"
+)
+
+// ErrMalformedProof is returned, possibly wrapped, when a proof does not have
+// the shape required for the given tree sizes and indices.
+var ErrMalformedProof = errors.New("malformed proof")
+
+// Hasher computes the hashes of the tree nodes. It is a subset of the
+// merkle.LogHasher interface, so that this package does not depend on it.
+type Hasher interface {
+	// HashChildren computes the hash of an inner node from its children.
+	HashChildren(l, r []byte) []byte
+	// Size returns the number of bytes the hashes have.
+	Size() int
+}
+
+// RootMismatchError occurs when an inclusion proof fails.
+type RootMismatchError struct {
+	ExpectedRoot   []byte
+	CalculatedRoot []byte
+}
+
+func (e RootMismatchError) Error() string {
+	b := append([]byte("calculated root:\n"), formatBytes(e.CalculatedRoot)...)
+	b = append(b, "\n does not match expected root:\n"...)
+	return string(append(b, formatBytes(e.ExpectedRoot)...))
+}
+
+// VerifyMatch returns RootMismatchError if the calculated root hash does not
+// match the expected one.
+func VerifyMatch(calculated, expected []byte) error {
+	if !bytes.Equal(calculated, expected) {
+		return RootMismatchError{ExpectedRoot: expected, CalculatedRoot: calculated}
+	}
+	return nil
+}
+
+// VerifyInclusion verifies the correctness of the inclusion proof for the leaf
+// with the specified hash and index, relatively to the tree of the given size
+// and root hash. Requires 0 <= index < size.
+func VerifyInclusion(hasher Hasher, index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	calcRoot, err := RootFromInclusionProof(hasher, index, size, leafHash, proof)
+	if err != nil {
+		return err
+	}
+	return VerifyMatch(calcRoot, root)
+}
+
+// RootFromInclusionProof calculates the expected root hash for a tree of the
+// given size, provided a leaf index and hash with the corresponding inclusion
+// proof. Requires 0 <= index < size.
+func RootFromInclusionProof(hasher Hasher, index, size uint64, leafHash []byte, proof [][]byte) ([]byte, error) {
+	if index >= size {
+		return nil, newError(nil, "index is beyond size: %d >= %d", index, size)
+	}
+	if got, want := len(leafHash), hasher.Size(); got != want {
+		return nil, newError(nil, "leafHash has unexpected size %d, want %d", uint64(got), uint64(want))
+	}
+
+	inner, border := DecompInclProof(index, size)
+	if got, want := len(proof), inner+border; got != want {
+		return nil, newError(ErrMalformedProof, "wrong proof size %d, want %d", uint64(got), uint64(want))
+	}
+
+	res := ChainInner(hasher, leafHash, proof[:inner], index)
+	res = ChainBorderRight(hasher, res, proof[inner:])
+	return res, nil
+}
+
+// VerifyConsistency checks that the passed-in consistency proof is valid
+// between the passed in tree sizes, with respect to the corresponding root
+// hashes. Requires 0 <= size1 <= size2.
+func VerifyConsistency(hasher Hasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
+	hash1, hash2, err := ConsistencyRoots(hasher, hasher, size1, size2, proof, root1, root2)
+	if err != nil {
+		return err
+	}
+	if err := VerifyMatch(hash1, root1); err != nil {
+		return err
+	}
+	return VerifyMatch(hash2, root2)
+}
+
+// ConsistencyRoots returns the root hashes of the trees of size1 and size2
+// calculated from the passed-in consistency proof. The proof is valid iff they
+// match root1 and root2 correspondingly. Returns an error if the proof is
+// malformed. Requires 0 <= size1 <= size2.
+//
+// The roots are computed with hasher1 and hasher2 correspondingly, which can
+// be the same hasher, or hashers with separate scratch buffers.
+func ConsistencyRoots(hasher1, hasher2 Hasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) ([]byte, []byte, error) {
+	switch {
+	case size2 < size1:
+		return nil, nil, newError(nil, "size2 (%d) < size1 (%d)", size2, size1)
+	case size1 == size2:
+		if len(proof) > 0 {
+			return nil, nil, newError(ErrMalformedProof, "size1=size2, but proof is not empty")
+		}
+		return root1, root1, nil
+	case size1 == 0:
+		// Any size greater than 0 is consistent with size 0.
+		if len(proof) > 0 {
+			return nil, nil, newError(ErrMalformedProof, "expected empty proof, but got %d components", uint64(len(proof)))
+		}
+		return root1, root2, nil // Proof OK.
+	case len(proof) == 0:
+		return nil, nil, newError(ErrMalformedProof, "empty proof")
+	}
+
+	inner, border := DecompInclProof(size1-1, size2)
+	shift := bits.TrailingZeros64(size1)
+	inner -= shift // Note: shift < inner if size1 < size2.
+
+	// The proof includes the root hash for the sub-tree of size 2^shift.
+	seed, start := proof[0], 1
+	if size1 == 1<<uint(shift) { // Unless size1 is that very 2^shift.
+		seed, start = root1, 0
+	}
+	if got, want := len(proof), start+inner+border; got != want {
+		return nil, nil, newError(ErrMalformedProof, "wrong proof size %d, want %d", uint64(got), uint64(want))
+	}
+	proof = proof[start:]
+	// Now len(proof) == inner+border, and proof is effectively a suffix of
+	// inclusion proof for entry |size1-1| in a tree of size |size2|.
+
+	// Calculate the first root.
+	mask := (size1 - 1) >> uint(shift) // Start chaining from level |shift|.
+	hash1 := chainInnerRight(hasher1, seed, proof[:inner], mask)
+	hash1 = ChainBorderRight(hasher1, hash1, proof[inner:])
+
+	// Calculate the second root.
+	hash2 := ChainInner(hasher2, seed, proof[:inner], mask)
+	hash2 = ChainBorderRight(hasher2, hash2, proof[inner:])
+	return hash1, hash2, nil
+}
+
+// DecompInclProof breaks down inclusion proof for a leaf at the specified
+// |index| in a tree of the specified |size| into 2 components. The splitting
+// point between them is where paths to leaves |index| and |size-1| diverge.
+// Returns lengths of the bottom and upper proof parts correspondingly. The sum
+// of the two determines the correct length of the inclusion proof.
+//
+// DecompInclProof, ChainInner and ChainBorderRight are the building blocks of
+// the verification, exported for the proof package and custom verifiers.
+func DecompInclProof(index, size uint64) (int, int) {
+	inner := bits.Len64(index ^ (size - 1))
+	border := bits.OnesCount64(index >> uint(inner))
+	return inner, border
+}
+
+// ChainInner computes a subtree hash for a node on or below the tree's right
+// border. Assumes |proof| hashes are ordered from lower levels to upper, and
+// |seed| is the initial subtree/leaf hash on the path located at the specified
+// |index| on its level.
+func ChainInner(hasher Hasher, seed []byte, proof [][]byte, index uint64) []byte {
+	for i, h := range proof {
+		if (index>>uint(i))&1 == 0 {
+			seed = hasher.HashChildren(seed, h)
+		} else {
+			seed = hasher.HashChildren(h, seed)
+		}
+	}
+	return seed
+}
+
+// chainInnerRight computes a subtree hash like ChainInner, but only takes
+// hashes to the left from the path into consideration, which effectively means
+// the result is a hash of the corresponding earlier version of this subtree.
+func chainInnerRight(hasher Hasher, seed []byte, proof [][]byte, index uint64) []byte {
+	for i, h := range proof {
+		if (index>>uint(i))&1 == 1 {
+			seed = hasher.HashChildren(h, seed)
+		}
+	}
+	return seed
+}
+
+// ChainBorderRight chains proof hashes along tree borders. This differs from
+// inner chaining because |proof| contains only left-side subtree hashes.
+func ChainBorderRight(hasher Hasher, seed []byte, proof [][]byte) []byte {
+	for _, h := range proof {
+		seed = hasher.HashChildren(h, seed)
+	}
+	return seed
+}
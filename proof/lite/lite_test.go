@@ -0,0 +1,155 @@
+// Copyright 2017 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lite_test
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/proof/lite"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func TestVerify(t *testing.T) {
+	const size = 20
+	tree := testonly.New(hasher)
+	for i := 0; i < size; i++ {
+		tree.AppendData([]byte(fmt.Sprintf("leaf: %d", i)))
+	}
+	for size2 := uint64(1); size2 <= size; size2++ {
+		root2 := tree.HashAt(size2)
+		for index := uint64(0); index < size2; index++ {
+			p, err := tree.InclusionProof(index, size2)
+			if err != nil {
+				t.Fatalf("InclusionProof: %v", err)
+			}
+			if err := lite.VerifyInclusion(hasher, index, size2, tree.LeafHash(index), p, root2); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d): %v", index, size2, err)
+			}
+			if err := lite.VerifyInclusion(hasher, index, size2, hasher.HashLeaf(nil), p, root2); err == nil {
+				t.Errorf("VerifyInclusion(%d, %d): want error for wrong leaf", index, size2)
+			}
+		}
+		for size1 := uint64(0); size1 <= size2; size1++ {
+			p, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			root1 := tree.HashAt(size1)
+			if err := lite.VerifyConsistency(hasher, size1, size2, p, root1, root2); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d): %v", size1, size2, err)
+			}
+			if size1 != 0 && size1 != size2 {
+				if err := lite.VerifyConsistency(hasher, size1, size2, p, root2, root2); err == nil {
+					t.Errorf("VerifyConsistency(%d, %d): want error for wrong root", size1, size2)
+				}
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	hash := hasher.HashLeaf(nil)
+	for _, tc := range []struct {
+		desc      string
+		err       error
+		want      string
+		malformed bool
+	}{
+		{
+			desc: "index",
+			err:  lite.VerifyInclusion(hasher, 5, 5, hash, nil, hash),
+			want: "index is beyond size: 5 >= 5",
+		},
+		{
+			desc: "leaf-hash",
+			err:  lite.VerifyInclusion(hasher, 0, 5, []byte{1}, nil, hash),
+			want: "leafHash has unexpected size 1, want 32",
+		},
+		{
+			desc:      "inclusion-proof-size",
+			err:       lite.VerifyInclusion(hasher, 0, 5, hash, nil, hash),
+			want:      "malformed proof: wrong proof size 0, want 3",
+			malformed: true,
+		},
+		{
+			desc: "sizes",
+			err:  lite.VerifyConsistency(hasher, 5, 4, nil, hash, hash),
+			want: "size2 (4) < size1 (5)",
+		},
+		{
+			desc:      "same-size",
+			err:       lite.VerifyConsistency(hasher, 4, 4, [][]byte{hash}, hash, hash),
+			want:      "malformed proof: size1=size2, but proof is not empty",
+			malformed: true,
+		},
+		{
+			desc:      "empty-tree",
+			err:       lite.VerifyConsistency(hasher, 0, 4, [][]byte{hash, hash}, hash, hash),
+			want:      "malformed proof: expected empty proof, but got 2 components",
+			malformed: true,
+		},
+		{
+			desc: "root-mismatch",
+			err:  lite.VerifyMatch([]byte{1, 2}, []byte{3}),
+			want: fmt.Sprintf("calculated root:\n%v\n does not match expected root:\n%v", []byte{1, 2}, []byte{3}),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if tc.err == nil {
+				t.Fatal("got no error")
+			}
+			if got := tc.err.Error(); got != tc.want {
+				t.Errorf("got error %q, want %q", got, tc.want)
+			}
+			if got, want := errors.Is(tc.err, proof.ErrMalformedProof), tc.malformed; got != want {
+				t.Errorf("errors.Is(ErrMalformedProof): got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// TestDependencies checks that the package does not depend on fmt, even
+// transitively, to keep the binaries small.
+func TestDependencies(t *testing.T) {
+	seen := map[string]bool{}
+	var walk func(path string, from string)
+	walk = func(path, from string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		if path == "fmt" {
+			t.Errorf("depends on fmt via %s", from)
+			return
+		}
+		pkg, err := build.Import(path, ".", 0)
+		if err != nil {
+			t.Fatalf("Import(%q): %v", path, err)
+		}
+		for _, imp := range pkg.Imports {
+			if imp != "C" && imp != "unsafe" {
+				walk(imp, path)
+			}
+		}
+	}
+	walk("github.com/transparency-dev/merkle/proof/lite", "")
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"time"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/metrics"
+	"github.com/transparency-dev/merkle/trace"
+)
+
+// observer verifies proofs, and reports the metrics and traces of the
+// verifications. The zero value reports nothing.
+type observer struct {
+	rec    metrics.Recorder
+	tracer trace.Tracer
+}
+
+func (o observer) enabled() bool {
+	return o.rec != nil || o.tracer != nil
+}
+
+// inclusion is like VerifyInclusion, but reports the metrics.
+func (o observer) inclusion(hasher merkle.LogHasher, index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	s, ok := getScratch(hasher, o)
+	if !ok {
+		return verifyInclusion(hasher, index, size, leafHash, proof, root)
+	}
+	start := o.now()
+	err := verifyInclusion(&s.h1, index, size, leafHash, proof, root)
+	o.verified(start, s.hashes(), err)
+	return s.release(err)
+}
+
+// consistency is like VerifyConsistency, but reports the metrics.
+func (o observer) consistency(hasher merkle.LogHasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
+	s, ok := getScratch(hasher, o)
+	if !ok {
+		return verifyConsistency(hasher, hasher, size1, size2, proof, root1, root2)
+	}
+	start := o.now()
+	err := verifyConsistency(&s.h1, &s.h2, size1, size2, proof, root1, root2)
+	o.verified(start, s.hashes(), err)
+	return s.release(err)
+}
+
+// now returns the current time if the latency is reported.
+func (o observer) now() time.Time {
+	if o.rec == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// verified reports a completed verification which started at the given time,
+// and computed the given number of hashes.
+func (o observer) verified(start time.Time, hashes uint64, err error) {
+	if o.rec != nil {
+		o.rec.Observe(metrics.VerifyLatency, time.Since(start).Seconds())
+		o.rec.Add(metrics.HashesComputed, hashes)
+		if err != nil {
+			o.rec.Add(metrics.VerifyFailures, 1)
+		}
+	}
+	if o.tracer != nil {
+		o.tracer.Verified(err)
+	}
+}
+
+// rejected reports a verification which failed before computing any hashes.
+func (o observer) rejected(err error) error {
+	if o.rec != nil {
+		o.rec.Add(metrics.VerifyFailures, 1)
+	}
+	if o.tracer != nil {
+		o.tracer.Verified(err)
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/metrics"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+type fakeRecorder struct {
+	counters   map[string]uint64
+	histograms map[string][]float64
+}
+
+func (f *fakeRecorder) Add(name string, delta uint64) {
+	f.counters[name] += delta
+}
+
+func (f *fakeRecorder) Observe(name string, value float64) {
+	f.histograms[name] = append(f.histograms[name], value)
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Add(string, uint64)      {}
+func (nopRecorder) Observe(string, float64) {}
+
+func TestMetrics(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := testonly.GenTree(hasher, 0, 7)
+	store := newStore(t, 7)
+	f := &fakeRecorder{counters: map[string]uint64{}, histograms: map[string][]float64{}}
+
+	// The proof consists of nodes 0:1, 1:1, and the ephemeral node 2:1 which is
+	// computed from 0:6 and 1:2.
+	nodes, err := proof.Inclusion(0, 7)
+	if err != nil {
+		t.Fatalf("Inclusion: %v", err)
+	}
+	fetcher := proof.Fetcher{
+		Fetch: func(_ context.Context, ids []compact.NodeID) ([][]byte, error) {
+			return store.Get(ids)
+		},
+		Recorder: f,
+	}
+	p, err := fetcher.Proof(context.Background(), nodes, hasher.HashChildren)
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	v := proof.Verifier{Hasher: hasher, Recorder: f}
+	if err := v.VerifyInclusion(0, 7, tree.LeafHash(0), p, tree.Hash()); err != nil {
+		t.Fatalf("VerifyInclusion: %v", err)
+	}
+	if err := v.VerifyInclusion(1, 7, tree.LeafHash(0), p, tree.Hash()); err == nil {
+		t.Fatal("VerifyInclusion: want error")
+	}
+	// The malformed proof is rejected without hashing.
+	if err := v.VerifyInclusion(0, 7, tree.LeafHash(0), p[1:], tree.Hash()); err == nil {
+		t.Fatal("VerifyInclusion: want error")
+	}
+	if diff := cmp.Diff(f.counters, map[string]uint64{
+		metrics.ProofsGenerated: 1,
+		metrics.NodesFetched:    4,
+		metrics.HashesComputed:  1 + 3 + 3,
+		metrics.VerifyFailures:  2,
+	}); diff != "" {
+		t.Errorf("counters: diff(-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(f.histograms[metrics.ProofSize], []float64{3}); diff != "" {
+		t.Errorf("%s: diff(-got +want):\n%s", metrics.ProofSize, diff)
+	}
+	if got, want := len(f.histograms[metrics.VerifyLatency]), 2; got != want {
+		t.Errorf("%s: got %d values, want %d", metrics.VerifyLatency, got, want)
+	}
+}
+
+type fakeTracer struct {
+	nodes    [][]compact.NodeID
+	ephem    []compact.NodeID
+	hashes   int
+	verified []error
+}
+
+func (f *fakeTracer) Nodes(ids []compact.NodeID, begin, end int, ephem compact.NodeID) {
+	f.nodes = append(f.nodes, append([]compact.NodeID(nil), ids...))
+	f.ephem = append(f.ephem, ids[begin:end]...)
+}
+
+func (f *fakeTracer) Hash(left, right, hash []byte) { f.hashes++ }
+func (f *fakeTracer) Verified(err error)            { f.verified = append(f.verified, err) }
+
+func TestTrace(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := testonly.GenTree(hasher, 0, 7)
+	store := newStore(t, 7)
+	f := &fakeTracer{}
+
+	nodes, err := proof.Inclusion(0, 7)
+	if err != nil {
+		t.Fatalf("Inclusion: %v", err)
+	}
+	fetcher := proof.Fetcher{
+		Fetch: func(_ context.Context, ids []compact.NodeID) ([][]byte, error) {
+			return store.Get(ids)
+		},
+		Tracer: f,
+	}
+	p, err := fetcher.Proof(context.Background(), nodes, hasher.HashChildren)
+	if err != nil {
+		t.Fatalf("Proof: %v", err)
+	}
+	v := proof.Verifier{Hasher: hasher, Tracer: f}
+	if err := v.VerifyInclusion(0, 7, tree.LeafHash(0), p, tree.Hash()); err != nil {
+		t.Fatalf("VerifyInclusion: %v", err)
+	}
+	if err := v.VerifyInclusion(1, 7, tree.LeafHash(0), p, tree.Hash()); err == nil {
+		t.Fatal("VerifyInclusion: want error")
+	}
+
+	// The proof consists of nodes 0:1, 1:1, and the ephemeral node 2:1 which is
+	// computed from 0:6 and 1:2.
+	ids := []compact.NodeID{compact.NewNodeID(0, 1), compact.NewNodeID(1, 1), compact.NewNodeID(0, 6), compact.NewNodeID(1, 2)}
+	if diff := cmp.Diff(f.nodes, [][]compact.NodeID{ids}); diff != "" {
+		t.Errorf("nodes: diff(-got +want):\n%s", diff)
+	}
+	if diff := cmp.Diff(f.ephem, ids[2:]); diff != "" {
+		t.Errorf("ephemeral: diff(-got +want):\n%s", diff)
+	}
+	if got, want := f.hashes, 1+3+3; got != want {
+		t.Errorf("hashes: got %d, want %d", got, want)
+	}
+	if got, want := len(f.verified), 2; got != want {
+		t.Fatalf("verified: got %d results, want %d", got, want)
+	}
+	if f.verified[0] != nil || f.verified[1] == nil {
+		t.Errorf("verified: got %v, want [nil, error]", f.verified)
+	}
+}
+
+func TestMetricsAllocs(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := testonly.GenTree(hasher, 0, 1000)
+	const index, size = 123, 999
+	p, err := tree.InclusionProof(index, size)
+	if err != nil {
+		t.Fatalf("InclusionProof: %v", err)
+	}
+	leaf, root := tree.LeafHash(index), tree.HashAt(size)
+	// Reporting the metrics does not prevent reusing the buffers.
+	v := proof.Verifier{Hasher: hasher, Recorder: nopRecorder{}}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := v.VerifyInclusion(index, size, leaf, p, root); err != nil {
+			t.Fatalf("VerifyInclusion: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("VerifyInclusion: got %v allocs, want 0", allocs)
+	}
+}
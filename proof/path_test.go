@@ -0,0 +1,86 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"math/bits"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestPath(t *testing.T) {
+	const size = 37
+	tree := newTree(size)
+	// hashRange returns the root hash of the [begin, end) range of leaves, as
+	// defined by RFC 6962. This is also the hash of an ephemeral node.
+	var hashRange func(begin, end uint64) []byte
+	hashRange = func(begin, end uint64) []byte {
+		if end-begin == 1 {
+			return tree.LeafHash(begin)
+		}
+		split := uint64(1) << (bits.Len64(end-begin-1) - 1)
+		return rfc6962.DefaultHasher.HashChildren(hashRange(begin, begin+split), hashRange(begin+split, end))
+	}
+	// getHashes returns the hashes of the given nodes in the tree of the given
+	// size, including ephemeral nodes.
+	getHashes := func(ids []compact.NodeID, size uint64) [][]byte {
+		hashes := make([][]byte, len(ids))
+		for i, id := range ids {
+			begin, end := id.Coverage()
+			if end > size {
+				end = size
+			}
+			hashes[i] = hashRange(begin, end)
+		}
+		return hashes
+	}
+
+	for size2 := uint64(1); size2 <= size; size2++ {
+		for index := uint64(0); index < size2; index++ {
+			ids, err := proof.InclusionPath(index, size2)
+			if err != nil {
+				t.Fatalf("InclusionPath(%d, %d): %v", index, size2, err)
+			}
+			want, err := tree.InclusionProof(index, size2)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d): %v", index, size2, err)
+			}
+			if diff := cmp.Diff(getHashes(ids, size2), want); diff != "" {
+				t.Errorf("InclusionPath(%d, %d): diff(-got +want):\n%s", index, size2, diff)
+			}
+		}
+		for size1 := uint64(0); size1 <= size2; size1++ {
+			ids, err := proof.ConsistencyPath(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyPath(%d, %d): %v", size1, size2, err)
+			}
+			want, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", size1, size2, err)
+			}
+			if diff := cmp.Diff(getHashes(ids, size2), want, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("ConsistencyPath(%d, %d): diff(-got +want):\n%s", size1, size2, diff)
+			}
+		}
+	}
+	if _, err := proof.InclusionPath(5, 5); err == nil {
+		t.Error("InclusionPath(5, 5): want error")
+	}
+}
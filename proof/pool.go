@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"sync"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/trace"
+)
+
+// bufHasher is a merkle.LogHasher which counts the computed node hashes, and
+// reports them to the tracer if it is not nil. If the wrapped hasher is a ScratchHasher, it also writes the results of
+// HashChildren to the same buffer. The chained hash computations in this
+// package only use the result of the previous HashChildren call, so they can
+// run on such a hasher.
+type bufHasher struct {
+	merkle.LogHasher
+	scratch ScratchHasher // The wrapped hasher, or nil if it is not a ScratchHasher.
+	buf     []byte
+	count   uint64 // The number of HashChildren calls.
+	tracer  trace.Tracer
+}
+
+func (b *bufHasher) HashChildren(l, r []byte) []byte {
+	var hash []byte
+	if b.scratch != nil {
+		b.buf = b.scratch.HashChildrenTo(b.buf, l, r)
+		hash = b.buf
+	} else {
+		hash = b.LogHasher.HashChildren(l, r)
+	}
+	b.count++
+	if b.tracer != nil {
+		b.tracer.Hash(l, r, hash)
+	}
+	return hash
+}
+
+func (b *bufHasher) reset(hasher merkle.LogHasher, scratch ScratchHasher, tracer trace.Tracer) {
+	b.LogHasher, b.scratch, b.count, b.tracer = hasher, scratch, 0, tracer
+}
+
+// scratch contains the hashers with reusable buffers for one verification.
+// Consistency proofs need two of them, for computing the two roots.
+type scratch struct {
+	h1, h2 bufHasher
+}
+
+var scratchPool = sync.Pool{New: func() interface{} { return new(scratch) }}
+
+// getScratch returns the scratch space from the pool if the hasher supports
+// computing hashes into the caller's buffer, or the observer reports the
+// computed hashes. Otherwise, returns false, and the hasher can be used as is.
+// The caller must call release when the computed hashes are no longer used.
+func getScratch(hasher merkle.LogHasher, o observer) (*scratch, bool) {
+	sh, ok := hasher.(ScratchHasher)
+	if !ok && !o.enabled() {
+		return nil, false
+	}
+	s := scratchPool.Get().(*scratch)
+	s.h1.reset(hasher, sh, o.tracer)
+	s.h2.reset(hasher, sh, o.tracer)
+	return s, true
+}
+
+// hashes returns the number of hashes computed with the scratch hashers.
+func (s *scratch) hashes() uint64 {
+	return s.h1.count + s.h2.count
+}
+
+// release returns the scratch space to the pool. The passed in error is
+// returned, with the hashes it refers to copied out of the scratch space.
+func (s *scratch) release(err error) error {
+	if rme, ok := err.(RootMismatchError); ok {
+		rme.CalculatedRoot = append([]byte(nil), rme.CalculatedRoot...)
+		err = rme
+	}
+	s.h1.reset(nil, nil, nil)
+	s.h2.reset(nil, nil, nil)
+	scratchPool.Put(s)
+	return err
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestVerifyAllocs(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	tree := newTree(1000)
+	const index, size1, size2 = 123, 555, 999
+	incl, err := tree.InclusionProof(index, size2)
+	if err != nil {
+		t.Fatalf("InclusionProof: %v", err)
+	}
+	cons, err := tree.ConsistencyProof(size1, size2)
+	if err != nil {
+		t.Fatalf("ConsistencyProof: %v", err)
+	}
+	leaf, root1, root2 := tree.LeafHash(index), tree.HashAt(size1), tree.HashAt(size2)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := proof.VerifyInclusion(hasher, index, size2, leaf, incl, root2); err != nil {
+			t.Fatalf("VerifyInclusion: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("VerifyInclusion: got %v allocs, want 0", allocs)
+	}
+	allocs = testing.AllocsPerRun(100, func() {
+		if err := proof.VerifyConsistency(hasher, size1, size2, cons, root1, root2); err != nil {
+			t.Fatalf("VerifyConsistency: %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("VerifyConsistency: got %v allocs, want 0", allocs)
+	}
+
+	// The calculated root in the error must not refer to the reused buffers.
+	err = proof.VerifyInclusion(hasher, index, size2, leaf, incl, root1)
+	var rme proof.RootMismatchError
+	if !errors.As(err, &rme) {
+		t.Fatalf("VerifyInclusion: %v, want RootMismatchError", err)
+	}
+	if err := proof.VerifyInclusion(hasher, index+1, size2, tree.LeafHash(index+1), incl, root2); err == nil {
+		t.Fatal("VerifyInclusion: want error")
+	}
+	if !bytes.Equal(rme.CalculatedRoot, root2) {
+		t.Errorf("RootMismatchError: got calculated root %x, want %x", rme.CalculatedRoot, root2)
+	}
+}
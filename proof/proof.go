@@ -20,8 +20,19 @@ import (
 	"math/bits"
 
 	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof/lite"
+	"github.com/transparency-dev/merkle/trace"
 )
 
+// MaxNodes is the buffer capacity sufficient for building the Nodes of any
+// inclusion or consistency proof without allocations.
+//
+// If the path from a node diverges from the right border of the tree at level
+// L, the nodes are: the seed node, at most L nodes on the path, at most L nodes
+// of the ephemeral node, and at most 64-L nodes to the left. For L <= 63, the
+// total is at most 128.
+const MaxNodes = 128
+
 // Nodes contains information on how to construct a log Merkle tree proof. It
 // supports any proof that has at most one ephemeral node, such as inclusion
 // and consistency proofs defined in RFC 6962.
@@ -46,21 +57,50 @@ type Nodes struct {
 // proof for the given leaf index in a log Merkle tree of the given size. It
 // requires 0 <= index < size.
 func Inclusion(index, size uint64) (Nodes, error) {
+	return InclusionTo(nil, index, size)
+}
+
+// InclusionTo is like Inclusion, but stores the node IDs in the given buffer,
+// overwriting its contents. No allocations are made if the buffer has at least
+// MaxNodes capacity, e.g. if it is a slice of a [MaxNodes]compact.NodeID array.
+// The returned Nodes share the buffer.
+func InclusionTo(buf []compact.NodeID, index, size uint64) (Nodes, error) {
 	if index >= size {
 		return Nodes{}, fmt.Errorf("index %d out of bounds for tree size %d", index, size)
 	}
-	return nodes(index, 0, size).skipFirst(), nil
+	return nodesTo(buf, index, 0, size).skipFirst(), nil
+}
+
+// NodeInclusion returns the information on how to fetch and construct an
+// inclusion proof for the given perfect node in a log Merkle tree of the given
+// size. The node must be fully covered by the tree, i.e. all its leaves must be
+// within [0, size). For leaves, this is equivalent to Inclusion.
+func NodeInclusion(id compact.NodeID, size uint64) (Nodes, error) {
+	if id.Index >= size>>id.Level {
+		return Nodes{}, fmt.Errorf("node %+v out of bounds for tree size %d", id, size)
+	}
+	return nodes(id.Index, id.Level, size).skipFirst(), nil
 }
 
 // Consistency returns the information on how to fetch and construct a
 // consistency proof between the two given tree sizes of a log Merkle tree. It
 // requires 0 <= size1 <= size2.
 func Consistency(size1, size2 uint64) (Nodes, error) {
+	return ConsistencyTo(nil, size1, size2)
+}
+
+// ConsistencyTo is like Consistency, but stores the node IDs in the given
+// buffer, overwriting its contents. No allocations are made if the buffer has
+// at least MaxNodes capacity. The returned Nodes share the buffer.
+func ConsistencyTo(buf []compact.NodeID, size1, size2 uint64) (Nodes, error) {
 	if size1 > size2 {
 		return Nodes{}, fmt.Errorf("tree size %d > %d", size1, size2)
 	}
 	if size1 == size2 || size1 == 0 {
-		return Nodes{IDs: []compact.NodeID{}}, nil
+		if buf == nil {
+			return Nodes{IDs: []compact.NodeID{}}, nil
+		}
+		return Nodes{IDs: buf[:0]}, nil
 	}
 
 	// Find the root of the biggest perfect subtree that ends at size1.
@@ -70,7 +110,7 @@ func Consistency(size1, size2 uint64) (Nodes, error) {
 	// two, in which case adding this node would be redundant because the client
 	// is assumed to know it from a checkpoint), and nodes of the inclusion proof
 	// into this node in the tree of size2.
-	p := nodes(index, level, size2)
+	p := nodesTo(buf, index, level, size2)
 
 	// Handle the case when size1 is a power of 2.
 	if index == 0 {
@@ -79,9 +119,195 @@ func Consistency(size1, size2 uint64) (Nodes, error) {
 	return p, nil
 }
 
+// InclusionSize returns the number of hashes in the inclusion proof for the
+// given leaf index in a log Merkle tree of the given size, without building
+// the proof. It requires 0 <= index < size.
+func InclusionSize(index, size uint64) (int, error) {
+	if index >= size {
+		return 0, fmt.Errorf("index %d out of bounds for tree size %d", index, size)
+	}
+	inner, border := lite.DecompInclProof(index, size)
+	return inner + border, nil
+}
+
+// ConsistencySize returns the number of hashes in the consistency proof between
+// the two given tree sizes of a log Merkle tree, without building the proof. It
+// requires 0 <= size1 <= size2.
+func ConsistencySize(size1, size2 uint64) (int, error) {
+	if size1 > size2 {
+		return 0, fmt.Errorf("tree size %d > %d", size1, size2)
+	}
+	if size1 == size2 || size1 == 0 {
+		return 0, nil
+	}
+	// See Consistency for the structure of the proof.
+	level := uint(bits.TrailingZeros64(size1))
+	index := (size1 - 1) >> level
+	inner, border := lite.DecompInclProof(index, (size2-1)>>level+1)
+	if index == 0 {
+		return inner + border, nil
+	}
+	return 1 + inner + border, nil
+}
+
+// InclusionBytes is like InclusionSize, but returns the total size of the
+// proof hashes in bytes, given the size of one hash.
+func InclusionBytes(index, size uint64, hashSize int) (int, error) {
+	n, err := InclusionSize(index, size)
+	return n * hashSize, err
+}
+
+// ConsistencyBytes is like ConsistencySize, but returns the total size of the
+// proof hashes in bytes, given the size of one hash.
+func ConsistencyBytes(size1, size2 uint64, hashSize int) (int, error) {
+	n, err := ConsistencySize(size1, size2)
+	return n * hashSize, err
+}
+
+// InclusionAndConsistency returns the information on how to fetch and
+// construct a combined proof that the leaf at the given index is included into
+// the tree of size1, and that this tree is consistent with the tree of size2.
+// It requires 0 <= index < size1 <= size2.
+//
+// The returned Nodes are the inclusion and consistency parts of the proof
+// correspondingly, see the Combined type.
+func InclusionAndConsistency(index, size1, size2 uint64) (Nodes, Nodes, error) {
+	if size1 > size2 {
+		return Nodes{}, Nodes{}, fmt.Errorf("tree size %d > %d", size1, size2)
+	}
+	incl, err := Inclusion(index, size1)
+	if err != nil {
+		return Nodes{}, Nodes{}, err
+	}
+	cons, err := Consistency(size1, size2)
+	if err != nil {
+		return Nodes{}, Nodes{}, err
+	}
+	return incl, cons, nil
+}
+
+// ShardedInclusion returns the information on how to fetch and construct a
+// proof that the leaf at the given index is included into the shard tree of
+// the given size, and that the root of this shard is included into the
+// super-tree of the given size at the given index. It requires
+// 0 <= index < shardSize and 0 <= shard < superSize.
+//
+// The super-tree is a log Merkle tree whose leaves are the shard root hashes,
+// i.e. the hash of shard's leaf is HashLeaf(shardRoot). The returned Nodes are
+// the shard and the super-tree parts of the proof correspondingly, see the
+// Sharded type. Note that the nodes of the two parts belong to different trees.
+func ShardedInclusion(index, shardSize, shard, superSize uint64) (Nodes, Nodes, error) {
+	leaf, err := Inclusion(index, shardSize)
+	if err != nil {
+		return Nodes{}, Nodes{}, err
+	}
+	root, err := Inclusion(shard, superSize)
+	if err != nil {
+		return Nodes{}, Nodes{}, err
+	}
+	return leaf, root, nil
+}
+
+// InclusionInRange returns the information on how to fetch and construct an
+// inclusion proof for the given leaf index into the [begin, end) compact range,
+// rather than a whole tree. It requires begin <= index < end.
+//
+// The proof consists of the siblings of the nodes on the path from the leaf up
+// to the root of the compact range's perfect subtree which contains it. It has
+// no ephemeral nodes.
+func InclusionInRange(index, begin, end uint64) (Nodes, error) {
+	if index < begin || index >= end {
+		return Nodes{}, fmt.Errorf("index %d out of bounds for range [%d, %d)", index, begin, end)
+	}
+	_, root := rangeNode(begin, end, index)
+	ids := make([]compact.NodeID, 0, root.Level)
+	for node := compact.NewNodeID(0, index); node.Level < root.Level; node = node.Parent() {
+		ids = append(ids, node.Sibling())
+	}
+	return Nodes{IDs: ids}, nil
+}
+
+// RangeInclusion returns the information on how to fetch and construct a proof
+// that the [begin, end) compact range is a part of the log Merkle tree of the
+// given size. It requires begin <= end <= size.
+//
+// The proof consists of the nodes of the [0, begin) and [end, size) compact
+// ranges, ordered from left to right, and has no ephemeral nodes. Together
+// with the range itself, they cover the whole tree, so the verifier can
+// compute its root hash, see VerifyRangeInclusion.
+//
+// Two parties holding different ranges, e.g. [a, b) and [c, d), can check that
+// their ranges belong to the same tree by verifying such proofs against the
+// same root hash. If the ranges are adjacent, i.e. b == c, then the parties
+// can share a part of the proofs: the [0, c) compact range needed by the party
+// holding [c, d) is the merge of [0, a) from the other party's proof, and the
+// other party's [a, b) range. Symmetrically, the [b, size) compact range is the
+// merge of the [c, d) range and [d, size) from the second party's proof.
+func RangeInclusion(begin, end, size uint64) (Nodes, error) {
+	if begin > end || end > size {
+		return Nodes{}, fmt.Errorf("range [%d, %d) out of bounds for tree size %d", begin, end, size)
+	}
+	ids := make([]compact.NodeID, 0, compact.RangeSize(0, begin)+compact.RangeSize(end, size))
+	ids = compact.RangeNodes(0, begin, ids)
+	ids = compact.RangeNodes(end, size, ids)
+	return Nodes{IDs: ids}, nil
+}
+
+// NonInclusion returns the information on how to fetch and construct a proof
+// that a value is not in a log of the given size whose leaves are sorted by
+// their data. The pos argument is the position at which the value would be in
+// the log, i.e. the number of leaves smaller than it. Requires 0 <= pos <= size.
+//
+// The proof is the RangeInclusion proof for the leaves adjacent to this
+// position, i.e. the [pos-1, pos+1) range clipped to [0, size). The verifier
+// also needs the data of these leaves, see VerifyNonInclusion.
+func NonInclusion(pos, size uint64) (Nodes, error) {
+	if pos > size {
+		return Nodes{}, fmt.Errorf("position %d out of bounds for tree size %d", pos, size)
+	}
+	begin, end := adjacent(pos, size)
+	return RangeInclusion(begin, end, size)
+}
+
+// adjacent returns the [begin, end) range of leaves adjacent to the given
+// position in the tree of the given size. Requires pos <= size.
+func adjacent(pos, size uint64) (uint64, uint64) {
+	begin, end := pos, pos
+	if pos > 0 {
+		begin--
+	}
+	if pos < size {
+		end++
+	}
+	return begin, end
+}
+
+// rangeNode returns the position and the ID of the node of the [begin, end)
+// compact range which covers the given leaf index. Requires that the range
+// contains the index.
+func rangeNode(begin, end, index uint64) (int, compact.NodeID) {
+	for i, id := range compact.RangeNodes(begin, end, nil) {
+		if b, e := id.Coverage(); index >= b && index < e {
+			return i, id
+		}
+	}
+	panic(fmt.Sprintf("index %d not in range [%d, %d)", index, begin, end))
+}
+
 // nodes returns the node IDs necessary to prove that the (level, index) node
 // is included in the Merkle tree of the given size.
 func nodes(index uint64, level uint, size uint64) Nodes {
+	return nodesTo(nil, index, level, size)
+}
+
+// nodesTo is like nodes, but stores the node IDs in the given buffer,
+// overwriting its contents. If the buffer is nil, it allocates the exact
+// number of IDs. Otherwise, no allocations are made if the buffer has at least
+// MaxNodes capacity.
+func nodesTo(buf []compact.NodeID, index uint64, level uint, size uint64) Nodes {
+	if size&(size-1) == 0 {
+		return perfectNodes(buf, index, level, size)
+	}
 	// Compute the `fork` node, where the path from root to (level, index) node
 	// diverges from the path to (0, size).
 	//
@@ -105,7 +331,10 @@ func nodes(index uint64, level uint, size uint64) Nodes {
 	// - The `inner` nodes at each level up to the fork node.
 	// - The `right` nodes, comprising the ephemeral node.
 	// - The `left` nodes, completing the coverage of the whole [0, size) range.
-	nodes := append(make([]compact.NodeID, 0, 1+inner+right+left), node)
+	if buf == nil {
+		buf = make([]compact.NodeID, 0, 1+inner+right+left)
+	}
+	nodes := append(buf[:0], node)
 
 	// The first portion of the proof consists of the siblings for nodes of the
 	// path going up to the level at which the ephemeral node appears.
@@ -137,6 +366,25 @@ func nodes(index uint64, level uint, size uint64) Nodes {
 	return Nodes{IDs: nodes, begin: len1, end: len2, ephem: fork.Sibling()}
 }
 
+// perfectNodes is the fast path of the nodes function for the case when the
+// tree size is a power of two. Such a tree is perfect, so the proof consists of
+// only the siblings of the nodes on the path to the root, and has no ephemeral
+// nodes.
+func perfectNodes(buf []compact.NodeID, index uint64, level uint, size uint64) Nodes {
+	height := uint(bits.TrailingZeros64(size))
+	node := compact.NewNodeID(level, index)
+	if buf == nil {
+		buf = make([]compact.NodeID, 0, 1+height-level)
+	}
+	nodes := append(buf[:0], node)
+	for ; node.Level < height; node = node.Parent() {
+		nodes = append(nodes, node.Sibling())
+	}
+	// For consistency with the general case, the ephemeral node is set to the
+	// sibling of the root, although it is not used in the proof.
+	return Nodes{IDs: nodes, ephem: compact.NewNodeID(height, 1)}
+}
+
 // Ephem returns the ephemeral node, and indices begin and end, such that
 // IDs[begin:end] slice contains the child nodes of the ephemeral node.
 //
@@ -147,20 +395,94 @@ func (n Nodes) Ephem() (compact.NodeID, int, int) {
 	return n.ephem, n.begin, n.end
 }
 
+// EphemNode returns the ID of the ephemeral node of the proof, and the
+// sub-slice of IDs containing the nodes from which its hash is recomputed by
+// Rehash, ordered from lower to upper levels. The last return value is false
+// iff the proof has no ephemeral node, in which case the other values are
+// empty.
+//
+// The returned sub-slice shares the underlying array with n.IDs.
+func (n Nodes) EphemNode() (compact.NodeID, []compact.NodeID, bool) {
+	if n.begin >= n.end {
+		return compact.NodeID{}, nil, false
+	}
+	return n.ephem, n.IDs[n.begin:n.end], true
+}
+
+// Path returns the IDs of the nodes whose hashes form the proof, in the proof
+// order. It is like IDs, but the nodes from which the ephemeral node is
+// recomputed are replaced by the ephemeral node itself. This suits the storage
+// backends which store or compute the ephemeral node hashes on their own: the
+// proof is the list of the node hashes, and needs no Rehash.
+//
+// The ephemeral node is only returned if it has more than one child in IDs.
+// Otherwise, its hash is the same as the child's, so the child is returned.
+func (n Nodes) Path() []compact.NodeID {
+	if n.end-n.begin <= 1 {
+		return append([]compact.NodeID(nil), n.IDs...)
+	}
+	ids := make([]compact.NodeID, 0, len(n.IDs)-(n.end-n.begin)+1)
+	ids = append(ids, n.IDs[:n.begin]...)
+	ids = append(ids, n.ephem)
+	return append(ids, n.IDs[n.end:]...)
+}
+
+// InclusionPath returns the IDs of the nodes whose hashes form the inclusion
+// proof for the given leaf index in the tree of the given size. The IDs may
+// include an ephemeral node, see Nodes.Path.
+func InclusionPath(index, size uint64) ([]compact.NodeID, error) {
+	n, err := Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+	return n.Path(), nil
+}
+
+// ConsistencyPath returns the IDs of the nodes whose hashes form the
+// consistency proof between the two given tree sizes. The IDs may include an
+// ephemeral node, see Nodes.Path.
+func ConsistencyPath(size1, size2 uint64) ([]compact.NodeID, error) {
+	n, err := Consistency(size1, size2)
+	if err != nil {
+		return nil, err
+	}
+	return n.Path(), nil
+}
+
 // Rehash computes the proof based on the slice of node hashes corresponding to
 // their IDs in the n.IDs field. The slices must be of the same length. The hc
 // parameter computes a node's hash based on hashes of its children.
 //
-// Warning: The passed-in slice of hashes can be modified in-place.
+// Warning: The passed-in slice of hashes can be modified in-place. Use RehashTo
+// to keep it intact.
 func (n Nodes) Rehash(h [][]byte, hc func(left, right []byte) []byte) ([][]byte, error) {
+	return n.RehashTo(h[:0], h, hc)
+}
+
+// Trace reports the proof nodes to the tracer, and returns the hash function
+// which also reports each node hash computed by hc. Pass it to Rehash or
+// RehashTo to trace the computation of the proof.
+func (n Nodes) Trace(tracer trace.Tracer, hc func(left, right []byte) []byte) func(left, right []byte) []byte {
+	tracer.Nodes(n.IDs, n.begin, n.end, n.ephem)
+	return func(left, right []byte) []byte {
+		hash := hc(left, right)
+		tracer.Hash(left, right, hash)
+		return hash
+	}
+}
+
+// RehashTo is like Rehash, but appends the resulting proof to dst, and returns
+// the new slice. The passed-in slice of hashes is not modified, unless it
+// shares the underlying array with dst. The caller may pass in dst[:0] to reuse
+// the same buffer across calls.
+func (n Nodes) RehashTo(dst, h [][]byte, hc func(left, right []byte) []byte) ([][]byte, error) {
 	if got, want := len(h), len(n.IDs); got != want {
 		return nil, fmt.Errorf("got %d hashes but expected %d", got, want)
 	}
-	cursor := 0
-	// Scan the list of node hashes, and store the rehashed list in-place.
-	// Invariant: cursor <= i, and h[:cursor] contains all the hashes of the
-	// rehashed list after scanning h up to index i-1.
-	for i, ln := 0, len(h); i < ln; i, cursor = i+1, cursor+1 {
+	// Scan the list of node hashes, and append the rehashed list to dst. Note
+	// that h[i] is always read before the corresponding dst element is written,
+	// so this works in-place as well, i.e. if dst is h[:0].
+	for i, ln := 0, len(h); i < ln; i++ {
 		hash := h[i]
 		if i >= n.begin && i < n.end {
 			// Scan the block of node hashes that need rehashing.
@@ -169,9 +491,40 @@ func (n Nodes) Rehash(h [][]byte, hc func(left, right []byte) []byte) ([][]byte,
 			}
 			i--
 		}
-		h[cursor] = hash
+		dst = append(dst, hash)
+	}
+	return dst, nil
+}
+
+// Tile identifies a group of proof nodes located in the same tile. A tile of
+// height h is a perfect subtree of height h, such that its root is at a level
+// which is a multiple of h. The tile contains all the nodes of this subtree,
+// except the root, which belongs to the tile above.
+type Tile struct {
+	// Root is the ID of the tile's root node.
+	Root compact.NodeID
+	// Pos contains the positions in the Nodes.IDs slice of all the proof nodes
+	// located in this tile, in increasing order.
+	Pos []int
+}
+
+// Tiles groups the proof nodes by the enclosing tiles of the given height, so
+// that each tile can be fetched from storage at once. The tiles are ordered by
+// the first occurrence of their nodes in the IDs slice. Requires height > 0.
+func (n Nodes) Tiles(height uint) []Tile {
+	var tiles []Tile
+	pos := make(map[compact.NodeID]int, len(n.IDs))
+	for i, id := range n.IDs {
+		root := id.TileRoot(height)
+		idx, ok := pos[root]
+		if !ok {
+			idx = len(tiles)
+			pos[root] = idx
+			tiles = append(tiles, Tile{Root: root})
+		}
+		tiles[idx].Pos = append(tiles[idx].Pos, i)
 	}
-	return h[:cursor], nil
+	return tiles
 }
 
 func (n Nodes) skipFirst() Nodes {
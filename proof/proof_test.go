@@ -15,7 +15,10 @@
 package proof
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"math"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -248,6 +251,41 @@ func TestInclusionSucceedsUpToTreeSize(t *testing.T) {
 	}
 }
 
+func TestNodeInclusion(t *testing.T) {
+	for size := uint64(1); size <= 70; size++ {
+		for index := uint64(0); index < size; index++ {
+			want := inclusion(t, index, size)
+			got, err := NodeInclusion(compact.NewNodeID(0, index), size)
+			if err != nil {
+				t.Fatalf("NodeInclusion: %v", err)
+			}
+			if diff := cmp.Diff(got, want, cmp.AllowUnexported(Nodes{})); diff != "" {
+				t.Fatalf("NodeInclusion(0:%d, %d): diff(-got +want):\n%s", index, size, diff)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		id      compact.NodeID
+		size    uint64
+		wantErr bool
+	}{
+		{id: compact.NewNodeID(2, 1), size: 8},
+		{id: compact.NewNodeID(2, 1), size: 7, wantErr: true},
+		{id: compact.NewNodeID(3, 0), size: 8},
+		{id: compact.NewNodeID(4, 0), size: 8, wantErr: true},
+		{id: compact.NewNodeID(64, 0), size: ^uint64(0), wantErr: true},
+		{id: compact.NewNodeID(63, 0), size: ^uint64(0)},
+	} {
+		t.Run(fmt.Sprintf("%d:%d/%d", tc.id.Level, tc.id.Index, tc.size), func(t *testing.T) {
+			_, err := NodeInclusion(tc.id, tc.size)
+			if got, want := err != nil, tc.wantErr; got != want {
+				t.Errorf("NodeInclusion: %v, wantErr %v", err, want)
+			}
+		})
+	}
+}
+
 func TestConsistencySucceedsUpToTreeSize(t *testing.T) {
 	const maxSize = uint64(100)
 	for s1 := uint64(1); s1 < maxSize; s1++ {
@@ -305,6 +343,36 @@ func TestEphem(t *testing.T) {
 	}
 }
 
+func TestEphemNode(t *testing.T) {
+	id := compact.NewNodeID
+	for _, tc := range []struct {
+		index, size uint64
+		wantID      compact.NodeID
+		wantIDs     []compact.NodeID
+	}{
+		{index: 3, size: 32},
+		{index: 12, size: 13},
+		{index: 6, size: 7},
+		{index: 1, size: 3, wantID: id(1, 1), wantIDs: []compact.NodeID{id(0, 2)}},
+		{index: 0, size: 7, wantID: id(2, 1), wantIDs: []compact.NodeID{id(0, 6), id(1, 2)}},
+		{index: 81, size: 95, wantID: id(3, 11), wantIDs: []compact.NodeID{id(0, 94), id(1, 46), id(2, 22)}},
+	} {
+		t.Run(fmt.Sprintf("%d:%d", tc.index, tc.size), func(t *testing.T) {
+			n := inclusion(t, tc.index, tc.size)
+			gotID, gotIDs, ok := n.EphemNode()
+			if want := tc.wantIDs != nil; ok != want {
+				t.Fatalf("EphemNode: got ok=%v, want %v", ok, want)
+			}
+			if gotID != tc.wantID {
+				t.Errorf("EphemNode: got ID %+v, want %+v", gotID, tc.wantID)
+			}
+			if diff := cmp.Diff(tc.wantIDs, gotIDs); diff != "" {
+				t.Errorf("EphemNode: diff(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestRehash(t *testing.T) {
 	th := rfc6962.DefaultHasher
 	h := [][]byte{
@@ -350,6 +418,20 @@ func TestRehash(t *testing.T) {
 				t.Errorf("proofs mismatch:\ngot: %x\nwant: %x", got, want)
 			}
 		})
+		t.Run(tc.desc+"-to", func(t *testing.T) {
+			h := append([][]byte{}, tc.hashes...)
+			dst := [][]byte{h[0]}
+			got, err := tc.nodes.RehashTo(dst, h, th.HashChildren)
+			if err != nil {
+				t.Fatalf("RehashTo: %v", err)
+			}
+			if want := append([][]byte{h[0]}, tc.want...); !cmp.Equal(got, want) {
+				t.Errorf("proofs mismatch:\ngot: %x\nwant: %x", got, want)
+			}
+			if !cmp.Equal(h, tc.hashes) {
+				t.Errorf("RehashTo modified the input:\ngot: %x\nwant: %x", h, tc.hashes)
+			}
+		})
 	}
 }
 
@@ -361,3 +443,288 @@ func inclusion(t *testing.T, index, size uint64) Nodes {
 	}
 	return n
 }
+
+func TestTiles(t *testing.T) {
+	id := compact.NewNodeID
+	for _, tc := range []struct {
+		desc   string
+		ids    []compact.NodeID
+		height uint
+		want   []Tile
+	}{
+		{desc: "empty", height: 8, want: nil},
+		{
+			desc:   "height-1",
+			ids:    []compact.NodeID{id(0, 1), id(1, 1), id(2, 1)},
+			height: 1,
+			want: []Tile{
+				{Root: id(1, 0), Pos: []int{0}},
+				{Root: id(2, 0), Pos: []int{1}},
+				{Root: id(3, 0), Pos: []int{2}},
+			},
+		},
+		{
+			desc:   "height-2",
+			ids:    []compact.NodeID{id(0, 5), id(1, 3), id(2, 0), id(3, 1), id(0, 12)},
+			height: 2,
+			want: []Tile{
+				{Root: id(2, 1), Pos: []int{0, 1}},
+				{Root: id(4, 0), Pos: []int{2, 3}},
+				{Root: id(2, 3), Pos: []int{4}},
+			},
+		},
+		{
+			desc:   "height-8",
+			ids:    []compact.NodeID{id(0, 300), id(1, 151), id(7, 3), id(8, 0), id(9, 3), id(16, 1)},
+			height: 8,
+			want: []Tile{
+				{Root: id(8, 1), Pos: []int{0, 1, 2}},
+				{Root: id(16, 0), Pos: []int{3, 4}},
+				{Root: id(24, 0), Pos: []int{5}},
+			},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			got := Nodes{IDs: tc.ids}.Tiles(tc.height)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("Tiles: diff(-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTilesCoverProof(t *testing.T) {
+	for _, height := range []uint{1, 2, 3, 8} {
+		for size := uint64(1); size <= 300; size += 7 {
+			for index := uint64(0); index < size; index += 3 {
+				n := inclusion(t, index, size)
+				seen := make([]bool, len(n.IDs))
+				for _, tile := range n.Tiles(height) {
+					begin, end := tile.Root.Coverage()
+					for _, pos := range tile.Pos {
+						id := n.IDs[pos]
+						if id.Level >= tile.Root.Level || id.Level+height < tile.Root.Level {
+							t.Errorf("node %+v is not in tile %+v of height %d", id, tile.Root, height)
+						}
+						if b, e := id.Coverage(); b < begin || e > end {
+							t.Errorf("node %+v is not under tile %+v", id, tile.Root)
+						}
+						seen[pos] = true
+					}
+				}
+				for pos, ok := range seen {
+					if !ok {
+						t.Errorf("Tiles(%d) for %d:%d: node %+v not covered", height, index, size, n.IDs[pos])
+					}
+				}
+			}
+		}
+	}
+}
+
+// proofSize returns the number of hashes in the proof built from the given
+// nodes with Rehash.
+func proofSize(n Nodes) int {
+	if _, ids, ok := n.EphemNode(); ok {
+		return len(n.IDs) - len(ids) + 1
+	}
+	return len(n.IDs)
+}
+
+func TestProofSize(t *testing.T) {
+	const maxSize = 130
+	for size := uint64(1); size <= maxSize; size++ {
+		for index := uint64(0); index < size; index++ {
+			nodes, err := Inclusion(index, size)
+			if err != nil {
+				t.Fatalf("Inclusion: %v", err)
+			}
+			got, err := InclusionSize(index, size)
+			if err != nil {
+				t.Fatalf("InclusionSize: %v", err)
+			}
+			if want := proofSize(nodes); got != want {
+				t.Errorf("InclusionSize(%d, %d): got %d, want %d", index, size, got, want)
+			}
+		}
+		for size1 := uint64(0); size1 <= size; size1++ {
+			nodes, err := Consistency(size1, size)
+			if err != nil {
+				t.Fatalf("Consistency: %v", err)
+			}
+			got, err := ConsistencySize(size1, size)
+			if err != nil {
+				t.Fatalf("ConsistencySize: %v", err)
+			}
+			if want := proofSize(nodes); got != want {
+				t.Errorf("ConsistencySize(%d, %d): got %d, want %d", size1, size, got, want)
+			}
+		}
+	}
+
+	if got, err := InclusionBytes(5, 7, 32); err != nil || got != 3*32 {
+		t.Errorf("InclusionBytes: got %d, %v; want %d", got, err, 3*32)
+	}
+	if got, err := ConsistencyBytes(6, 8, 32); err != nil || got != 3*32 {
+		t.Errorf("ConsistencyBytes: got %d, %v; want %d", got, err, 3*32)
+	}
+	if _, err := InclusionSize(7, 7); err == nil {
+		t.Error("InclusionSize: want error")
+	}
+	if _, err := ConsistencySize(8, 7); err == nil {
+		t.Error("ConsistencySize: want error")
+	}
+	if got, err := ConsistencySize(1<<63, ^uint64(0)); err != nil || got != 1 {
+		t.Errorf("ConsistencySize: got %d, %v; want 1", got, err)
+	}
+}
+
+func TestNodesToNoAllocs(t *testing.T) {
+	var buf [MaxNodes]compact.NodeID
+	for _, size := range []uint64{1, 2, 7, 1 << 20, 1<<20 + 1, 1<<63 + 1, math.MaxUint64} {
+		for _, index := range []uint64{0, 1, size / 2, size - 2, size - 1} {
+			if index >= size {
+				continue
+			}
+			want, err := Inclusion(index, size)
+			if err != nil {
+				t.Fatalf("Inclusion: %v", err)
+			}
+			got, err := InclusionTo(buf[:0], index, size)
+			if err != nil {
+				t.Fatalf("InclusionTo: %v", err)
+			}
+			if diff := cmp.Diff(got, want, cmp.AllowUnexported(Nodes{})); diff != "" {
+				t.Errorf("InclusionTo(%d, %d): diff(-got +want):\n%s", index, size, diff)
+			}
+			if allocs := testing.AllocsPerRun(10, func() {
+				_, _ = InclusionTo(buf[:0], index, size)
+			}); allocs != 0 {
+				t.Errorf("InclusionTo(%d, %d): %v allocs, want 0", index, size, allocs)
+			}
+
+			size1 := index + 1
+			want, err = Consistency(size1, size)
+			if err != nil {
+				t.Fatalf("Consistency: %v", err)
+			}
+			got, err = ConsistencyTo(buf[:0], size1, size)
+			if err != nil {
+				t.Fatalf("ConsistencyTo: %v", err)
+			}
+			if diff := cmp.Diff(got, want, cmp.AllowUnexported(Nodes{})); diff != "" {
+				t.Errorf("ConsistencyTo(%d, %d): diff(-got +want):\n%s", size1, size, diff)
+			}
+			if allocs := testing.AllocsPerRun(10, func() {
+				_, _ = ConsistencyTo(buf[:0], size1, size)
+			}); allocs != 0 {
+				t.Errorf("ConsistencyTo(%d, %d): %v allocs, want 0", size1, size, allocs)
+			}
+		}
+	}
+}
+
+func BenchmarkInclusion(b *testing.B) {
+	for _, size := range []uint64{1 << 20, 1<<20 + 12345} {
+		b.Run(fmt.Sprintf("size:%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Inclusion(uint64(i)%size, size); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConsistency(b *testing.B) {
+	for _, size := range []uint64{1 << 20, 1<<20 + 12345} {
+		b.Run(fmt.Sprintf("size:%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := Consistency(uint64(i)%size+1, size); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// countHasher is a toy LogHasher in which the hash of a node is the number of
+// leaves that it covers. It allows checking proofs for huge trees, for which
+// the real node hashes can not be computed.
+type countHasher struct{}
+
+func (countHasher) EmptyRoot() []byte           { return countHash(0) }
+func (countHasher) HashLeaf(leaf []byte) []byte { return countHash(1) }
+func (countHasher) Size() int                   { return 8 }
+func (countHasher) HashChildren(l, r []byte) []byte {
+	return countHash(binary.BigEndian.Uint64(l) + binary.BigEndian.Uint64(r))
+}
+
+func countHash(count uint64) []byte {
+	var hash [8]byte
+	binary.BigEndian.PutUint64(hash[:], count)
+	return hash[:]
+}
+
+func TestHugeTrees(t *testing.T) {
+	hasher := countHasher{}
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	// getHashes returns the node hashes for the given IDs. All the nodes in a
+	// proof are perfect, so the hash is the number of leaves they cover.
+	getHashes := func(ids []compact.NodeID) [][]byte {
+		hashes := make([][]byte, len(ids))
+		for i, id := range ids {
+			hashes[i] = countHash(1 << id.Level)
+		}
+		return hashes
+	}
+	fetch := func(id compact.NodeID) ([]byte, error) {
+		return countHash(1 << id.Level), nil
+	}
+
+	for _, size := range []uint64{1<<63 - 1, 1 << 63, 1<<63 + 1, math.MaxUint64 - 1, math.MaxUint64} {
+		for _, index := range []uint64{0, 1, 1 << 62, size / 2, size - 2, size - 1} {
+			t.Run(fmt.Sprintf("%d:%d", index, size), func(t *testing.T) {
+				nodes, err := Inclusion(index, size)
+				if err != nil {
+					t.Fatalf("Inclusion: %v", err)
+				}
+				for _, id := range nodes.IDs {
+					if begin, end := id.Coverage(); end <= begin || end > size {
+						t.Errorf("Inclusion: node %+v not in the tree", id)
+					}
+				}
+				proof, err := nodes.Rehash(getHashes(nodes.IDs), hasher.HashChildren)
+				if err != nil {
+					t.Fatalf("Rehash: %v", err)
+				}
+				if err := VerifyInclusion(hasher, index, size, countHash(1), proof, countHash(size)); err != nil {
+					t.Errorf("VerifyInclusion: %v", err)
+				}
+
+				size1 := index + 1
+				if nodes, err = Consistency(size1, size); err != nil {
+					t.Fatalf("Consistency: %v", err)
+				}
+				if proof, err = nodes.Rehash(getHashes(nodes.IDs), hasher.HashChildren); err != nil {
+					t.Fatalf("Rehash: %v", err)
+				}
+				if err := VerifyConsistency(hasher, size1, size, proof, countHash(size1), countHash(size)); err != nil {
+					t.Errorf("VerifyConsistency: %v", err)
+				}
+
+				rng, err := rf.LoadRange(0, size, fetch)
+				if err != nil {
+					t.Fatalf("LoadRange: %v", err)
+				}
+				root, err := rng.GetRootHash(nil)
+				if err != nil {
+					t.Fatalf("GetRootHash: %v", err)
+				}
+				if got, want := root, countHash(size); !bytes.Equal(got, want) {
+					t.Errorf("GetRootHash: got %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
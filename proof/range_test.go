@@ -0,0 +1,242 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestInclusionInRange(t *testing.T) {
+	const size = 40
+	tree := newTree(size)
+	factory := &compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+	getHashes := func(ids []compact.NodeID) [][]byte {
+		hashes := make([][]byte, len(ids))
+		for i, id := range ids {
+			// The nodes are perfect, so their hashes are the same as the root hashes
+			// of the corresponding subtrees.
+			begin, end := id.Coverage()
+			sub := newTree(0)
+			for j := begin; j < end; j++ {
+				sub.Append(tree.LeafHash(j))
+			}
+			hashes[i] = sub.Hash()
+		}
+		return hashes
+	}
+
+	for begin := uint64(0); begin < size; begin++ {
+		for end := begin + 1; end <= size; end++ {
+			cr, err := factory.NewRange(begin, end, getHashes(compact.RangeNodes(begin, end, nil)))
+			if err != nil {
+				t.Fatalf("NewRange: %v", err)
+			}
+			for index := begin; index < end; index++ {
+				nodes, err := proof.InclusionInRange(index, begin, end)
+				if err != nil {
+					t.Fatalf("InclusionInRange: %v", err)
+				}
+				p, err := nodes.Rehash(getHashes(nodes.IDs), rfc6962.DefaultHasher.HashChildren)
+				if err != nil {
+					t.Fatalf("Rehash: %v", err)
+				}
+				leaf := tree.LeafHash(index)
+				if err := proof.VerifyInclusionInRange(rfc6962.DefaultHasher, index, leaf, p, cr); err != nil {
+					t.Errorf("VerifyInclusionInRange(%d, [%d, %d)): %v", index, begin, end, err)
+				}
+				// A wrong leaf or index does not verify.
+				if err := proof.VerifyInclusionInRange(rfc6962.DefaultHasher, index, tree.LeafHash((index+1)%size), p, cr); err == nil {
+					t.Errorf("VerifyInclusionInRange(%d, [%d, %d)): accepted wrong leaf", index, begin, end)
+				}
+				if len(p) != 0 {
+					if err := proof.VerifyInclusionInRange(rfc6962.DefaultHasher, index^1, leaf, p, cr); err == nil {
+						t.Errorf("VerifyInclusionInRange(%d, [%d, %d)): accepted wrong index", index, begin, end)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := proof.InclusionInRange(5, 6, 10); err == nil {
+		t.Error("InclusionInRange: accepted index out of range")
+	}
+	if _, err := proof.InclusionInRange(10, 6, 10); err == nil {
+		t.Error("InclusionInRange: accepted index out of range")
+	}
+}
+
+func TestVerifyAppend(t *testing.T) {
+	const maxSize = 40
+	tree := newTree(maxSize)
+	hasher := rfc6962.DefaultHasher
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	leafHashes := make([][]byte, maxSize)
+	for i := range leafHashes {
+		leafHashes[i] = tree.LeafHash(uint64(i))
+	}
+
+	for size1 := uint64(0); size1 <= maxSize; size1++ {
+		r := rf.NewEmptyRange(0)
+		for _, hash := range leafHashes[:size1] {
+			if err := r.Append(hash, nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		orig := r.Clone()
+		for size2 := size1; size2 <= maxSize; size2++ {
+			hashes, root2 := leafHashes[size1:size2], tree.HashAt(size2)
+			root, err := proof.RootAfterAppend(hasher, r, hashes)
+			if err != nil {
+				t.Fatalf("RootAfterAppend(%d, %d): %v", size1, size2, err)
+			}
+			if !bytes.Equal(root, root2) {
+				t.Errorf("RootAfterAppend(%d, %d): got %x, want %x", size1, size2, root, root2)
+			}
+
+			next, err := proof.VerifyAppend(hasher, r, hashes, size2, root2)
+			if err != nil {
+				t.Fatalf("VerifyAppend(%d, %d): %v", size1, size2, err)
+			}
+			if got, want := next.End(), size2; got != want {
+				t.Errorf("VerifyAppend(%d, %d): got range end %d, want %d", size1, size2, got, want)
+			}
+			if size2 > size1 {
+				if _, err := proof.VerifyAppend(hasher, r, hashes[1:], size2, root2); err == nil {
+					t.Errorf("VerifyAppend(%d, %d): want error for missing leaf", size1, size2)
+				}
+				if _, err := proof.VerifyAppend(hasher, r, hashes, size2, tree.HashAt(size1)); err == nil {
+					t.Errorf("VerifyAppend(%d, %d): want error for wrong root", size1, size2)
+				}
+			}
+		}
+		if !r.Equal(orig) {
+			t.Errorf("range [0, %d) was modified", size1)
+		}
+	}
+
+	if _, err := proof.RootAfterAppend(hasher, rf.NewEmptyRange(1), nil); err == nil {
+		t.Error("RootAfterAppend: want error for range not starting at 0")
+	}
+}
+
+func TestVerifyRangeInclusion(t *testing.T) {
+	const maxSize = 20
+	hasher := rfc6962.DefaultHasher
+	tree := newTree(maxSize)
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	// newRange returns the [begin, end) compact range of the test tree.
+	newRange := func(begin, end uint64) *compact.Range {
+		r := rf.NewEmptyRange(begin)
+		for i := begin; i < end; i++ {
+			if err := r.Append(tree.LeafHash(i), nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		return r
+	}
+
+	for size := uint64(0); size <= maxSize; size++ {
+		root := tree.HashAt(size)
+		for begin := uint64(0); begin <= size; begin++ {
+			for end := begin; end <= size; end++ {
+				nodes, err := proof.RangeInclusion(begin, end, size)
+				if err != nil {
+					t.Fatalf("RangeInclusion: %v", err)
+				}
+				p := append(newRange(0, begin).Hashes(), newRange(end, size).Hashes()...)
+				if got, want := len(nodes.IDs), len(p); got != want {
+					t.Fatalf("RangeInclusion(%d, %d, %d): got %d nodes, want %d", begin, end, size, got, want)
+				}
+				r := newRange(begin, end)
+				if err := proof.VerifyRangeInclusion(hasher, r, size, p, root); err != nil {
+					t.Errorf("VerifyRangeInclusion(%d, %d, %d): %v", begin, end, size, err)
+				}
+				if size == 0 {
+					continue
+				}
+				if err := proof.VerifyRangeInclusion(hasher, r, size, p, tree.HashAt(size-1)); err == nil {
+					t.Errorf("VerifyRangeInclusion(%d, %d, %d): want error for wrong root", begin, end, size)
+				}
+				if len(p) != 0 {
+					if err := proof.VerifyRangeInclusion(hasher, r, size, p[1:], root); err == nil {
+						t.Errorf("VerifyRangeInclusion(%d, %d, %d): want error for short proof", begin, end, size)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := proof.RangeInclusion(3, 2, 5); err == nil {
+		t.Error("RangeInclusion: want error for inverted range")
+	}
+	if _, err := proof.RangeInclusion(3, 6, 5); err == nil {
+		t.Error("RangeInclusion: want error for range beyond the tree")
+	}
+	if err := proof.VerifyRangeInclusion(hasher, newRange(3, 6), 5, nil, tree.HashAt(5)); err == nil {
+		t.Error("VerifyRangeInclusion: want error for range beyond the tree")
+	}
+}
+
+func TestVerifyConsistencyRange(t *testing.T) {
+	const size = 50
+	tree := newTree(size)
+	factory := &compact.RangeFactory{Hash: rfc6962.DefaultHasher.HashChildren}
+
+	for size1 := uint64(0); size1 <= size; size1++ {
+		cr := factory.NewEmptyRange(0)
+		for i := uint64(0); i < size1; i++ {
+			if err := cr.Append(tree.LeafHash(i), nil); err != nil {
+				t.Fatalf("Append: %v", err)
+			}
+		}
+		for size2 := size1; size2 <= size; size2++ {
+			p, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			if err := proof.VerifyConsistencyRange(rfc6962.DefaultHasher, cr, size2, p, tree.HashAt(size2)); err != nil {
+				t.Errorf("VerifyConsistencyRange(%d, %d): %v", size1, size2, err)
+			}
+		}
+	}
+
+	// A range which diverges from the tree does not verify.
+	cr := factory.NewEmptyRange(0)
+	for i := uint64(0); i < 10; i++ {
+		hash := tree.LeafHash(i)
+		if i == 3 {
+			hash = tree.LeafHash(4)
+		}
+		if err := cr.Append(hash, nil); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	p, err := tree.ConsistencyProof(10, size)
+	if err != nil {
+		t.Fatalf("ConsistencyProof: %v", err)
+	}
+	if err := proof.VerifyConsistencyRange(rfc6962.DefaultHasher, cr, size, p, tree.Hash()); err == nil {
+		t.Error("VerifyConsistencyRange: accepted divergent range")
+	}
+	// A range not starting at zero is rejected.
+	if err := proof.VerifyConsistencyRange(rfc6962.DefaultHasher, factory.NewEmptyRange(1), size, nil, tree.Hash()); err == nil {
+		t.Error("VerifyConsistencyRange: accepted range with begin=1")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"fmt"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// Refresh describes how to upgrade an inclusion proof valid at a tree size to
+// the proof for the same leaf at a bigger tree size. The hashes of the perfect
+// nodes shared by the two proofs are reused, and only the rest of the nodes
+// need to be fetched.
+type Refresh struct {
+	// IDs contains the IDs of the nodes that need to be fetched for the new
+	// proof, ordered as in the new proof.
+	IDs []compact.NodeID
+
+	nodes Nodes // The nodes of the new proof.
+	// reuse contains, for each of nodes.IDs, the position of its hash in the
+	// old proof, or -1 if the hash is fetched.
+	reuse []int
+	// ephem is the position of the ephemeral node hash in the old proof, if the
+	// new proof has the same ephemeral node computed from the same nodes, or -1.
+	ephem int
+	size  int // The expected size of the old proof.
+}
+
+// RefreshInclusion returns the information on how to upgrade the inclusion
+// proof for the given leaf index in the tree of size1 to the proof in the tree
+// of size2. Requires 0 <= index < size1 <= size2.
+func RefreshInclusion(index, size1, size2 uint64) (Refresh, error) {
+	if size1 > size2 {
+		return Refresh{}, fmt.Errorf("tree size %d > %d", size1, size2)
+	}
+	old, err := Inclusion(index, size1)
+	if err != nil {
+		return Refresh{}, err
+	}
+	nodes, err := Inclusion(index, size2)
+	if err != nil {
+		return Refresh{}, err
+	}
+
+	// Find the positions of the perfect nodes in the old proof. The ephemeral
+	// node of the old proof, if any, can be reused only if it is computed from
+	// the same nodes in the new proof. Otherwise, in the bigger tree it either
+	// has more leaves, or becomes a perfect node with a different hash. A window
+	// of a single node is not rehashed, so this node's hash is in the old proof.
+	pos := make(map[compact.NodeID]int, len(old.IDs))
+	for i, id := range old.IDs {
+		switch {
+		case i < old.begin || old.end-old.begin <= 1:
+			pos[id] = i
+		case i >= old.end:
+			pos[id] = i - (old.end - old.begin - 1)
+		}
+	}
+	size := len(old.IDs)
+	if old.begin < old.end {
+		size -= old.end - old.begin - 1
+	}
+
+	r := Refresh{nodes: nodes, reuse: make([]int, len(nodes.IDs)), ephem: -1, size: size}
+	if old.begin < old.end && sameIDs(old.IDs[old.begin:old.end], nodes.IDs[nodes.begin:nodes.end]) {
+		r.ephem = old.begin
+	}
+	for i, id := range nodes.IDs {
+		p, ok := pos[id]
+		if r.ephem >= 0 && i >= nodes.begin && i < nodes.end {
+			p, ok = -1, true // Not needed, the ephemeral node hash is reused.
+		}
+		if !ok {
+			p = -1
+			r.IDs = append(r.IDs, id)
+		}
+		r.reuse[i] = p
+	}
+	return r, nil
+}
+
+// Rehash builds the new proof from the old proof, and the hashes of the nodes
+// corresponding to r.IDs. The hc parameter computes a node's hash based on the
+// hashes of its children. The passed-in slices are not modified.
+func (r Refresh) Rehash(old, hashes [][]byte, hc func(left, right []byte) []byte) ([][]byte, error) {
+	if got, want := len(old), r.size; got != want {
+		return nil, fmt.Errorf("old proof has %d hashes, want %d", got, want)
+	} else if got, want := len(hashes), len(r.IDs); got != want {
+		return nil, fmt.Errorf("got %d hashes but expected %d", got, want)
+	}
+	all := make([][]byte, len(r.reuse))
+	for i, p := range r.reuse {
+		if p >= 0 {
+			all[i] = old[p]
+		} else if r.ephem < 0 || i < r.nodes.begin || i >= r.nodes.end {
+			all[i], hashes = hashes[0], hashes[1:]
+		}
+	}
+	if r.ephem < 0 {
+		return r.nodes.Rehash(all, hc)
+	}
+	res := make([][]byte, 0, len(all)-(r.nodes.end-r.nodes.begin-1))
+	res = append(res, all[:r.nodes.begin]...)
+	res = append(res, old[r.ephem])
+	return append(res, all[r.nodes.end:]...), nil
+}
+
+func sameIDs(a, b []compact.NodeID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, id := range a {
+		if id != b[i] {
+			return false
+		}
+	}
+	return true
+}
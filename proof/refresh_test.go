@@ -0,0 +1,102 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestRefreshInclusion(t *testing.T) {
+	const size = 24
+	store := newStore(t, size)
+	ref := testonly.GenTree(rfc6962.DefaultHasher, 0, size)
+	hc := rfc6962.DefaultHasher.HashChildren
+
+	for size1 := uint64(1); size1 <= size; size1++ {
+		for size2 := size1; size2 <= size; size2++ {
+			for index := uint64(0); index < size1; index++ {
+				r, err := proof.RefreshInclusion(index, size1, size2)
+				if err != nil {
+					t.Fatalf("RefreshInclusion(%d, %d, %d): %v", index, size1, size2, err)
+				}
+				full, err := proof.Inclusion(index, size2)
+				if err != nil {
+					t.Fatalf("Inclusion: %v", err)
+				}
+				if got, max := len(r.IDs), len(full.IDs); got > max {
+					t.Errorf("RefreshInclusion(%d, %d, %d): %d IDs, want <= %d", index, size1, size2, got, max)
+				} else if size1 == size2 && got != 0 {
+					t.Errorf("RefreshInclusion(%d, %d, %d): %d IDs, want 0", index, size1, size2, got)
+				}
+				// The nodes whose hashes are in the old proof are not fetched.
+				oldNodes, err := proof.Inclusion(index, size1)
+				if err != nil {
+					t.Fatalf("Inclusion: %v", err)
+				}
+				_, begin, end := oldNodes.Ephem()
+				for i, id := range oldNodes.IDs {
+					if i >= begin && i < end && end-begin > 1 {
+						continue // Only the hash of the whole window is in the old proof.
+					}
+					for _, fetched := range r.IDs {
+						if fetched == id {
+							t.Errorf("RefreshInclusion(%d, %d, %d): fetches node %v of the old proof", index, size1, size2, id)
+						}
+					}
+				}
+
+				hashes, err := store.Get(r.IDs)
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				old, _ := ref.InclusionProof(index, size1)
+				got, err := r.Rehash(old, hashes, hc)
+				if err != nil {
+					t.Fatalf("Rehash: %v", err)
+				}
+				want, _ := ref.InclusionProof(index, size2)
+				if diff := cmp.Diff(got, want); diff != "" {
+					t.Errorf("RefreshInclusion(%d, %d, %d): diff(-got +want):\n%s", index, size1, size2, diff)
+				}
+				if _, err := r.Rehash(append(old, old...), hashes, hc); err == nil && len(old) != 0 {
+					t.Errorf("Rehash: want error for long old proof")
+				}
+			}
+		}
+	}
+
+	// The old proof ends with node 1:2, the single node of its ephemeral window.
+	// In the new proof, it is rehashed with node 0:6, which is the only one to
+	// fetch.
+	r, err := proof.RefreshInclusion(0, 6, 7)
+	if err != nil {
+		t.Fatalf("RefreshInclusion: %v", err)
+	}
+	if diff := cmp.Diff(r.IDs, []compact.NodeID{compact.NewNodeID(0, 6)}); diff != "" {
+		t.Errorf("RefreshInclusion: diff(-got +want):\n%s", diff)
+	}
+
+	for _, tc := range [][3]uint64{{0, 0, 1}, {5, 5, 10}, {0, 10, 9}} {
+		if _, err := proof.RefreshInclusion(tc[0], tc[1], tc[2]); err == nil {
+			t.Errorf("RefreshInclusion(%d, %d, %d): want error", tc[0], tc[1], tc[2])
+		}
+	}
+}
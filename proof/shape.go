@@ -0,0 +1,61 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"fmt"
+
+	"github.com/transparency-dev/merkle/proof/lite"
+)
+
+// ErrMalformedProof is wrapped by the errors returned when a proof does not
+// have the shape expected for the tree parameters, e.g. has a wrong number of
+// hashes. Such proofs are rejected before doing any hashing.
+var ErrMalformedProof = lite.ErrMalformedProof
+
+// CheckInclusionShape checks that the inclusion proof has the shape expected
+// for the given leaf index in the tree of the given size: the right number of
+// hashes, each of hashSize bytes. It does no hashing, so it is cheap, but the
+// proof can still be invalid. Returns an error wrapping ErrMalformedProof if
+// the shape is wrong. Requires 0 <= index < size.
+func CheckInclusionShape(index, size uint64, proof [][]byte, hashSize int) error {
+	want, err := InclusionSize(index, size)
+	if err != nil {
+		return err
+	}
+	return checkShape(proof, want, hashSize)
+}
+
+// CheckConsistencyShape is like CheckInclusionShape, but for a consistency
+// proof between the two given tree sizes. Requires 0 <= size1 <= size2.
+func CheckConsistencyShape(size1, size2 uint64, proof [][]byte, hashSize int) error {
+	want, err := ConsistencySize(size1, size2)
+	if err != nil {
+		return err
+	}
+	return checkShape(proof, want, hashSize)
+}
+
+func checkShape(proof [][]byte, size, hashSize int) error {
+	if got := len(proof); got != size {
+		return fmt.Errorf("%w: wrong proof size %d, want %d", ErrMalformedProof, got, size)
+	}
+	for i, hash := range proof {
+		if got := len(hash); got != hashSize {
+			return fmt.Errorf("%w: hash %d has size %d, want %d", ErrMalformedProof, i, got, hashSize)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestCheckShape(t *testing.T) {
+	const size = 50
+	tree := newTree(size)
+	hasher := rfc6962.DefaultHasher
+	v := proof.Verifier{Hasher: hasher}
+	for index := uint64(0); index < size; index++ {
+		incl, err := tree.InclusionProof(index, size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		cons, err := tree.ConsistencyProof(index+1, size)
+		if err != nil {
+			t.Fatalf("ConsistencyProof: %v", err)
+		}
+		if err := proof.CheckInclusionShape(index, size, incl, hasher.Size()); err != nil {
+			t.Errorf("CheckInclusionShape(%d, %d): %v", index, size, err)
+		}
+		if err := proof.CheckConsistencyShape(index+1, size, cons, hasher.Size()); err != nil {
+			t.Errorf("CheckConsistencyShape(%d, %d): %v", index+1, size, err)
+		}
+
+		long := append(append([][]byte{}, incl...), incl...)
+		if len(incl) == 0 {
+			long = [][]byte{tree.Hash()}
+		}
+		if err := proof.CheckInclusionShape(index, size, long, hasher.Size()); !errors.Is(err, proof.ErrMalformedProof) {
+			t.Errorf("CheckInclusionShape(%d, %d): got %v, want %v", index, size, err, proof.ErrMalformedProof)
+		}
+		if err := proof.VerifyInclusion(hasher, index, size, tree.LeafHash(index), long, tree.Hash()); !errors.Is(err, proof.ErrMalformedProof) {
+			t.Errorf("VerifyInclusion(%d, %d): got %v, want %v", index, size, err, proof.ErrMalformedProof)
+		}
+		if len(cons) == 0 {
+			continue
+		}
+		short := append([][]byte{}, cons...)
+		short[0] = short[0][1:]
+		if err := proof.CheckConsistencyShape(index+1, size, short, hasher.Size()); !errors.Is(err, proof.ErrMalformedProof) {
+			t.Errorf("CheckConsistencyShape(%d, %d): got %v, want %v", index+1, size, err, proof.ErrMalformedProof)
+		}
+		if err := v.VerifyConsistency(index+1, size, short, tree.HashAt(index+1), tree.Hash()); !errors.Is(err, proof.ErrMalformedProof) {
+			t.Errorf("Verifier.VerifyConsistency(%d, %d): got %v, want %v", index+1, size, err, proof.ErrMalformedProof)
+		}
+		if err := proof.VerifyConsistency(hasher, index+1, size, cons[1:], tree.HashAt(index+1), tree.Hash()); !errors.Is(err, proof.ErrMalformedProof) {
+			t.Errorf("VerifyConsistency(%d, %d): got %v, want %v", index+1, size, err, proof.ErrMalformedProof)
+		}
+	}
+	if err := proof.CheckInclusionShape(size, size, nil, hasher.Size()); err == nil || errors.Is(err, proof.ErrMalformedProof) {
+		t.Errorf("CheckInclusionShape: got %v, want out of bounds error", err)
+	}
+}
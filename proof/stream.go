@@ -0,0 +1,122 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof/lite"
+)
+
+// RangeVerifier verifies that a range of leaves is included into a tree, like
+// VerifyRangeInclusion, but consumes the proof hashes and the leaf hashes
+// incrementally, e.g. as they arrive over the network. It keeps only the
+// compact range of the consumed part of the tree, i.e. O(log size) hashes, so
+// neither the proof nor the leaves need to be buffered in memory.
+//
+// The hashes must be pushed in the left to right order: first, the hashes of
+// the [0, begin) compact range nodes, then the leaf hashes, and then the
+// hashes of the [end, size) compact range nodes. This is the order of the
+// proof built by RangeInclusion, with the leaves inserted in the middle.
+type RangeVerifier struct {
+	hasher     merkle.LogHasher
+	rf         *compact.RangeFactory
+	begin, end uint64
+	size       uint64
+	rng        *compact.Range // The compact range of [0, pos) consumed so far.
+}
+
+// NewRangeVerifier returns a RangeVerifier for the [begin, end) range of
+// leaves in the tree of the given size. Requires begin <= end <= size.
+func NewRangeVerifier(hasher merkle.LogHasher, begin, end, size uint64) (*RangeVerifier, error) {
+	if begin > end || end > size {
+		return nil, fmt.Errorf("range [%d, %d) out of bounds for tree size %d", begin, end, size)
+	}
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	return &RangeVerifier{hasher: hasher, rf: rf, begin: begin, end: end, size: size, rng: rf.NewEmptyRange(0)}, nil
+}
+
+// Next returns the ID of the node whose hash is expected next. Returns false
+// if all the hashes have been consumed.
+func (v *RangeVerifier) Next() (compact.NodeID, bool) {
+	switch pos := v.rng.End(); {
+	case pos < v.begin:
+		return nextNode(pos, v.begin), true
+	case pos < v.end:
+		return compact.NewNodeID(0, pos), true
+	case pos < v.size:
+		return nextNode(pos, v.size), true
+	}
+	return compact.NodeID{}, false
+}
+
+// PushProof consumes the next proof hash. Returns an error if a leaf hash is
+// expected instead, or the proof is complete.
+func (v *RangeVerifier) PushProof(hash []byte) error {
+	pos := v.rng.End()
+	if pos >= v.begin && pos < v.end {
+		return fmt.Errorf("expected leaf %d", pos)
+	}
+	id, ok := v.Next()
+	if !ok {
+		return errors.New("proof is complete")
+	}
+	begin, end := id.Coverage()
+	node, err := v.rf.NewRange(begin, end, [][]byte{hash})
+	if err != nil {
+		return err
+	}
+	return v.rng.AppendRange(node, nil)
+}
+
+// PushLeaf consumes the next leaf hash. Returns an error if a proof hash is
+// expected instead.
+func (v *RangeVerifier) PushLeaf(hash []byte) error {
+	if pos := v.rng.End(); pos < v.begin || pos >= v.end {
+		return fmt.Errorf("expected proof node, got leaf %d", pos)
+	}
+	return v.rng.Append(hash, nil)
+}
+
+// Verify checks that all the hashes have been consumed, and the tree root hash
+// matches the given one.
+func (v *RangeVerifier) Verify(root []byte) error {
+	if id, ok := v.Next(); ok {
+		return fmt.Errorf("incomplete proof: expected node %+v", id)
+	}
+	calcRoot, err := v.rng.GetRootHash(nil)
+	if err != nil {
+		return err
+	} else if calcRoot == nil {
+		calcRoot = v.hasher.EmptyRoot()
+	}
+	return lite.VerifyMatch(calcRoot, root)
+}
+
+// nextNode returns the biggest perfect node that starts at the given position,
+// and ends no later than limit. Requires pos < limit.
+func nextNode(pos, limit uint64) compact.NodeID {
+	level := uint(bits.Len64(limit-pos)) - 1
+	if pos != 0 {
+		if tz := uint(bits.TrailingZeros64(pos)); tz < level {
+			level = tz
+		}
+	}
+	return compact.NewNodeID(level, pos>>level)
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proof_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestRangeVerifier(t *testing.T) {
+	hasher := rfc6962.DefaultHasher
+	const size = 20
+	store := newStore(t, size)
+	ref := testonly.GenTree(hasher, 0, size)
+	for size2 := uint64(0); size2 <= size; size2++ {
+		root := ref.HashAt(size2)
+		for begin := uint64(0); begin <= size2; begin++ {
+			for end := begin; end <= size2; end++ {
+				nodes, err := proof.RangeInclusion(begin, end, size2)
+				if err != nil {
+					t.Fatalf("RangeInclusion: %v", err)
+				}
+				p, err := store.Get(nodes.IDs)
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				left := compact.RangeSize(0, begin)
+
+				v, err := proof.NewRangeVerifier(hasher, begin, end, size2)
+				if err != nil {
+					t.Fatalf("NewRangeVerifier: %v", err)
+				}
+				var ids []compact.NodeID
+				push := func(hash []byte, leaf bool) {
+					t.Helper()
+					id, ok := v.Next()
+					if !ok {
+						t.Fatal("Next: no node expected")
+					}
+					push := v.PushProof
+					if leaf {
+						push = v.PushLeaf
+						// The other kind of hash is rejected.
+						if err := v.PushProof(hash); err == nil {
+							t.Fatal("PushProof: want error when leaf is expected")
+						}
+					} else if err := v.PushLeaf(hash); err == nil {
+						t.Fatal("PushLeaf: want error when proof is expected")
+					}
+					if err := push(hash); err != nil {
+						t.Fatalf("Push: %v", err)
+					}
+					ids = append(ids, id)
+				}
+				for _, hash := range p[:left] {
+					push(hash, false)
+				}
+				for i := begin; i < end; i++ {
+					push(ref.LeafHash(i), true)
+				}
+				for _, hash := range p[left:] {
+					push(hash, false)
+				}
+				if err := v.PushProof(root); err == nil {
+					t.Error("PushProof: want error for complete proof")
+				}
+				if err := v.Verify(root); err != nil {
+					t.Errorf("Verify(%d, %d, %d): %v", begin, end, size2, err)
+				}
+				if err := v.Verify(hasher.HashLeaf(nil)); err == nil {
+					t.Errorf("Verify(%d, %d, %d): want error for wrong root", begin, end, size2)
+				}
+				// The nodes are expected in the order of the range and the proof.
+				want := append(compact.RangeNodes(0, begin, nil), compact.RangeNodes(end, size2, nil)...)
+				var got []compact.NodeID
+				for _, id := range ids {
+					if id.Level != 0 || id.Index < begin || id.Index >= end {
+						got = append(got, id)
+					}
+				}
+				if diff := cmp.Diff(got, want); diff != "" {
+					t.Errorf("Next(%d, %d, %d): diff(-got +want):\n%s", begin, end, size2, diff)
+				}
+			}
+		}
+	}
+
+	v, err := proof.NewRangeVerifier(hasher, 0, 5, 10)
+	if err != nil {
+		t.Fatalf("NewRangeVerifier: %v", err)
+	}
+	if err, want := v.Verify(nil), "incomplete proof: expected node 0:0"; err == nil || err.Error() != want {
+		t.Errorf("Verify: got error %v, want %q", err, want)
+	}
+	if _, err := proof.NewRangeVerifier(hasher, 5, 11, 10); err == nil {
+		t.Error("NewRangeVerifier: want error for out of bounds range")
+	}
+}
@@ -16,161 +16,507 @@ package proof
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"math/bits"
 
 	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof/lite"
 )
 
 // RootMismatchError occurs when an inclusion proof fails.
-type RootMismatchError struct {
-	ExpectedRoot   []byte
-	CalculatedRoot []byte
-}
-
-func (e RootMismatchError) Error() string {
-	return fmt.Sprintf("calculated root:\n%v\n does not match expected root:\n%v", e.CalculatedRoot, e.ExpectedRoot)
-}
-
-func verifyMatch(calculated, expected []byte) error {
-	if !bytes.Equal(calculated, expected) {
-		return RootMismatchError{ExpectedRoot: expected, CalculatedRoot: calculated}
-	}
-	return nil
-}
+type RootMismatchError = lite.RootMismatchError
 
 // VerifyInclusion verifies the correctness of the inclusion proof for the leaf
 // with the specified hash and index, relatively to the tree of the given size
 // and root hash. Requires 0 <= index < size.
+//
+// If the hasher is a ScratchHasher, the intermediate hashes are computed in
+// buffers reused across calls.
 func VerifyInclusion(hasher merkle.LogHasher, index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	return observer{}.inclusion(hasher, index, size, leafHash, proof, root)
+}
+
+func verifyInclusion(hasher merkle.LogHasher, index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
 	calcRoot, err := RootFromInclusionProof(hasher, index, size, leafHash, proof)
 	if err != nil {
 		return err
 	}
-	return verifyMatch(calcRoot, root)
+	return lite.VerifyMatch(calcRoot, root)
+}
+
+// VerifyInclusionData is like VerifyInclusion, but takes the leaf data rather
+// than its hash. The leaf hash is computed with hasher.HashLeaf.
+func VerifyInclusionData(hasher merkle.LogHasher, index, size uint64, leaf []byte, proof [][]byte, root []byte) error {
+	return VerifyInclusion(hasher, index, size, hasher.HashLeaf(leaf), proof, root)
+}
+
+// VerifyInclusionContext is like VerifyInclusion, but computes the hashes with
+// a ContextLogHasher, e.g. a remote one. Returns the first error that occurs in
+// hash computations, if any.
+func VerifyInclusionContext(ctx context.Context, hasher merkle.ContextLogHasher, index, size uint64, leafHash []byte, proof [][]byte, root []byte) error {
+	b := merkle.Bind(ctx, hasher)
+	err := VerifyInclusion(b, index, size, leafHash, proof, root)
+	if hashErr := b.Err(); hashErr != nil {
+		return fmt.Errorf("hashing: %w", hashErr)
+	}
+	return err
+}
+
+// ScratchHasher is a merkle.LogHasher that can also compute node hashes into
+// caller-provided memory.
+type ScratchHasher interface {
+	merkle.LogHasher
+	// HashChildrenTo computes the same hash as HashChildren, writes it to dst,
+	// and returns the resulting slice. The dst slice is reused if it has enough
+	// capacity, and it is allowed to overlap with l and r.
+	HashChildrenTo(dst, l, r []byte) []byte
+}
+
+// VerifyInclusionScratch is like VerifyInclusion, but computes all the
+// intermediate hashes in the given scratch buffer, which should have capacity
+// for hasher.Size() bytes. If the hasher's HashChildrenTo does not allocate,
+// such as the SHA256 one from the rfc6962 package, then a successful
+// verification performs no heap allocations.
+func VerifyInclusionScratch(hasher ScratchHasher, index, size uint64, leafHash []byte, proof [][]byte, root, scratch []byte) error {
+	if index >= size {
+		return fmt.Errorf("index is beyond size: %d >= %d", index, size)
+	}
+	if got, want := len(leafHash), hasher.Size(); got != want {
+		return fmt.Errorf("leafHash has unexpected size %d, want %d", got, want)
+	}
+	inner, border := lite.DecompInclProof(index, size)
+	if got, want := len(proof), inner+border; got != want {
+		return fmt.Errorf("%w: wrong proof size %d, want %d", ErrMalformedProof, got, want)
+	}
+
+	res := leafHash
+	for i, h := range proof {
+		// Inner nodes can be on both sides of the path, border nodes are all on
+		// the left side, like in lite.ChainInner and lite.ChainBorderRight.
+		if i < inner && (index>>uint(i))&1 == 0 {
+			res = hasher.HashChildrenTo(scratch, res, h)
+		} else {
+			res = hasher.HashChildrenTo(scratch, h, res)
+		}
+	}
+	return lite.VerifyMatch(res, root)
 }
 
 // RootFromInclusionProof calculates the expected root hash for a tree of the
 // given size, provided a leaf index and hash with the corresponding inclusion
 // proof. Requires 0 <= index < size.
 func RootFromInclusionProof(hasher merkle.LogHasher, index, size uint64, leafHash []byte, proof [][]byte) ([]byte, error) {
-	if index >= size {
-		return nil, fmt.Errorf("index is beyond size: %d >= %d", index, size)
-	}
-	if got, want := len(leafHash), hasher.Size(); got != want {
-		return nil, fmt.Errorf("leafHash has unexpected size %d, want %d", got, want)
+	return lite.RootFromInclusionProof(hasher, index, size, leafHash, proof)
+}
+
+// VerifyNodeInclusion verifies the correctness of the inclusion proof for the
+// perfect node with the specified ID and hash, relatively to the tree of the
+// given size and root hash. The node must be fully covered by the tree.
+//
+// See NodeInclusion for how such proofs are constructed.
+func VerifyNodeInclusion(hasher merkle.LogHasher, id compact.NodeID, size uint64, nodeHash []byte, proof [][]byte, root []byte) error {
+	calcRoot, err := RootFromNodeInclusionProof(hasher, id, size, nodeHash, proof)
+	if err != nil {
+		return err
 	}
+	return lite.VerifyMatch(calcRoot, root)
+}
 
-	inner, border := decompInclProof(index, size)
+// RootFromNodeInclusionProof calculates the expected root hash for a tree of
+// the given size, provided a perfect node ID and hash with the corresponding
+// inclusion proof. The node must be fully covered by the tree.
+func RootFromNodeInclusionProof(hasher merkle.LogHasher, id compact.NodeID, size uint64, nodeHash []byte, proof [][]byte) ([]byte, error) {
+	if id.Index >= size>>id.Level {
+		return nil, fmt.Errorf("node %+v out of bounds for tree size %d", id, size)
+	}
+	if got, want := len(nodeHash), hasher.Size(); got != want {
+		return nil, fmt.Errorf("nodeHash has unexpected size %d, want %d", got, want)
+	}
+	// The proof has the same shape as the inclusion proof for the leaf id.Index
+	// in the tree which consists of the level-th level nodes of this tree.
+	inner, border := lite.DecompInclProof(id.Index, (size-1)>>id.Level+1)
 	if got, want := len(proof), inner+border; got != want {
-		return nil, fmt.Errorf("wrong proof size %d, want %d", got, want)
+		return nil, fmt.Errorf("%w: wrong proof size %d, want %d", ErrMalformedProof, got, want)
 	}
 
-	res := chainInner(hasher, leafHash, proof[:inner], index)
-	res = chainBorderRight(hasher, res, proof[inner:])
+	res := lite.ChainInner(hasher, nodeHash, proof[:inner], id.Index)
+	res = lite.ChainBorderRight(hasher, res, proof[inner:])
 	return res, nil
 }
 
+// VerifyInclusionInRange verifies the correctness of the inclusion proof for
+// the leaf with the specified hash and index, relatively to the given compact
+// range. The range must use the same hash function as the hasher. Requires
+// r.Begin() <= index < r.End().
+//
+// See InclusionInRange for how such proofs are constructed.
+func VerifyInclusionInRange(hasher merkle.LogHasher, index uint64, leafHash []byte, proof [][]byte, r *compact.Range) error {
+	if begin, end := r.Begin(), r.End(); index < begin || index >= end {
+		return fmt.Errorf("index %d out of bounds for range [%d, %d)", index, begin, end)
+	}
+	if got, want := len(leafHash), hasher.Size(); got != want {
+		return fmt.Errorf("leafHash has unexpected size %d, want %d", got, want)
+	}
+	pos, root := rangeNode(r.Begin(), r.End(), index)
+	if got, want := len(proof), int(root.Level); got != want {
+		return fmt.Errorf("%w: wrong proof size %d, want %d", ErrMalformedProof, got, want)
+	}
+	hash := lite.ChainInner(hasher, leafHash, proof, index)
+	return lite.VerifyMatch(hash, r.Hashes()[pos])
+}
+
+// VerifyRangeInclusion verifies that the given compact range is a part of the
+// tree of the given size and root hash, using the proof constructed as
+// described in RangeInclusion. The range must use the same hash function as
+// the hasher. Requires r.End() <= size.
+func VerifyRangeInclusion(hasher merkle.LogHasher, r *compact.Range, size uint64, proof [][]byte, root []byte) error {
+	begin, end := r.Begin(), r.End()
+	if end > size {
+		return fmt.Errorf("range [%d, %d) out of bounds for tree size %d", begin, end, size)
+	}
+	left := compact.RangeSize(0, begin)
+	if got, want := len(proof), left+compact.RangeSize(end, size); got != want {
+		return fmt.Errorf("%w: wrong proof size %d, want %d", ErrMalformedProof, got, want)
+	}
+
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	// Cap the slice, so that merging does not overwrite the rest of the proof.
+	full, err := rf.NewRange(0, begin, proof[:left:left])
+	if err != nil {
+		return err
+	}
+	for _, part := range []struct {
+		begin, end uint64
+		hashes     [][]byte
+	}{
+		{begin: begin, end: end, hashes: r.Hashes()},
+		{begin: end, end: size, hashes: proof[left:]},
+	} {
+		rng, err := rf.NewRange(part.begin, part.end, part.hashes)
+		if err != nil {
+			return err
+		}
+		if err := full.AppendRange(rng, nil); err != nil {
+			return err
+		}
+	}
+	calcRoot, err := full.GetRootHash(nil)
+	if err != nil {
+		return err
+	} else if calcRoot == nil {
+		calcRoot = hasher.EmptyRoot()
+	}
+	return lite.VerifyMatch(calcRoot, root)
+}
+
+// VerifyNonInclusion verifies that the given value is not in the log of the
+// given size and root hash, whose leaves are sorted by their data in
+// lexicographical order. The proof is constructed as described in
+// NonInclusion, and the neighbours are the data of the leaves adjacent to pos:
+// the leaf pos-1 if pos > 0, followed by the leaf pos if pos < size. Requires
+// 0 <= pos <= size.
+//
+// The value is absent iff the neighbours are in the tree, and the value is
+// strictly between them.
+func VerifyNonInclusion(hasher merkle.LogHasher, pos, size uint64, value []byte, neighbours [][]byte, proof [][]byte, root []byte) error {
+	if pos > size {
+		return fmt.Errorf("position %d out of bounds for tree size %d", pos, size)
+	}
+	begin, end := adjacent(pos, size)
+	if got, want := len(neighbours), int(end-begin); got != want {
+		return fmt.Errorf("got %d neighbours, want %d", got, want)
+	}
+	if pos > 0 && bytes.Compare(neighbours[0], value) >= 0 {
+		return fmt.Errorf("leaf %d is not less than the value", pos-1)
+	}
+	if pos < size && bytes.Compare(value, neighbours[len(neighbours)-1]) >= 0 {
+		return fmt.Errorf("leaf %d is not greater than the value", pos)
+	}
+	rng := (&compact.RangeFactory{Hash: hasher.HashChildren}).NewEmptyRange(begin)
+	for _, leaf := range neighbours {
+		if err := rng.Append(hasher.HashLeaf(leaf), nil); err != nil {
+			return err
+		}
+	}
+	return VerifyRangeInclusion(hasher, rng, size, proof, root)
+}
+
 // VerifyConsistency checks that the passed-in consistency proof is valid
 // between the passed in tree sizes, with respect to the corresponding root
 // hashes. Requires 0 <= size1 <= size2.
+//
+// If the hasher is a ScratchHasher, the intermediate hashes are computed in
+// buffers reused across calls.
 func VerifyConsistency(hasher merkle.LogHasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
-	switch {
-	case size2 < size1:
-		return fmt.Errorf("size2 (%d) < size1 (%d)", size1, size2)
-	case size1 == size2:
-		if len(proof) > 0 {
-			return errors.New("size1=size2, but proof is not empty")
-		}
-		return verifyMatch(root1, root2)
-	case size1 == 0:
-		// Any size greater than 0 is consistent with size 0.
-		if len(proof) > 0 {
-			return fmt.Errorf("expected empty proof, but got %d components", len(proof))
-		}
-		return nil // Proof OK.
-	case len(proof) == 0:
-		return errors.New("empty proof")
+	return observer{}.consistency(hasher, size1, size2, proof, root1, root2)
+}
+
+// VerifyConsistencyContext is like VerifyConsistency, but computes the hashes
+// with a ContextLogHasher, e.g. a remote one. Returns the first error that
+// occurs in hash computations, if any.
+func VerifyConsistencyContext(ctx context.Context, hasher merkle.ContextLogHasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
+	b := merkle.Bind(ctx, hasher)
+	err := VerifyConsistency(b, size1, size2, proof, root1, root2)
+	if hashErr := b.Err(); hashErr != nil {
+		return fmt.Errorf("hashing: %w", hashErr)
+	}
+	return err
+}
+
+func verifyConsistency(hasher1, hasher2 merkle.LogHasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) error {
+	hash1, hash2, err := lite.ConsistencyRoots(hasher1, hasher2, size1, size2, proof, root1, root2)
+	if err != nil {
+		return err
+	}
+	if err := lite.VerifyMatch(hash1, root1); err != nil {
+		return err
+	}
+	return lite.VerifyMatch(hash2, root2)
+}
+
+// RootFromConsistencyProof calculates the root hash of the tree of size2
+// implied by the given consistency proof from the tree of size1 with the given
+// root hash. Returns an error if the proof is malformed, or does not match
+// root1. Requires 0 < size1 <= size2, because the empty tree is consistent with
+// any tree, and so does not imply any root hash.
+//
+// The returned root is equal to root2 iff VerifyConsistency succeeds for root1
+// and root2.
+func RootFromConsistencyProof(hasher merkle.LogHasher, size1, size2 uint64, proof [][]byte, root1 []byte) ([]byte, error) {
+	if size1 == 0 && size2 != 0 {
+		return nil, errors.New("size1=0 does not imply a root for size2")
+	}
+	hash1, hash2, err := lite.ConsistencyRoots(hasher, hasher, size1, size2, proof, root1, nil)
+	if err != nil {
+		return nil, err
 	}
+	if err := lite.VerifyMatch(hash1, root1); err != nil {
+		return nil, err
+	}
+	return hash2, nil
+}
 
-	inner, border := decompInclProof(size1-1, size2)
-	shift := bits.TrailingZeros64(size1)
-	inner -= shift // Note: shift < inner if size1 < size2.
+// ConsistencyEvidence records a failed verification of a well-formed
+// consistency proof between two tree heads. If both tree heads are signed by
+// the log, it is evidence of the log presenting inconsistent views, which can be
+// shared with other parties, e.g. encoded as JSON.
+type ConsistencyEvidence struct {
+	Size1 uint64   `json:"size1"`
+	Size2 uint64   `json:"size2"`
+	Root1 []byte   `json:"root1"`
+	Root2 []byte   `json:"root2"`
+	Proof [][]byte `json:"proof"`
+	// The root hashes calculated from the proof. At least one of them does not
+	// match the corresponding root hash above.
+	CalculatedRoot1 []byte `json:"calculated_root1"`
+	CalculatedRoot2 []byte `json:"calculated_root2"`
+}
 
-	// The proof includes the root hash for the sub-tree of size 2^shift.
-	seed, start := proof[0], 1
-	if size1 == 1<<uint(shift) { // Unless size1 is that very 2^shift.
-		seed, start = root1, 0
+// CheckConsistency is like VerifyConsistency, but in addition it returns the
+// evidence of the failure if the proof is well-formed, but does not connect the
+// two root hashes. Returns an error only if the proof is malformed. Returns
+// nil, nil if the proof is valid.
+func CheckConsistency(hasher merkle.LogHasher, size1, size2 uint64, proof [][]byte, root1, root2 []byte) (*ConsistencyEvidence, error) {
+	hash1, hash2, err := lite.ConsistencyRoots(hasher, hasher, size1, size2, proof, root1, root2)
+	if err != nil {
+		return nil, err
 	}
-	if got, want := len(proof), start+inner+border; got != want {
-		return fmt.Errorf("wrong proof size %d, want %d", got, want)
+	if bytes.Equal(hash1, root1) && bytes.Equal(hash2, root2) {
+		return nil, nil
 	}
-	proof = proof[start:]
-	// Now len(proof) == inner+border, and proof is effectively a suffix of
-	// inclusion proof for entry |size1-1| in a tree of size |size2|.
+	return &ConsistencyEvidence{
+		Size1: size1, Size2: size2, Root1: root1, Root2: root2, Proof: proof,
+		CalculatedRoot1: hash1, CalculatedRoot2: hash2,
+	}, nil
+}
 
-	// Verify the first root.
-	mask := (size1 - 1) >> uint(shift) // Start chaining from level |shift|.
-	hash1 := chainInnerRight(hasher, seed, proof[:inner], mask)
-	hash1 = chainBorderRight(hasher, hash1, proof[inner:])
-	if err := verifyMatch(hash1, root1); err != nil {
+// Verify independently checks the evidence: that the calculated root hashes
+// follow from the proof, and that they do not match the claimed ones. Returns
+// nil if the evidence is valid.
+func (e *ConsistencyEvidence) Verify(hasher merkle.LogHasher) error {
+	hash1, hash2, err := lite.ConsistencyRoots(hasher, hasher, e.Size1, e.Size2, e.Proof, e.Root1, e.Root2)
+	if err != nil {
 		return err
 	}
+	if !bytes.Equal(hash1, e.CalculatedRoot1) || !bytes.Equal(hash2, e.CalculatedRoot2) {
+		return errors.New("calculated root hashes do not follow from the proof")
+	}
+	if bytes.Equal(hash1, e.Root1) && bytes.Equal(hash2, e.Root2) {
+		return errors.New("the proof is valid")
+	}
+	return nil
+}
 
-	// Verify the second root.
-	hash2 := chainInner(hasher, seed, proof[:inner], mask)
-	hash2 = chainBorderRight(hasher, hash2, proof[inner:])
-	return verifyMatch(hash2, root2)
+// Combined is a proof that a leaf is included into the tree of size1, and that
+// this tree is consistent with the tree of size2. See InclusionAndConsistency
+// for how it is constructed.
+type Combined struct {
+	Inclusion   [][]byte // The inclusion proof of the leaf into the tree of size1.
+	Consistency [][]byte // The consistency proof between size1 and size2.
 }
 
-// decompInclProof breaks down inclusion proof for a leaf at the specified
-// |index| in a tree of the specified |size| into 2 components. The splitting
-// point between them is where paths to leaves |index| and |size-1| diverge.
-// Returns lengths of the bottom and upper proof parts correspondingly. The sum
-// of the two determines the correct length of the inclusion proof.
-func decompInclProof(index, size uint64) (int, int) {
-	inner := innerProofSize(index, size)
-	border := bits.OnesCount64(index >> uint(inner))
-	return inner, border
+// VerifyInclusionAndConsistency verifies the combined proof for the leaf with
+// the specified hash and index, relatively to the tree of size2 with the given
+// root hash. Requires 0 <= index < size1 <= size2.
+//
+// The root hash of the tree of size1 is computed from the inclusion proof, and
+// is returned so that the caller can compare it with the tree head through
+// which the leaf was learnt. A successful verification confirms that the leaf
+// is included into both trees.
+func VerifyInclusionAndConsistency(hasher merkle.LogHasher, index, size1, size2 uint64, leafHash []byte, proof Combined, root2 []byte) ([]byte, error) {
+	if size1 > size2 {
+		return nil, fmt.Errorf("size2 (%d) < size1 (%d)", size2, size1)
+	}
+	root1, err := RootFromInclusionProof(hasher, index, size1, leafHash, proof.Inclusion)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyConsistency(hasher, size1, size2, proof.Consistency, root1, root2); err != nil {
+		return nil, err
+	}
+	return root1, nil
 }
 
-func innerProofSize(index, size uint64) int {
-	return bits.Len64(index ^ (size - 1))
+// Sharded is a proof that a leaf is included into a shard tree, and that the
+// root of this shard is included into the super-tree. See ShardedInclusion.
+type Sharded struct {
+	Leaf  [][]byte // The inclusion proof of the leaf into the shard tree.
+	Shard [][]byte // The inclusion proof of the shard root into the super-tree.
 }
 
-// chainInner computes a subtree hash for a node on or below the tree's right
-// border. Assumes |proof| hashes are ordered from lower levels to upper, and
-// |seed| is the initial subtree/leaf hash on the path located at the specified
-// |index| on its level.
-func chainInner(hasher merkle.LogHasher, seed []byte, proof [][]byte, index uint64) []byte {
-	for i, h := range proof {
-		if (index>>uint(i))&1 == 0 {
-			seed = hasher.HashChildren(seed, h)
-		} else {
-			seed = hasher.HashChildren(h, seed)
-		}
+// VerifyShardedInclusion verifies the proof that the leaf with the given hash
+// and index is included into the shard tree of the given size, and that this
+// shard's root hash is included at the given index into the super-tree of the
+// given size and root hash. Returns the root hash of the shard tree.
+//
+// The leaves of the super-tree are the shard roots, hashed with HashLeaf.
+func VerifyShardedInclusion(hasher merkle.LogHasher, index, shardSize, shard, superSize uint64, leafHash []byte, proof Sharded, superRoot []byte) ([]byte, error) {
+	shardRoot, err := RootFromInclusionProof(hasher, index, shardSize, leafHash, proof.Leaf)
+	if err != nil {
+		return nil, err
+	}
+	if err := VerifyInclusion(hasher, shard, superSize, hasher.HashLeaf(shardRoot), proof.Shard, superRoot); err != nil {
+		return nil, err
 	}
-	return seed
+	return shardRoot, nil
 }
 
-// chainInnerRight computes a subtree hash like chainInner, but only takes
-// hashes to the left from the path into consideration, which effectively means
-// the result is a hash of the corresponding earlier version of this subtree.
-func chainInnerRight(hasher merkle.LogHasher, seed []byte, proof [][]byte, index uint64) []byte {
-	for i, h := range proof {
-		if (index>>uint(i))&1 == 1 {
-			seed = hasher.HashChildren(h, seed)
+// VerifyConsistencyRange checks that the passed-in consistency proof is valid
+// between the tree represented by the given compact range, and the tree of
+// size2 with the given root hash. The range must cover leaves [0, size1), and
+// use the same hash function as the hasher. Requires size1 <= size2.
+//
+// The range hashes commit to the entire [0, size1) tree, so a successful
+// verification confirms that the new tree extends the locally stored range,
+// including all its perfect subtrees.
+func VerifyConsistencyRange(hasher merkle.LogHasher, r *compact.Range, size2 uint64, proof [][]byte, root2 []byte) error {
+	if begin := r.Begin(); begin != 0 {
+		return fmt.Errorf("range begin=%d, want 0", begin)
+	}
+	root1, err := r.GetRootHash(nil)
+	if err != nil {
+		return err
+	} else if root1 == nil {
+		root1 = hasher.EmptyRoot()
+	}
+	return VerifyConsistency(hasher, r.End(), size2, proof, root1, root2)
+}
+
+// RootAfterAppend returns the root hash of the tree obtained by appending the
+// leaves with the given hashes to the tree represented by the compact range.
+// The range must cover leaves [0, size1), and use the same hash function as the
+// hasher. The range is not modified.
+func RootAfterAppend(hasher merkle.LogHasher, r *compact.Range, leafHashes [][]byte) ([]byte, error) {
+	_, root, err := appendLeaves(hasher, r, leafHashes)
+	return root, err
+}
+
+// VerifyAppend checks that appending the leaves with the given hashes to the
+// tree represented by the compact range results in the tree of size2 with the
+// given root hash. The range must cover leaves [0, size1), and use the same
+// hash function as the hasher. This check does not need a consistency proof,
+// but requires all the leaves in [size1, size2).
+//
+// On success, returns the compact range of the new tree, which can be used for
+// verifying the subsequent appends. The passed in range is not modified.
+func VerifyAppend(hasher merkle.LogHasher, r *compact.Range, leafHashes [][]byte, size2 uint64, root2 []byte) (*compact.Range, error) {
+	if size1 := r.End(); size2 < size1 || uint64(len(leafHashes)) != size2-size1 {
+		return nil, fmt.Errorf("got %d leaves for sizes %d -> %d", len(leafHashes), size1, size2)
+	}
+	r, root, err := appendLeaves(hasher, r, leafHashes)
+	if err != nil {
+		return nil, err
+	}
+	if err := lite.VerifyMatch(root, root2); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// appendLeaves returns a copy of the given compact range with the given leaf
+// hashes appended, and the root hash of the corresponding tree.
+func appendLeaves(hasher merkle.LogHasher, r *compact.Range, leafHashes [][]byte) (*compact.Range, []byte, error) {
+	if begin := r.Begin(); begin != 0 {
+		return nil, nil, fmt.Errorf("range begin=%d, want 0", begin)
+	}
+	r = r.Clone()
+	for _, hash := range leafHashes {
+		if err := r.Append(hash, nil); err != nil {
+			return nil, nil, err
 		}
 	}
-	return seed
+	root, err := r.GetRootHash(nil)
+	if err != nil {
+		return nil, nil, err
+	} else if root == nil {
+		root = hasher.EmptyRoot()
+	}
+	return r, root, nil
+}
+
+// ChainError occurs when a consistency proof between two subsequent tree heads
+// of a chain fails to verify.
+type ChainError struct {
+	Index int    // The index of the failed transition, i.e. sizes[Index] -> sizes[Index+1].
+	Size1 uint64 // The smaller tree size of the failed transition.
+	Size2 uint64 // The bigger tree size of the failed transition.
+	Err   error  // The verification error.
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("transition %d (size %d -> %d): %v", e.Index, e.Size1, e.Size2, e.Err)
 }
 
-// chainBorderRight chains proof hashes along tree borders. This differs from
-// inner chaining because |proof| contains only left-side subtree hashes.
-func chainBorderRight(hasher merkle.LogHasher, seed []byte, proof [][]byte) []byte {
-	for _, h := range proof {
-		seed = hasher.HashChildren(h, seed)
+// Unwrap returns the underlying verification error.
+func (e *ChainError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyConsistencyChain checks that the passed-in sequence of tree heads is
+// consistent. The i-th tree head is represented by sizes[i] and roots[i], and
+// proofs[i] is the consistency proof between the i-th and (i+1)-th tree heads.
+// Requires the sizes to be non-decreasing.
+//
+// If one of the transitions fails to verify, the returned error is a
+// *ChainError identifying it.
+func VerifyConsistencyChain(hasher merkle.LogHasher, sizes []uint64, roots [][]byte, proofs [][][]byte) error {
+	if got, want := len(roots), len(sizes); got != want {
+		return fmt.Errorf("got %d roots, want %d", got, want)
+	}
+	want := 0
+	if ln := len(sizes); ln != 0 {
+		want = ln - 1
 	}
-	return seed
+	if got := len(proofs); got != want {
+		return fmt.Errorf("got %d proofs, want %d", got, want)
+	}
+	for i, proof := range proofs {
+		size1, size2 := sizes[i], sizes[i+1]
+		if err := VerifyConsistency(hasher, size1, size2, proof, roots[i], roots[i+1]); err != nil {
+			return &ChainError{Index: i, Size1: size1, Size2: size2, Err: err}
+		}
+	}
+	return nil
 }
@@ -16,7 +16,9 @@ package proof
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -328,6 +330,23 @@ func TestVerifyInclusion(t *testing.T) {
 	}
 }
 
+func TestVerifyInclusionData(t *testing.T) {
+	for i := 1; i < 6; i++ {
+		p := inclusionProofs[i]
+		t.Run(fmt.Sprintf("proof:%d", i), func(t *testing.T) {
+			leaf := leaves[p.leaf-1]
+			if err := VerifyInclusionData(hasher, p.leaf-1, p.size, leaf, p.proof, roots[p.size-1]); err != nil {
+				t.Errorf("VerifyInclusionData: %v", err)
+			}
+			// The leaf hash passed in as data must not verify.
+			leafHash := hasher.HashLeaf(leaf)
+			if err := VerifyInclusionData(hasher, p.leaf-1, p.size, leafHash, p.proof, roots[p.size-1]); err == nil {
+				t.Error("VerifyInclusionData: want error for leaf hash passed as data")
+			}
+		})
+	}
+}
+
 func TestVerifyConsistency(t *testing.T) {
 	root1 := []byte("don't care 1")
 	root2 := []byte("don't care 2")
@@ -381,6 +400,36 @@ func TestVerifyConsistency(t *testing.T) {
 	}
 }
 
+func TestVerifyContext(t *testing.T) {
+	ctx := context.Background()
+	ctxHasher := merkle.WithContext(hasher)
+	for i := 1; i < 6; i++ {
+		p := inclusionProofs[i]
+		leafHash := hasher.HashLeaf(leaves[p.leaf-1])
+		if err := VerifyInclusionContext(ctx, ctxHasher, p.leaf-1, p.size, leafHash, p.proof, roots[p.size-1]); err != nil {
+			t.Errorf("VerifyInclusionContext(%d): %v", i, err)
+		}
+	}
+	for i, p := range consistencyProofs {
+		if err := VerifyConsistencyContext(ctx, ctxHasher, p.size1, p.size2, p.proof, roots[p.size1-1], roots[p.size2-1]); err != nil {
+			t.Errorf("VerifyConsistencyContext(%d): %v", i, err)
+		}
+	}
+
+	// Hashing errors are surfaced instead of a root mismatch.
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	p := inclusionProofs[5]
+	leafHash := hasher.HashLeaf(leaves[p.leaf-1])
+	if err := VerifyInclusionContext(cctx, ctxHasher, p.leaf-1, p.size, leafHash, p.proof, roots[p.size-1]); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifyInclusionContext: got %v, want %v", err, context.Canceled)
+	}
+	c := consistencyProofs[len(consistencyProofs)-1]
+	if err := VerifyConsistencyContext(cctx, ctxHasher, c.size1, c.size2, c.proof, roots[c.size1-1], roots[c.size2-1]); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifyConsistencyContext: got %v, want %v", err, context.Canceled)
+	}
+}
+
 // extend explicitly copies |proof| slice and appends |hashes| to it.
 func extend(proof [][]byte, hashes ...[]byte) [][]byte {
 	res := make([][]byte, len(proof), len(proof)+len(hashes))
@@ -0,0 +1,95 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ctapi decodes the proof responses of the RFC6962 Certificate
+// Transparency log JSON API, see RFC6962 section 4, and verifies them.
+//
+// Each Verify function takes the raw JSON body of the response, and the tree
+// size and root hash that the proof is verified against, typically taken from
+// a signed tree head which the caller has already checked.
+package ctapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// GetProofByHashResponse is the response to the get-proof-by-hash request.
+type GetProofByHashResponse struct {
+	LeafIndex int64    `json:"leaf_index"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// GetSTHConsistencyResponse is the response to the get-sth-consistency request.
+type GetSTHConsistencyResponse struct {
+	Consistency [][]byte `json:"consistency"`
+}
+
+// GetEntryAndProofResponse is the response to the get-entry-and-proof request.
+type GetEntryAndProofResponse struct {
+	LeafInput []byte   `json:"leaf_input"`
+	ExtraData []byte   `json:"extra_data"`
+	AuditPath [][]byte `json:"audit_path"`
+}
+
+// VerifyProofByHash decodes the get-proof-by-hash response, and verifies that
+// the leaf with the given hash is included in the tree of the given size and
+// root hash. Returns the decoded response, which contains the leaf index.
+func VerifyProofByHash(hasher merkle.LogHasher, body, leafHash []byte, size uint64, root []byte) (*GetProofByHashResponse, error) {
+	var resp GetProofByHashResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding get-proof-by-hash response: %v", err)
+	}
+	if resp.LeafIndex < 0 {
+		return nil, fmt.Errorf("negative leaf index %d", resp.LeafIndex)
+	}
+	if err := proof.VerifyInclusion(hasher, uint64(resp.LeafIndex), size, leafHash, resp.AuditPath, root); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// VerifySTHConsistency decodes the get-sth-consistency response, and verifies
+// that the tree with the given size1 and root1 is a prefix of the tree with the
+// given size2 and root2.
+func VerifySTHConsistency(hasher merkle.LogHasher, body []byte, size1, size2 uint64, root1, root2 []byte) error {
+	var resp GetSTHConsistencyResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decoding get-sth-consistency response: %v", err)
+	}
+	return proof.VerifyConsistency(hasher, size1, size2, resp.Consistency, root1, root2)
+}
+
+// VerifyEntryAndProof decodes the get-entry-and-proof response, and verifies
+// that the returned entry is included at the given index into the tree of the
+// given size and root hash. Returns the decoded response, which contains the
+// entry.
+//
+// The leaf hash is computed from the leaf_input field, which is the
+// MerkleTreeLeaf structure. The extra_data field is not covered by the proof,
+// and is not checked.
+func VerifyEntryAndProof(hasher merkle.LogHasher, body []byte, index, size uint64, root []byte) (*GetEntryAndProofResponse, error) {
+	var resp GetEntryAndProofResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding get-entry-and-proof response: %v", err)
+	}
+	leafHash := hasher.HashLeaf(resp.LeafInput)
+	if err := proof.VerifyInclusion(hasher, index, size, leafHash, resp.AuditPath, root); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
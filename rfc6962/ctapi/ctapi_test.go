@@ -0,0 +1,132 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func newTree(size int) *testonly.Tree {
+	tree := testonly.New(hasher)
+	for i := 0; i < size; i++ {
+		tree.AppendData([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return tree
+}
+
+// encode returns the JSON encoding of the given proof path, in the form used by
+// the RFC6962 API.
+func encode(path [][]byte) string {
+	strs := make([]string, len(path))
+	for i, hash := range path {
+		strs[i] = base64.StdEncoding.EncodeToString(hash)
+	}
+	res, _ := json.Marshal(strs)
+	return string(res)
+}
+
+func TestVerifyProofByHash(t *testing.T) {
+	const size = 11
+	tree := newTree(size)
+	root := tree.Hash()
+	for index := uint64(0); index < size; index++ {
+		path, err := tree.InclusionProof(index, size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		body := fmt.Sprintf(`{"leaf_index":%d,"audit_path":%s}`, index, encode(path))
+		resp, err := VerifyProofByHash(hasher, []byte(body), tree.LeafHash(index), size, root)
+		if err != nil {
+			t.Fatalf("VerifyProofByHash(%d): %v", index, err)
+		}
+		if got, want := resp.LeafIndex, int64(index); got != want {
+			t.Errorf("VerifyProofByHash: got index %d, want %d", got, want)
+		}
+		if _, err := VerifyProofByHash(hasher, []byte(body), tree.LeafHash((index+1)%size), size, root); err == nil {
+			t.Errorf("VerifyProofByHash(%d): want error for wrong leaf", index)
+		}
+	}
+
+	for _, body := range []string{
+		`{"leaf_index":-1,"audit_path":[]}`,
+		`{"leaf_index":0,"audit_path":["!"]}`,
+		`{"leaf_index":0`,
+	} {
+		if _, err := VerifyProofByHash(hasher, []byte(body), tree.LeafHash(0), size, root); err == nil {
+			t.Errorf("VerifyProofByHash(%s): want error", body)
+		}
+	}
+}
+
+func TestVerifySTHConsistency(t *testing.T) {
+	const size = 11
+	tree := newTree(size)
+	for size1 := uint64(0); size1 <= size; size1++ {
+		for size2 := size1; size2 <= size; size2++ {
+			path, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			body := fmt.Sprintf(`{"consistency":%s}`, encode(path))
+			root1, root2 := tree.HashAt(size1), tree.HashAt(size2)
+			if err := VerifySTHConsistency(hasher, []byte(body), size1, size2, root1, root2); err != nil {
+				t.Errorf("VerifySTHConsistency(%d, %d): %v", size1, size2, err)
+			}
+		}
+	}
+	if err := VerifySTHConsistency(hasher, []byte(`{"consistency":1}`), 1, 2, tree.HashAt(1), tree.HashAt(2)); err == nil {
+		t.Error("VerifySTHConsistency: want error for malformed response")
+	}
+}
+
+func TestVerifyEntryAndProof(t *testing.T) {
+	const size = 11
+	tree := newTree(size)
+	root := tree.Hash()
+	for index := uint64(0); index < size; index++ {
+		path, err := tree.InclusionProof(index, size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		leaf := []byte(fmt.Sprintf("leaf %d", index))
+		body := fmt.Sprintf(`{"leaf_input":"%s","extra_data":"AQI=","audit_path":%s}`,
+			base64.StdEncoding.EncodeToString(leaf), encode(path))
+		resp, err := VerifyEntryAndProof(hasher, []byte(body), index, size, root)
+		if err != nil {
+			t.Fatalf("VerifyEntryAndProof(%d): %v", index, err)
+		}
+		if !bytes.Equal(resp.LeafInput, leaf) {
+			t.Errorf("VerifyEntryAndProof: got leaf %q, want %q", resp.LeafInput, leaf)
+		}
+		if got, want := resp.ExtraData, []byte{1, 2}; !bytes.Equal(got, want) {
+			t.Errorf("VerifyEntryAndProof: got extra data %x, want %x", got, want)
+		}
+
+		tampered := strings.Replace(body, `"leaf_input":"`, `"leaf_input":"AA`, 1)
+		if _, err := VerifyEntryAndProof(hasher, []byte(tampered), index, size, root); err == nil {
+			t.Errorf("VerifyEntryAndProof(%d): want error for tampered leaf", index)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rfc6962
+
+import "github.com/transparency-dev/merkle"
+
+// MaxEmptyLevel is the highest level of the precomputed EmptyHashes table. It
+// is enough for sparse trees keyed by 256-bit hashes.
+const MaxEmptyLevel = 256
+
+// EmptyHashes contains the hashes of empty subtrees of DefaultHasher for
+// levels from 0 to MaxEmptyLevel inclusively. EmptyHashes[0] is the hash of
+// the empty tree, and EmptyHashes[i+1] is the hash of a node with both
+// children equal to EmptyHashes[i].
+//
+// The table is shared, and must not be modified.
+var EmptyHashes = ExtendEmptyHashes(DefaultHasher, nil, MaxEmptyLevel)
+
+// ExtendEmptyHashes appends to the given table of empty subtree hashes of the
+// given hasher, so that it contains the hashes for all levels up to and
+// including maxLevel, and returns the new table. The table must be either
+// empty, or contain a prefix of such hashes, e.g. computed by a previous call.
+// It is returned unchanged if it already covers maxLevel.
+//
+// For example, EmptyHashes can be extended to a higher level without
+// recomputing the existing hashes, by passing in a copy of it.
+func ExtendEmptyHashes(hasher merkle.LogHasher, table [][]byte, maxLevel uint) [][]byte {
+	if len(table) == 0 {
+		table = append(table, hasher.EmptyRoot())
+	}
+	for level := uint(len(table)); level <= maxLevel; level++ {
+		prev := table[level-1]
+		table = append(table, hasher.HashChildren(prev, prev))
+	}
+	return table
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rfc6962
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestEmptyHashes(t *testing.T) {
+	if got, want := len(EmptyHashes), MaxEmptyLevel+1; got != want {
+		t.Fatalf("len(EmptyHashes): got %d, want %d", got, want)
+	}
+	for _, tc := range []struct {
+		level uint
+		want  string
+	}{
+		{level: 0, want: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{level: 1, want: "a68ee79dc12813d134fd035c7328f7bd5ee68187735f7f0d2e451aea3ff6930f"},
+		{level: 64, want: "f8bfc81ad6a066ae40499868a6244bf4a8e6a35c4483144fb59493b80c7ed249"},
+		{level: 256, want: "dfbe207a8b9bb8228d1b300ba7f792cb99b47a5de57a206926b4632d0f1195fe"},
+	} {
+		t.Run(fmt.Sprintf("level:%d", tc.level), func(t *testing.T) {
+			if got := hex.EncodeToString(EmptyHashes[tc.level]); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtendEmptyHashes(t *testing.T) {
+	// Extending a prefix of the table gives the same hashes.
+	prefix := append([][]byte(nil), EmptyHashes[:10]...)
+	table := ExtendEmptyHashes(DefaultHasher, prefix, MaxEmptyLevel)
+	for level, hash := range table {
+		if !bytes.Equal(hash, EmptyHashes[level]) {
+			t.Errorf("level %d: got %x, want %x", level, hash, EmptyHashes[level])
+		}
+	}
+	// A table covering the level is returned unchanged.
+	if got, want := len(ExtendEmptyHashes(DefaultHasher, table, 5)), len(table); got != want {
+		t.Errorf("ExtendEmptyHashes: got %d levels, want %d", got, want)
+	}
+
+	hasher := NewHMAC(crypto.SHA256, []byte("key"))
+	table = ExtendEmptyHashes(hasher, nil, 3)
+	if got, want := len(table), 4; got != want {
+		t.Fatalf("ExtendEmptyHashes: got %d levels, want %d", got, want)
+	}
+	if got, want := table[0], hasher.EmptyRoot(); !bytes.Equal(got, want) {
+		t.Errorf("level 0: got %x, want %x", got, want)
+	}
+	for level := 1; level < len(table); level++ {
+		if got, want := table[level], hasher.HashChildren(table[level-1], table[level-1]); !bytes.Equal(got, want) {
+			t.Errorf("level %d: got %x, want %x", level, got, want)
+		}
+	}
+}
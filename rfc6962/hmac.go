@@ -0,0 +1,58 @@
+// Copyright 2016 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rfc6962
+
+import (
+	"crypto"
+	"crypto/hmac"
+)
+
+// HMACHasher is a keyed variant of the RFC6962 tree hashing algorithm. It
+// computes HMACs instead of plain hashes, with the same domain separation
+// prefixes. Only the holders of the key can compute and verify the tree
+// hashes.
+type HMACHasher struct {
+	crypto.Hash
+	key []byte
+}
+
+// NewHMAC creates a new keyed LogHasher on the passed in hash function.
+func NewHMAC(h crypto.Hash, key []byte) *HMACHasher {
+	return &HMACHasher{Hash: h, key: append([]byte(nil), key...)}
+}
+
+// EmptyRoot returns a special case for an empty tree.
+func (t *HMACHasher) EmptyRoot() []byte {
+	return hmac.New(t.New, t.key).Sum(nil)
+}
+
+// HashLeaf returns the keyed Merkle tree leaf hash of the data passed in
+// through leaf. The data in leaf is prefixed by the LeafHashPrefix.
+func (t *HMACHasher) HashLeaf(leaf []byte) []byte {
+	h := hmac.New(t.New, t.key)
+	h.Write([]byte{RFC6962LeafHashPrefix})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// HashChildren returns the keyed inner Merkle tree node hash of the two child
+// nodes l and r. The hashed structure is NodeHashPrefix||l||r.
+func (t *HMACHasher) HashChildren(l, r []byte) []byte {
+	h := hmac.New(t.New, t.key)
+	h.Write([]byte{RFC6962NodeHashPrefix})
+	h.Write(l)
+	h.Write(r)
+	return h.Sum(nil)
+}
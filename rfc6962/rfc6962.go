@@ -17,7 +17,8 @@ package rfc6962
 
 import (
 	"crypto"
-	_ "crypto/sha256" // SHA256 is the default algorithm.
+	"crypto/sha256" // SHA256 is the default algorithm.
+	"hash"
 )
 
 // Domain separation prefixes
@@ -53,6 +54,48 @@ func (t *Hasher) HashLeaf(leaf []byte) []byte {
 	return h.Sum(nil)
 }
 
+// HashLeaves returns the Merkle tree leaf hashes of the given leaves, the same
+// as HashLeaf would return for each of them. It is cheaper than calling
+// HashLeaf in a loop: the hash state is reused, and all the returned hashes are
+// allocated in one buffer.
+func (t *Hasher) HashLeaves(leaves [][]byte) [][]byte {
+	size := t.Size()
+	buf := make([]byte, 0, len(leaves)*size)
+	hashes := make([][]byte, len(leaves))
+	h := t.New()
+	for i, leaf := range leaves {
+		h.Reset()
+		h.Write([]byte{RFC6962LeafHashPrefix})
+		h.Write(leaf)
+		buf = h.Sum(buf)
+		// Limit the capacity, so that appending to one hash can not overwrite the
+		// subsequent ones.
+		hashes[i] = buf[len(buf)-size : len(buf) : len(buf)]
+	}
+	return hashes
+}
+
+// NewLeafWriter returns a hash.Hash which computes the Merkle tree leaf hash
+// of all the data written to it, i.e. the same as HashLeaf would return for
+// the concatenated data. This allows hashing large leaves without buffering
+// them in memory. Calling Reset on the returned hash starts a new leaf.
+func (t *Hasher) NewLeafWriter() hash.Hash {
+	w := &leafWriter{Hash: t.New()}
+	w.Reset()
+	return w
+}
+
+// leafWriter is a hash.Hash which prefixes all data with LeafHashPrefix.
+type leafWriter struct {
+	hash.Hash
+}
+
+// Reset resets the hash to its initial state, which includes the prefix.
+func (w *leafWriter) Reset() {
+	w.Hash.Reset()
+	w.Hash.Write([]byte{RFC6962LeafHashPrefix})
+}
+
 // HashChildren returns the inner Merkle tree node hash of the two child nodes l and r.
 // The hashed structure is NodeHashPrefix||l||r.
 func (t *Hasher) HashChildren(l, r []byte) []byte {
@@ -66,3 +109,23 @@ func (t *Hasher) HashChildren(l, r []byte) []byte {
 	h.Write(b)
 	return h.Sum(nil)
 }
+
+// HashChildrenTo computes the same hash as HashChildren, writes it to dst, and
+// returns the resulting slice. The dst slice is reused if it has capacity for
+// Size() bytes, and it is allowed to overlap with l and r. For SHA256 hashes
+// of 32-byte children it does not allocate memory.
+func (t *Hasher) HashChildrenTo(dst, l, r []byte) []byte {
+	if t.Hash == crypto.SHA256 && len(l) == sha256.Size && len(r) == sha256.Size {
+		var buf [1 + 2*sha256.Size]byte
+		buf[0] = RFC6962NodeHashPrefix
+		copy(buf[1:], l)
+		copy(buf[1+sha256.Size:], r)
+		sum := sha256.Sum256(buf[:])
+		return append(dst[:0], sum[:]...)
+	}
+	h := t.New()
+	h.Write([]byte{RFC6962NodeHashPrefix})
+	h.Write(l)
+	h.Write(r)
+	return h.Sum(dst[:0])
+}
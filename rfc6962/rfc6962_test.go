@@ -16,7 +16,11 @@ package rfc6962
 
 import (
 	"bytes"
+	"crypto"
+	_ "crypto/sha512"
 	"encoding/hex"
+	"fmt"
+	"io"
 	"testing"
 )
 
@@ -69,6 +73,112 @@ func TestRFC6962Hasher(t *testing.T) {
 	}
 }
 
+func TestHMACHasher(t *testing.T) {
+	hasher := NewHMAC(crypto.SHA256, []byte("key"))
+	for _, tc := range []struct {
+		desc string
+		got  []byte
+		want string
+	}{
+		// echo -n | openssl dgst -sha256 -hmac key
+		{
+			desc: "HMAC Empty",
+			want: "5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0",
+			got:  hasher.EmptyRoot(),
+		},
+		// echo -n 004C313233343536 | xxd -r -p | openssl dgst -sha256 -hmac key
+		{
+			desc: "HMAC Leaf",
+			want: "93a626156036638d9c4f5532649f403343faec30b6c308e757756d9d2c74a20d",
+			got:  hasher.HashLeaf([]byte("L123456")),
+		},
+		// echo -n 014E3132334E343536 | xxd -r -p | openssl dgst -sha256 -hmac key
+		{
+			desc: "HMAC Node",
+			want: "cdc21dae30951e59c848673e38ad22c41fed7edb47c72b94f51a8749414c9250",
+			got:  hasher.HashChildren([]byte("N123"), []byte("N456")),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got := hex.EncodeToString(tc.got); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	// A different key gives different hashes.
+	other := NewHMAC(crypto.SHA256, []byte("other key"))
+	if bytes.Equal(hasher.HashLeaf(nil), other.HashLeaf(nil)) {
+		t.Error("HashLeaf: same hash for different keys")
+	}
+}
+
+func TestHashLeaves(t *testing.T) {
+	for _, hasher := range []*Hasher{DefaultHasher, New(crypto.SHA512)} {
+		t.Run(fmt.Sprintf("%v", hasher.Hash), func(t *testing.T) {
+			leaves := make([][]byte, 100)
+			for i := range leaves {
+				leaves[i] = bytes.Repeat([]byte{byte(i)}, i)
+			}
+			hashes := hasher.HashLeaves(leaves)
+			if got, want := len(hashes), len(leaves); got != want {
+				t.Fatalf("HashLeaves: got %d hashes, want %d", got, want)
+			}
+			for i, leaf := range leaves {
+				if got, want := hashes[i], hasher.HashLeaf(leaf); !bytes.Equal(got, want) {
+					t.Errorf("HashLeaves: hash %d: got %x, want %x", i, got, want)
+				}
+			}
+			// The hashes do not share capacity.
+			_ = append(hashes[0], 1, 2, 3)
+			if got, want := hashes[1], hasher.HashLeaf(leaves[1]); !bytes.Equal(got, want) {
+				t.Errorf("HashLeaves: hash 1 overwritten: got %x, want %x", got, want)
+			}
+		})
+	}
+	if got := DefaultHasher.HashLeaves(nil); len(got) != 0 {
+		t.Errorf("HashLeaves(nil): got %x, want empty", got)
+	}
+}
+
+func TestNewLeafWriter(t *testing.T) {
+	for _, hasher := range []*Hasher{DefaultHasher, New(crypto.SHA512)} {
+		for _, size := range []int{0, 1, 63, 64, 65, 1000, 1 << 20} {
+			t.Run(fmt.Sprintf("%v:%d", hasher.Hash, size), func(t *testing.T) {
+				data := make([]byte, size)
+				for i := range data {
+					data[i] = byte(i * 7)
+				}
+				want := hasher.HashLeaf(data)
+
+				w := hasher.NewLeafWriter()
+				for chunk := data; len(chunk) != 0; {
+					n := 1 + len(chunk)/3
+					if _, err := w.Write(chunk[:n]); err != nil {
+						t.Fatalf("Write: %v", err)
+					}
+					chunk = chunk[n:]
+				}
+				if got := w.Sum(nil); !bytes.Equal(got, want) {
+					t.Errorf("Sum: got %x, want %x", got, want)
+				}
+				if got, want := w.Size(), hasher.Size(); got != want {
+					t.Errorf("Size: got %d, want %d", got, want)
+				}
+
+				// Reset starts a new leaf with the same prefix.
+				w.Reset()
+				if _, err := io.Copy(w, bytes.NewReader(data)); err != nil {
+					t.Fatalf("Copy: %v", err)
+				}
+				if got := w.Sum(nil); !bytes.Equal(got, want) {
+					t.Errorf("Sum after Reset: got %x, want %x", got, want)
+				}
+			})
+		}
+	}
+}
+
 // TODO(pavelkalinnikov): Apply this test to all LogHasher implementations.
 func TestRFC6962HasherCollisions(t *testing.T) {
 	hasher := DefaultHasher
@@ -97,6 +207,51 @@ func TestRFC6962HasherCollisions(t *testing.T) {
 	}
 }
 
+func TestHashChildrenTo(t *testing.T) {
+	hasher := DefaultHasher
+	l := hasher.HashLeaf([]byte("left"))
+	r := hasher.HashLeaf([]byte("right"))
+	want := hasher.HashChildren(l, r)
+
+	for _, tc := range []struct {
+		desc   string
+		hasher *Hasher
+		l, r   []byte
+	}{
+		{desc: "sha256", hasher: hasher, l: l, r: r},
+		{desc: "short-children", hasher: hasher, l: []byte("N123"), r: []byte("N456")},
+		{desc: "sha512", hasher: New(crypto.SHA512), l: l, r: r},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			want := tc.hasher.HashChildren(tc.l, tc.r)
+			if got := tc.hasher.HashChildrenTo(nil, tc.l, tc.r); !bytes.Equal(got, want) {
+				t.Errorf("HashChildrenTo(nil): got %x, want %x", got, want)
+			}
+			dst := make([]byte, tc.hasher.Size())
+			if got := tc.hasher.HashChildrenTo(dst, tc.l, tc.r); !bytes.Equal(got, want) {
+				t.Errorf("HashChildrenTo(dst): got %x, want %x", got, want)
+			}
+		})
+	}
+
+	// The output can overlap with the inputs.
+	dst := append([]byte{}, l...)
+	if got := hasher.HashChildrenTo(dst, dst, r); !bytes.Equal(got, want) {
+		t.Errorf("HashChildrenTo(l): got %x, want %x", got, want)
+	}
+	dst = append([]byte{}, r...)
+	if got := hasher.HashChildrenTo(dst, l, dst); !bytes.Equal(got, want) {
+		t.Errorf("HashChildrenTo(r): got %x, want %x", got, want)
+	}
+
+	dst = make([]byte, hasher.Size())
+	if allocs := testing.AllocsPerRun(100, func() {
+		dst = hasher.HashChildrenTo(dst, l, r)
+	}); allocs != 0 {
+		t.Errorf("HashChildrenTo: got %v allocs, want 0", allocs)
+	}
+}
+
 func BenchmarkHashChildren(b *testing.B) {
 	h := DefaultHasher
 	l := h.HashLeaf([]byte("one"))
@@ -105,3 +260,33 @@ func BenchmarkHashChildren(b *testing.B) {
 		_ = h.HashChildren(l, r)
 	}
 }
+
+func BenchmarkHashChildrenTo(b *testing.B) {
+	h := DefaultHasher
+	l := h.HashLeaf([]byte("one"))
+	r := h.HashLeaf([]byte("or other"))
+	dst := make([]byte, h.Size())
+	for i := 0; i < b.N; i++ {
+		dst = h.HashChildrenTo(dst, l, r)
+	}
+}
+
+func BenchmarkHashLeaves(b *testing.B) {
+	h := DefaultHasher
+	leaves := make([][]byte, 1024)
+	for i := range leaves {
+		leaves[i] = bytes.Repeat([]byte{byte(i)}, 200)
+	}
+	b.Run("HashLeaf", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, leaf := range leaves {
+				_ = h.HashLeaf(leaf)
+			}
+		}
+	})
+	b.Run("HashLeaves", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			_ = h.HashLeaves(leaves)
+		}
+	})
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rfc9162
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// The bounds of the variable-length vectors, in bytes.
+const (
+	minLogID    = 2
+	maxLogID    = 127
+	minNodeHash = 32
+	maxNodeHash = 1<<8 - 1
+	maxVec16    = 1<<16 - 1
+)
+
+var errShort = errors.New("truncated input")
+
+// MarshalBinary returns the TLS encoding of the tree head.
+func (th *TreeHeadDataV2) MarshalBinary() ([]byte, error) {
+	var w writer
+	w.u64(th.Timestamp)
+	w.u64(th.TreeSize)
+	w.nodeHash(th.RootHash)
+	w.vec16(func() {
+		for _, ext := range th.Extensions {
+			w.u16(ext.Type)
+			w.vec16(func() { w.buf = append(w.buf, ext.Data...) })
+		}
+	})
+	return w.buf, w.err
+}
+
+// UnmarshalBinary decodes the tree head from its TLS encoding.
+func (th *TreeHeadDataV2) UnmarshalBinary(data []byte) error {
+	r := reader{buf: data}
+	th.Timestamp = r.u64()
+	th.TreeSize = r.u64()
+	th.RootHash = r.nodeHash()
+	exts := reader{buf: r.vec16()}
+	th.Extensions = nil
+	for len(exts.buf) != 0 && exts.err == nil {
+		ext := Extension{Type: exts.u16()}
+		ext.Data = exts.vec16()
+		th.Extensions = append(th.Extensions, ext)
+	}
+	return r.done(exts.err)
+}
+
+// MarshalBinary returns the TLS encoding of the inclusion proof.
+func (p *InclusionProofDataV2) MarshalBinary() ([]byte, error) {
+	var w writer
+	w.logID(p.LogID)
+	w.u64(p.TreeSize)
+	w.u64(p.LeafIndex)
+	w.path(p.InclusionPath)
+	return w.buf, w.err
+}
+
+// UnmarshalBinary decodes the inclusion proof from its TLS encoding.
+func (p *InclusionProofDataV2) UnmarshalBinary(data []byte) error {
+	r := reader{buf: data}
+	p.LogID = r.logID()
+	p.TreeSize = r.u64()
+	p.LeafIndex = r.u64()
+	p.InclusionPath = r.path()
+	return r.done(nil)
+}
+
+// MarshalBinary returns the TLS encoding of the consistency proof.
+func (p *ConsistencyProofDataV2) MarshalBinary() ([]byte, error) {
+	var w writer
+	w.logID(p.LogID)
+	w.u64(p.TreeSize1)
+	w.u64(p.TreeSize2)
+	w.path(p.ConsistencyPath)
+	return w.buf, w.err
+}
+
+// UnmarshalBinary decodes the consistency proof from its TLS encoding.
+func (p *ConsistencyProofDataV2) UnmarshalBinary(data []byte) error {
+	r := reader{buf: data}
+	p.LogID = r.logID()
+	p.TreeSize1 = r.u64()
+	p.TreeSize2 = r.u64()
+	p.ConsistencyPath = r.path()
+	return r.done(nil)
+}
+
+// writer builds a TLS encoding. The first error is sticky, and all subsequent
+// writes are no-op.
+type writer struct {
+	buf []byte
+	err error
+}
+
+func (w *writer) u16(v uint16) {
+	w.buf = append(w.buf, byte(v>>8), byte(v))
+}
+
+func (w *writer) u64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+// vec8 writes an opaque vector with a 1-byte length prefix, and checks that its
+// length is within [min, max].
+func (w *writer) vec8(b []byte, min, max int, what string) {
+	if w.err != nil {
+		return
+	}
+	if l := len(b); l < min || l > max {
+		w.err = fmt.Errorf("%s length %d out of range [%d, %d]", what, l, min, max)
+		return
+	}
+	w.buf = append(w.buf, byte(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// vec16 writes a vector with a 2-byte length prefix. The contents are written
+// by the passed in function.
+func (w *writer) vec16(contents func()) {
+	if w.err != nil {
+		return
+	}
+	pos := len(w.buf)
+	w.buf = append(w.buf, 0, 0)
+	contents()
+	if w.err != nil {
+		return
+	}
+	l := len(w.buf) - pos - 2
+	if l > maxVec16 {
+		w.err = fmt.Errorf("vector length %d exceeds %d", l, maxVec16)
+		return
+	}
+	binary.BigEndian.PutUint16(w.buf[pos:], uint16(l))
+}
+
+func (w *writer) logID(id []byte) {
+	w.vec8(id, minLogID, maxLogID, "log ID")
+}
+
+func (w *writer) nodeHash(hash []byte) {
+	w.vec8(hash, minNodeHash, maxNodeHash, "node hash")
+}
+
+// path writes a proof path. The RFC requires it to be non-empty, but it is
+// empty in trivial proofs, so this is allowed here.
+func (w *writer) path(hashes [][]byte) {
+	w.vec16(func() {
+		for _, hash := range hashes {
+			w.nodeHash(hash)
+		}
+	})
+}
+
+// reader parses a TLS encoding. The first error is sticky, and all subsequent
+// reads return zero values.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.buf) < n {
+		r.err = errShort
+		return nil
+	}
+	res := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	return res
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// vec8 reads an opaque vector with a 1-byte length prefix, and checks that its
+// length is within [min, max].
+func (r *reader) vec8(min, max int, what string) []byte {
+	b := r.bytes(1)
+	if b == nil {
+		return nil
+	}
+	if l := int(b[0]); l < min || l > max {
+		r.err = fmt.Errorf("%s length %d out of range [%d, %d]", what, l, min, max)
+		return nil
+	}
+	return r.bytes(int(b[0]))
+}
+
+// vec16 reads a vector with a 2-byte length prefix, and returns its contents.
+func (r *reader) vec16() []byte {
+	return r.bytes(int(r.u16()))
+}
+
+func (r *reader) logID() []byte {
+	return r.vec8(minLogID, maxLogID, "log ID")
+}
+
+func (r *reader) nodeHash() []byte {
+	return r.vec8(minNodeHash, maxNodeHash, "node hash")
+}
+
+func (r *reader) path() [][]byte {
+	p := reader{buf: r.vec16()}
+	var hashes [][]byte
+	for len(p.buf) != 0 && p.err == nil {
+		hashes = append(hashes, p.nodeHash())
+	}
+	if r.err == nil {
+		r.err = p.err
+	}
+	return hashes
+}
+
+// done returns the first error encountered by this reader or the passed in
+// nested one, and checks that the input is fully consumed.
+func (r *reader) done(nested error) error {
+	if r.err != nil {
+		return r.err
+	} else if nested != nil {
+		return nested
+	} else if len(r.buf) != 0 {
+		return fmt.Errorf("%d trailing bytes", len(r.buf))
+	}
+	return nil
+}
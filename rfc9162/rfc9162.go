@@ -0,0 +1,88 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rfc9162 provides the tree hashing, tree head and proof structures of
+// Certificate Transparency version 2.0, according to RFC9162.
+//
+// The Merkle tree hashing of RFC9162 is the same as in RFC6962, so the
+// rfc6962 hasher and the proof package are used for the tree operations. This
+// package adds the TLS encodings of the tree head and proofs, and helpers that
+// verify the decoded structures.
+package rfc9162
+
+import (
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// DefaultHasher is the SHA256 based LogHasher, which is the only hash algorithm
+// registered for RFC9162 logs.
+var DefaultHasher merkle.LogHasher = rfc6962.DefaultHasher
+
+// Extension is a tree head extension.
+type Extension struct {
+	Type uint16
+	Data []byte
+}
+
+// TreeHeadDataV2 is the TreeHeadDataV2 structure, see RFC9162 section 4.9.
+type TreeHeadDataV2 struct {
+	Timestamp  uint64
+	TreeSize   uint64
+	RootHash   []byte
+	Extensions []Extension
+}
+
+// InclusionProofDataV2 is the InclusionProofDataV2 structure, see RFC9162
+// section 4.12.
+type InclusionProofDataV2 struct {
+	// LogID is the contents of the DER encoding of the log OID, without the
+	// ASN.1 tag and length.
+	LogID         []byte
+	TreeSize      uint64
+	LeafIndex     uint64
+	InclusionPath [][]byte
+}
+
+// ConsistencyProofDataV2 is the ConsistencyProofDataV2 structure, see RFC9162
+// section 4.11.
+type ConsistencyProofDataV2 struct {
+	// LogID is the contents of the DER encoding of the log OID, without the
+	// ASN.1 tag and length.
+	LogID           []byte
+	TreeSize1       uint64
+	TreeSize2       uint64
+	ConsistencyPath [][]byte
+}
+
+// Verify checks that the proof proves inclusion of the leaf with the given hash
+// into the tree with the given tree head.
+func (p *InclusionProofDataV2) Verify(hasher merkle.LogHasher, leafHash []byte, th *TreeHeadDataV2) error {
+	if p.TreeSize != th.TreeSize {
+		return fmt.Errorf("tree size mismatch: proof %d, tree head %d", p.TreeSize, th.TreeSize)
+	}
+	return proof.VerifyInclusion(hasher, p.LeafIndex, p.TreeSize, leafHash, p.InclusionPath, th.RootHash)
+}
+
+// Verify checks that the proof proves consistency between the two tree heads.
+func (p *ConsistencyProofDataV2) Verify(hasher merkle.LogHasher, th1, th2 *TreeHeadDataV2) error {
+	if p.TreeSize1 != th1.TreeSize || p.TreeSize2 != th2.TreeSize {
+		return fmt.Errorf("tree sizes mismatch: proof %d:%d, tree heads %d:%d",
+			p.TreeSize1, p.TreeSize2, th1.TreeSize, th2.TreeSize)
+	}
+	return proof.VerifyConsistency(hasher, p.TreeSize1, p.TreeSize2, p.ConsistencyPath, th1.RootHash, th2.RootHash)
+}
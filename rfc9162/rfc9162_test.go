@@ -0,0 +1,255 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rfc9162
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+// logID is the contents of the DER encoding of OID 1.3.101.8192.
+var logID = []byte{0x2b, 0x65, 0x90, 0x00}
+
+func hash(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func TestEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		desc string
+		msg  encoding.BinaryMarshaler
+		want string
+	}{
+		{
+			desc: "tree-head",
+			msg: &TreeHeadDataV2{
+				Timestamp: 0x0102030405060708, TreeSize: 7, RootHash: hash(0xaa),
+				Extensions: []Extension{{Type: 1, Data: []byte{5, 6}}, {Type: 2}},
+			},
+			want: "0102030405060708" + "0000000000000007" + "20" + strings.Repeat("aa", 32) +
+				"000a" + "0001" + "0002" + "0506" + "0002" + "0000",
+		},
+		{
+			desc: "tree-head-no-ext",
+			msg:  &TreeHeadDataV2{Timestamp: 1, TreeSize: 0, RootHash: hash(0xaa)},
+			want: "0000000000000001" + "0000000000000000" + "20" + strings.Repeat("aa", 32) + "0000",
+		},
+		{
+			desc: "inclusion",
+			msg: &InclusionProofDataV2{
+				LogID: logID, TreeSize: 5, LeafIndex: 2, InclusionPath: [][]byte{hash(1), hash(2)},
+			},
+			want: "042b659000" + "0000000000000005" + "0000000000000002" + "0042" +
+				"20" + strings.Repeat("01", 32) + "20" + strings.Repeat("02", 32),
+		},
+		{
+			desc: "inclusion-empty",
+			msg:  &InclusionProofDataV2{LogID: logID, TreeSize: 1},
+			want: "042b659000" + "0000000000000001" + "0000000000000000" + "0000",
+		},
+		{
+			desc: "consistency",
+			msg: &ConsistencyProofDataV2{
+				LogID: logID, TreeSize1: 3, TreeSize2: 8, ConsistencyPath: [][]byte{hash(3)},
+			},
+			want: "042b659000" + "0000000000000003" + "0000000000000008" + "0021" +
+				"20" + strings.Repeat("03", 32),
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			data, err := tc.msg.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if got := hex.EncodeToString(data); got != tc.want {
+				t.Errorf("MarshalBinary: got %s, want %s", got, tc.want)
+			}
+
+			var got encoding.BinaryUnmarshaler
+			switch tc.msg.(type) {
+			case *TreeHeadDataV2:
+				got = &TreeHeadDataV2{}
+			case *InclusionProofDataV2:
+				got = &InclusionProofDataV2{}
+			case *ConsistencyProofDataV2:
+				got = &ConsistencyProofDataV2{}
+			}
+			if err := got.UnmarshalBinary(data); err != nil {
+				t.Fatalf("UnmarshalBinary: %v", err)
+			}
+			if diff := cmp.Diff(got, tc.msg, cmpEmpty); diff != "" {
+				t.Errorf("UnmarshalBinary: diff(-got +want):\n%s", diff)
+			}
+
+			// All truncations of the input must fail to decode, and so must the input
+			// with extra bytes.
+			for l := 0; l < len(data); l++ {
+				if err := got.UnmarshalBinary(data[:l]); err == nil {
+					t.Fatalf("UnmarshalBinary(%d bytes): want error", l)
+				}
+			}
+			if err := got.UnmarshalBinary(append(data, 0)); err == nil {
+				t.Error("UnmarshalBinary with trailing bytes: want error")
+			}
+		})
+	}
+}
+
+// cmpEmpty treats nil and empty slices as equal.
+var cmpEmpty = cmp.FilterValues(func(x, y []byte) bool {
+	return len(x) == 0 && len(y) == 0
+}, cmp.Ignore())
+
+func TestMarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		msg     encoding.BinaryMarshaler
+		wantErr string
+	}{
+		{
+			desc:    "short-root",
+			msg:     &TreeHeadDataV2{RootHash: hash(0)[:31]},
+			wantErr: "node hash length 31",
+		},
+		{
+			desc: "long-extension",
+			msg: &TreeHeadDataV2{RootHash: hash(0), Extensions: []Extension{
+				{Data: make([]byte, 1<<16)},
+			}},
+			wantErr: "vector length 65536",
+		},
+		{
+			desc:    "short-log-id",
+			msg:     &InclusionProofDataV2{LogID: []byte{1}},
+			wantErr: "log ID length 1",
+		},
+		{
+			desc:    "long-log-id",
+			msg:     &ConsistencyProofDataV2{LogID: make([]byte, 128)},
+			wantErr: "log ID length 128",
+		},
+		{
+			desc:    "bad-path",
+			msg:     &InclusionProofDataV2{LogID: logID, InclusionPath: [][]byte{hash(0), nil}},
+			wantErr: "node hash length 0",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := tc.msg.MarshalBinary()
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("MarshalBinary: %v, want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		data    string
+		wantErr string
+	}{
+		{desc: "short-log-id", data: "012b", wantErr: "log ID length 1"},
+		{desc: "truncated", data: "042b6590", wantErr: "truncated"},
+		{
+			desc:    "short-hash",
+			data:    "042b659000" + "0000000000000001" + "0000000000000000" + "0002" + "0100",
+			wantErr: "node hash length 1",
+		},
+		{
+			desc:    "path-overflow",
+			data:    "042b659000" + "0000000000000001" + "0000000000000000" + "0021" + "21" + strings.Repeat("00", 32),
+			wantErr: "truncated",
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			data, err := hex.DecodeString(tc.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var p InclusionProofDataV2
+			err = p.UnmarshalBinary(data)
+			if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+				t.Errorf("UnmarshalBinary: %v, want error %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	const size = 20
+	tree := testonly.New(DefaultHasher)
+	for i := 0; i < size; i++ {
+		tree.AppendData([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	treeHead := func(size uint64) *TreeHeadDataV2 {
+		return &TreeHeadDataV2{TreeSize: size, RootHash: tree.HashAt(size)}
+	}
+
+	for size2 := uint64(1); size2 <= size; size2++ {
+		th2 := treeHead(size2)
+		for index := uint64(0); index < size2; index++ {
+			path, err := tree.InclusionProof(index, size2)
+			if err != nil {
+				t.Fatalf("InclusionProof: %v", err)
+			}
+			p := roundTripInclusion(t, &InclusionProofDataV2{
+				LogID: logID, TreeSize: size2, LeafIndex: index, InclusionPath: path,
+			})
+			if err := p.Verify(DefaultHasher, tree.LeafHash(index), th2); err != nil {
+				t.Errorf("Verify(%d, %d): %v", index, size2, err)
+			}
+			if err := p.Verify(DefaultHasher, tree.LeafHash(index), treeHead(size2-1)); err == nil {
+				t.Errorf("Verify(%d, %d): want error for tree head of another size", index, size2)
+			}
+		}
+
+		for size1 := uint64(1); size1 <= size2; size1++ {
+			path, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			p := &ConsistencyProofDataV2{
+				LogID: logID, TreeSize1: size1, TreeSize2: size2, ConsistencyPath: path,
+			}
+			if err := p.Verify(DefaultHasher, treeHead(size1), th2); err != nil {
+				t.Errorf("Verify(%d, %d): %v", size1, size2, err)
+			}
+			if err := p.Verify(DefaultHasher, th2, th2); err == nil && size1 != size2 {
+				t.Errorf("Verify(%d, %d): want error for mismatching tree heads", size1, size2)
+			}
+		}
+	}
+}
+
+func roundTripInclusion(t *testing.T, p *InclusionProofDataV2) *InclusionProofDataV2 {
+	t.Helper()
+	data, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	var res InclusionProofDataV2
+	if err := res.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	return &res
+}
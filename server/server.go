@@ -0,0 +1,203 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package server provides a reference HTTP server which serves the root hashes
+// and proofs of a Merkle tree backed by a storage.Tree. Together with the
+// storage package, it allows standing up a verifiable log service.
+//
+// The server exposes the following JSON endpoints. Hashes are encoded in
+// base64, as usual for []byte in JSON.
+//
+//	GET /root[?size=N]                            -> proof.Checkpoint
+//	GET /proof/inclusion?index=I&size=N           -> InclusionResponse
+//	GET /proof/consistency?size1=N1&size2=N2      -> ConsistencyResponse
+//
+// If the size parameter of the root request is omitted, the current tree size
+// is used.
+//
+// Note that there is no gRPC flavour of the server. It would make the module
+// depend on google.golang.org/grpc and protobuf, and require generated code,
+// while the module keeps its dependencies minimal. A gRPC service can be built
+// outside of this module on top of the same storage.Tree methods that the
+// handlers here use: Size, HashAt, InclusionProof and ConsistencyProof.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/storage"
+)
+
+// InclusionResponse is the response to the inclusion proof request.
+type InclusionResponse struct {
+	Index uint64   `json:"index"`
+	Size  uint64   `json:"size"`
+	Proof [][]byte `json:"proof"`
+}
+
+// ConsistencyResponse is the response to the consistency proof request.
+type ConsistencyResponse struct {
+	Size1 uint64   `json:"size1"`
+	Size2 uint64   `json:"size2"`
+	Proof [][]byte `json:"proof"`
+}
+
+// Server is an http.Handler serving the root hashes and proofs of a tree. It is
+// safe for concurrent use. The tree must not be used directly while the server
+// is running; use the AppendData and Append methods to grow it.
+type Server struct {
+	mu   sync.RWMutex
+	tree *storage.Tree
+	mux  *http.ServeMux
+}
+
+// New returns a Server for the given tree.
+func New(tree *storage.Tree) *Server {
+	s := &Server{tree: tree, mux: http.NewServeMux()}
+	s.mux.HandleFunc("/root", s.handleRoot)
+	s.mux.HandleFunc("/proof/inclusion", s.handleInclusion)
+	s.mux.HandleFunc("/proof/consistency", s.handleConsistency)
+	return s
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// AppendData appends the leaves with the given data to the tree.
+func (s *Server) AppendData(entries ...[]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.AppendData(entries...)
+}
+
+// Append appends the leaves with the given hashes to the tree.
+func (s *Server) Append(hashes ...[]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tree.Append(hashes...)
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	size := s.tree.Size()
+	if r.URL.Query().Get("size") != "" {
+		var err error
+		if size, err = s.getSize(r, "size"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	root, err := s.tree.HashAt(size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, &proof.Checkpoint{Size: size, RootHash: root})
+}
+
+func (s *Server) handleInclusion(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	size, err := s.getSize(r, "size")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	index, err := getUint(r, "index")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if index >= size {
+		http.Error(w, fmt.Sprintf("index %d out of range for size %d", index, size), http.StatusBadRequest)
+		return
+	}
+	hashes, err := s.tree.InclusionProof(index, size)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, &InclusionResponse{Index: index, Size: size, Proof: hashes})
+}
+
+func (s *Server) handleConsistency(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	size1, err := s.getSize(r, "size1")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size2, err := s.getSize(r, "size2")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if size1 > size2 {
+		http.Error(w, fmt.Sprintf("size1 %d > size2 %d", size1, size2), http.StatusBadRequest)
+		return
+	}
+	hashes, err := s.tree.ConsistencyProof(size1, size2)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, &ConsistencyResponse{Size1: size1, Size2: size2, Proof: hashes})
+}
+
+// getSize returns the tree size from the given request parameter. Returns an
+// error if it exceeds the current tree size.
+func (s *Server) getSize(r *http.Request, name string) (uint64, error) {
+	size, err := getUint(r, name)
+	if err != nil {
+		return 0, err
+	} else if treeSize := s.tree.Size(); size > treeSize {
+		return 0, fmt.Errorf("%s %d exceeds the tree size %d", name, size, treeSize)
+	}
+	return size, nil
+}
+
+// getUint parses the given request parameter as uint64.
+func getUint(r *http.Request, name string) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, fmt.Errorf("missing %s", name)
+	}
+	v, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad %s: %v", name, err)
+	}
+	return v, nil
+}
+
+// writeJSON writes the given value as the JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/storage"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func newServer(t *testing.T, size uint64) *Server {
+	t.Helper()
+	tree, err := storage.NewTree(hasher, storage.NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	s := New(tree)
+	for i := uint64(0); i < size; i++ {
+		if err := s.AppendData([]byte(fmt.Sprintf("leaf: %d", i))); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+	}
+	return s
+}
+
+// get sends the GET request to the server, checks the response status code,
+// and decodes the response into the given value if the request succeeded.
+func get(t *testing.T, s *Server, url string, wantCode int, v interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if got := w.Code; got != wantCode {
+		t.Fatalf("GET %s: got status %d, want %d: %s", url, got, wantCode, w.Body)
+	}
+	if wantCode != http.StatusOK {
+		return
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("GET %s: decoding response: %v", url, err)
+	}
+}
+
+func TestServer(t *testing.T) {
+	const size = 21
+	s := newServer(t, size)
+
+	var latest proof.Checkpoint
+	get(t, s, "/root", http.StatusOK, &latest)
+	if got, want := latest.Size, uint64(size); got != want {
+		t.Fatalf("root: got size %d, want %d", got, want)
+	}
+
+	roots := make([][]byte, size+1)
+	for i := range roots {
+		var cp proof.Checkpoint
+		get(t, s, fmt.Sprintf("/root?size=%d", i), http.StatusOK, &cp)
+		if got, want := cp.Size, uint64(i); got != want {
+			t.Fatalf("root: got size %d, want %d", got, want)
+		}
+		roots[i] = cp.RootHash
+	}
+	if diff := cmp.Diff(latest.RootHash, roots[size]); diff != "" {
+		t.Errorf("root: diff(-got +want):\n%s", diff)
+	}
+
+	for size2 := uint64(1); size2 <= size; size2++ {
+		for index := uint64(0); index < size2; index++ {
+			var resp InclusionResponse
+			get(t, s, fmt.Sprintf("/proof/inclusion?index=%d&size=%d", index, size2), http.StatusOK, &resp)
+			leafHash := hasher.HashLeaf([]byte(fmt.Sprintf("leaf: %d", index)))
+			if err := proof.VerifyInclusion(hasher, index, size2, leafHash, resp.Proof, roots[size2]); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d): %v", index, size2, err)
+			}
+		}
+		for size1 := uint64(1); size1 <= size2; size1++ {
+			var resp ConsistencyResponse
+			get(t, s, fmt.Sprintf("/proof/consistency?size1=%d&size2=%d", size1, size2), http.StatusOK, &resp)
+			if err := proof.VerifyConsistency(hasher, size1, size2, resp.Proof, roots[size1], roots[size2]); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d): %v", size1, size2, err)
+			}
+		}
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	s := newServer(t, 10)
+	for _, url := range []string{
+		"/root?size=11",
+		"/root?size=-1",
+		"/proof/inclusion?size=5",
+		"/proof/inclusion?index=5&size=5",
+		"/proof/inclusion?index=0&size=11",
+		"/proof/inclusion?index=x&size=5",
+		"/proof/consistency?size1=5",
+		"/proof/consistency?size1=6&size2=5",
+		"/proof/consistency?size1=5&size2=11",
+	} {
+		t.Run(url, func(t *testing.T) {
+			get(t, s, url, http.StatusBadRequest, nil)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/root", nil))
+	if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
+		t.Errorf("POST: got status %d, want %d", got, want)
+	}
+}
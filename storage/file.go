@@ -0,0 +1,217 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// FileStore is a NodeStore backed by an append-only file. Each Write appends a
+// record with all the written nodes and a checksum, and syncs the file. When
+// the file is opened, the records are replayed into memory, and a torn
+// trailing record, e.g. left by a crash during a Write, is discarded. A corrupt
+// record followed by more data fails the opening instead, and the file is left
+// intact, because the following records were already acknowledged.
+//
+// If a Write fails, the file is truncated back to the last complete record, so
+// that the subsequent writes are not lost behind a torn record. If this is not
+// possible, or syncing the file fails, the store rejects all the subsequent
+// writes, and must be reopened.
+//
+// FileStore keeps all the nodes in memory. It is a reference implementation
+// suitable for moderately sized trees.
+type FileStore struct {
+	mem  *MemoryStore
+	file file
+	end  int64 // The offset of the end of the last complete record.
+	err  error // The error which broke the store, if any.
+}
+
+// file is the subset of *os.File methods used by FileStore.
+type file interface {
+	io.ReadWriteSeeker
+	io.Closer
+	Truncate(size int64) error
+	Sync() error
+}
+
+// OpenFileStore opens the FileStore at the given path, creating the file if it
+// does not exist.
+func OpenFileStore(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{mem: NewMemoryStore(), file: f}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	return s.file.Close()
+}
+
+// Get returns the hashes of the given nodes.
+func (s *FileStore) Get(ids []compact.NodeID) ([][]byte, error) {
+	return s.mem.Get(ids)
+}
+
+// Size returns the current tree size.
+func (s *FileStore) Size() (uint64, error) {
+	return s.mem.Size()
+}
+
+// Write appends the record with the given nodes and tree size to the file, and
+// syncs it. The changes become visible only after that.
+func (s *FileStore) Write(size uint64, nodes []Node) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+	if size < s.mem.size {
+		return fmt.Errorf("tree size %d is smaller than the current %d", size, s.mem.size)
+	}
+	if s.err != nil {
+		return fmt.Errorf("store is broken: %w", s.err)
+	}
+	rec, err := encodeRecord(size, nodes)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(rec); err != nil {
+		s.rollback(err)
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		// The state of the file is unknown after a failed sync, and retrying it
+		// is not reliable. Remove the record, and stop accepting writes.
+		s.rollback(err)
+		s.err = err
+		return err
+	}
+	s.end += int64(len(rec))
+	s.mem.write(size, nodes)
+	return nil
+}
+
+// rollback removes the partially written record from the file after the given
+// write error. If this fails, the store is marked as broken.
+func (s *FileStore) rollback(err error) {
+	if terr := s.file.Truncate(s.end); terr != nil {
+		s.err = fmt.Errorf("%v; truncating: %v", err, terr)
+	} else if _, serr := s.file.Seek(s.end, io.SeekStart); serr != nil {
+		s.err = fmt.Errorf("%v; seeking: %v", err, serr)
+	}
+}
+
+// replay reads all the records from the file, and truncates the file after the
+// last valid record if the remaining bytes are a torn trailing record.
+func (s *FileStore) replay() error {
+	data, err := io.ReadAll(s.file)
+	if err != nil {
+		return err
+	}
+	var pos int
+	for pos < len(data) {
+		size, nodes, n, err := decodeRecord(data[pos:])
+		if errors.Is(err, io.ErrUnexpectedEOF) || (err != nil && pos+n == len(data)) {
+			break // The tail is torn by an interrupted write.
+		} else if err != nil {
+			return fmt.Errorf("record at offset %d: %w", pos, err)
+		}
+		s.mem.write(size, nodes)
+		pos += n
+	}
+	if pos < len(data) {
+		if err := s.file.Truncate(int64(pos)); err != nil {
+			return err
+		}
+	}
+	s.end = int64(pos)
+	_, err = s.file.Seek(s.end, io.SeekStart)
+	return err
+}
+
+// The record format is: the tree size (8 bytes), the number of nodes (4 bytes),
+// the nodes, and the CRC32 checksum of all the preceding record bytes (4
+// bytes). Each node is: the level (1 byte), the index (8 bytes), the hash
+// length (1 byte), and the hash. All integers are big-endian.
+const recordHeaderSize = 8 + 4
+
+var errCorrupt = errors.New("corrupt record")
+
+func encodeRecord(size uint64, nodes []Node) ([]byte, error) {
+	rec := make([]byte, recordHeaderSize, recordHeaderSize+len(nodes)*(10+32)+4)
+	binary.BigEndian.PutUint64(rec, size)
+	binary.BigEndian.PutUint32(rec[8:], uint32(len(nodes)))
+	for _, node := range nodes {
+		if node.ID.Level > 255 || len(node.Hash) > 255 {
+			return nil, fmt.Errorf("node %+v with %d-byte hash can not be stored", node.ID, len(node.Hash))
+		}
+		var buf [10]byte
+		buf[0] = byte(node.ID.Level)
+		binary.BigEndian.PutUint64(buf[1:], node.ID.Index)
+		buf[9] = byte(len(node.Hash))
+		rec = append(append(rec, buf[:]...), node.Hash...)
+	}
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(rec))
+	return append(rec, sum[:]...), nil
+}
+
+// decodeRecord decodes the record at the beginning of the given data, and
+// returns its size in bytes. Returns io.ErrUnexpectedEOF if the record extends
+// beyond the data, or errCorrupt with the record size if its checksum does not
+// match.
+func decodeRecord(data []byte) (uint64, []Node, int, error) {
+	if len(data) < recordHeaderSize {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	size := binary.BigEndian.Uint64(data)
+	count := binary.BigEndian.Uint32(data[8:])
+	pos := recordHeaderSize
+	if uint64(count) > uint64(len(data)-pos)/10 { // Each node takes at least 10 bytes.
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	}
+	nodes := make([]Node, 0, count)
+	for i := uint32(0); i < count; i++ {
+		if len(data) < pos+10 {
+			return 0, nil, 0, io.ErrUnexpectedEOF
+		}
+		level, index, ln := data[pos], binary.BigEndian.Uint64(data[pos+1:]), int(data[pos+9])
+		pos += 10
+		if len(data) < pos+ln {
+			return 0, nil, 0, io.ErrUnexpectedEOF
+		}
+		hash := append([]byte(nil), data[pos:pos+ln]...)
+		pos += ln
+		nodes = append(nodes, Node{ID: compact.NewNodeID(uint(level), index), Hash: hash})
+	}
+	if len(data) < pos+4 {
+		return 0, nil, 0, io.ErrUnexpectedEOF
+	} else if binary.BigEndian.Uint32(data[pos:]) != crc32.ChecksumIEEE(data[:pos]) {
+		return 0, nil, pos + 4, errCorrupt
+	}
+	return size, nodes, pos + 4, nil
+}
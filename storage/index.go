@@ -0,0 +1,160 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// ErrNotFound is returned when a leaf hash is not in the index.
+var ErrNotFound = errors.New("not found")
+
+// IndexStore stores the mapping from leaf hashes to leaf indices, for a prefix
+// of the tree. Implementations must be safe for concurrent use.
+type IndexStore interface {
+	// Lookup returns the index of the first leaf with the given hash. Returns
+	// an error wrapping ErrNotFound if there is no such leaf.
+	Lookup(leafHash []byte) (uint64, error)
+	// Size returns the number of indexed leaves.
+	Size() (uint64, error)
+	// Add atomically indexes the given leaf hashes, which are the leaves
+	// [begin, begin+len(leafHashes)) of the tree. Requires begin == Size().
+	Add(begin uint64, leafHashes [][]byte) error
+}
+
+// MemoryIndex is an in-memory IndexStore.
+type MemoryIndex struct {
+	mu      sync.RWMutex
+	size    uint64
+	indices map[string]uint64
+}
+
+// NewMemoryIndex returns an empty MemoryIndex.
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{indices: make(map[string]uint64)}
+}
+
+// Lookup returns the index of the first leaf with the given hash.
+func (m *MemoryIndex) Lookup(leafHash []byte) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	index, ok := m.indices[string(leafHash)]
+	if !ok {
+		return 0, fmt.Errorf("leaf hash %x: %w", leafHash, ErrNotFound)
+	}
+	return index, nil
+}
+
+// Size returns the number of indexed leaves.
+func (m *MemoryIndex) Size() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.size, nil
+}
+
+// Add indexes the given leaf hashes.
+func (m *MemoryIndex) Add(begin uint64, leafHashes [][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if begin != m.size {
+		return fmt.Errorf("begin=%d, want %d", begin, m.size)
+	}
+	for i, hash := range leafHashes {
+		if _, ok := m.indices[string(hash)]; !ok {
+			m.indices[string(hash)] = begin + uint64(i)
+		}
+	}
+	m.size += uint64(len(leafHashes))
+	return nil
+}
+
+// IndexedTree is a Tree which also maintains the index of its leaf hashes. It
+// is not safe for concurrent use.
+type IndexedTree struct {
+	*Tree
+	index IndexStore
+}
+
+// NewIndexedTree returns an IndexedTree which uses the given tree and index.
+// If the index is behind the tree, e.g. because the last update was
+// interrupted, the missing leaves are indexed from the tree's nodes.
+func NewIndexedTree(tree *Tree, index IndexStore) (*IndexedTree, error) {
+	begin, err := index.Size()
+	if err != nil {
+		return nil, err
+	}
+	end := tree.Size()
+	if begin > end {
+		return nil, fmt.Errorf("index size %d exceeds the tree size %d", begin, end)
+	}
+	t := &IndexedTree{Tree: tree, index: index}
+	if begin == end {
+		return t, nil
+	}
+	ids := make([]compact.NodeID, 0, end-begin)
+	for i := begin; i < end; i++ {
+		ids = append(ids, compact.NewNodeID(0, i))
+	}
+	hashes, err := tree.store.Get(ids)
+	if err != nil {
+		return nil, err
+	}
+	if err := index.Add(begin, hashes); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// AppendData appends the leaves with the given data to the tree, and indexes
+// their hashes.
+func (t *IndexedTree) AppendData(entries ...[]byte) error {
+	hashes := make([][]byte, len(entries))
+	for i, data := range entries {
+		hashes[i] = t.hasher.HashLeaf(data)
+	}
+	return t.Append(hashes...)
+}
+
+// Append appends the leaves with the given hashes to the tree, and indexes
+// them. The tree nodes are written first, so the index is never ahead of the
+// tree.
+func (t *IndexedTree) Append(hashes ...[]byte) error {
+	begin := t.Size()
+	if err := t.Tree.Append(hashes...); err != nil {
+		return err
+	}
+	return t.index.Add(begin, hashes)
+}
+
+// ProveByHash returns the index of the first leaf with the given hash, and its
+// inclusion proof in the tree of the given size. Like the get-proof-by-hash
+// method of RFC 6962, fails if the leaf is not in the tree of this size.
+func (t *IndexedTree) ProveByHash(leafHash []byte, size uint64) (uint64, [][]byte, error) {
+	index, err := t.index.Lookup(leafHash)
+	if err != nil {
+		return 0, nil, err
+	} else if index >= size {
+		return 0, nil, fmt.Errorf("leaf hash %x at index %d: %w in tree of size %d", leafHash, index, ErrNotFound, size)
+	}
+	proof, err := t.InclusionProof(index, size)
+	if err != nil {
+		return 0, nil, err
+	}
+	return index, proof, nil
+}
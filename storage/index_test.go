@@ -0,0 +1,123 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+func TestIndexedTree(t *testing.T) {
+	leaves := [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("b"), []byte("d")}
+	tree, err := NewTree(hasher, NewMemoryStore())
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	it, err := NewIndexedTree(tree, NewMemoryIndex())
+	if err != nil {
+		t.Fatalf("NewIndexedTree: %v", err)
+	}
+	if err := it.AppendData(leaves[:2]...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	if err := it.AppendData(leaves[2:]...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	size := it.Size()
+	root, err := it.Hash()
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+
+	for _, tc := range []struct {
+		leaf      string
+		size      uint64
+		wantIndex uint64
+		wantErr   bool
+	}{
+		{leaf: "a", size: size, wantIndex: 0},
+		{leaf: "b", size: size, wantIndex: 1}, // The first of the duplicates.
+		{leaf: "d", size: size, wantIndex: 4},
+		{leaf: "c", size: 3, wantIndex: 2},
+		{leaf: "c", size: 2, wantErr: true},
+		{leaf: "e", size: size, wantErr: true},
+	} {
+		leafHash := hasher.HashLeaf([]byte(tc.leaf))
+		index, p, err := it.ProveByHash(leafHash, tc.size)
+		if tc.wantErr {
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("ProveByHash(%q, %d): got %v, want %v", tc.leaf, tc.size, err, ErrNotFound)
+			}
+			continue
+		} else if err != nil {
+			t.Fatalf("ProveByHash(%q, %d): %v", tc.leaf, tc.size, err)
+		}
+		if index != tc.wantIndex {
+			t.Errorf("ProveByHash(%q, %d): got index %d, want %d", tc.leaf, tc.size, index, tc.wantIndex)
+		}
+		want, err := it.InclusionProof(index, tc.size)
+		if err != nil {
+			t.Fatalf("InclusionProof: %v", err)
+		}
+		if diff := cmp.Diff(p, want); diff != "" {
+			t.Errorf("ProveByHash(%q, %d): diff(-got +want):\n%s", tc.leaf, tc.size, diff)
+		}
+		if tc.size == size {
+			if err := proof.VerifyInclusion(hasher, index, size, leafHash, p, root); err != nil {
+				t.Errorf("VerifyInclusion: %v", err)
+			}
+		}
+	}
+}
+
+func TestIndexedTreeRecovery(t *testing.T) {
+	store := NewMemoryStore()
+	tree, err := NewTree(hasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	// Append to the tree without indexing, as if the index update failed.
+	if err := tree.AppendData([]byte("a"), []byte("b"), []byte("c")); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	index := NewMemoryIndex()
+	if err := index.Add(0, [][]byte{hasher.HashLeaf([]byte("a"))}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	it, err := NewIndexedTree(tree, index)
+	if err != nil {
+		t.Fatalf("NewIndexedTree: %v", err)
+	}
+	if got, err := index.Size(); err != nil || got != 3 {
+		t.Errorf("Size: got %d, %v; want 3", got, err)
+	}
+	if index, _, err := it.ProveByHash(hasher.HashLeaf([]byte("c")), 3); err != nil || index != 2 {
+		t.Errorf("ProveByHash: got %d, %v; want 2", index, err)
+	}
+
+	// The index can not be ahead of the tree.
+	if err := index.Add(3, [][]byte{hasher.HashLeaf([]byte("d"))}); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := NewIndexedTree(tree, index); err == nil {
+		t.Error("NewIndexedTree: want error for index ahead of the tree")
+	}
+	if err := index.Add(2, nil); err == nil {
+		t.Error("Add: want error for wrong begin")
+	}
+}
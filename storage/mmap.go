@@ -0,0 +1,340 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+const (
+	mmapSizeFile = "SIZE"
+	// mmapChunk is the minimal amount by which the level files grow.
+	mmapChunk = 1 << 20
+)
+
+// MmapStore is a NodeStore which keeps the node hashes in memory-mapped files,
+// one per tree level, so that huge trees can be served without loading the
+// hashes into RAM. All the hashes must have the same size.
+//
+// The files are append-only: Write requires that the nodes of each level are
+// written in order of their indices, and that every batch completes all the
+// perfect nodes of the [0, size) tree, and has no nodes outside of it. This is
+// how Tree writes the nodes.
+//
+// The committed tree size is stored in a separate file, which is atomically
+// replaced after the node hashes are synced. The data beyond the committed size,
+// e.g. left by a crash in the middle of a Write, is ignored and overwritten by
+// subsequent writes. The files grow in chunks, and Compact trims them back.
+type MmapStore struct {
+	dir      string
+	hashSize int
+
+	mu     sync.RWMutex
+	size   uint64
+	levels []*mmapLevel
+}
+
+// mmapLevel is a memory-mapped file with the hashes of one tree level.
+type mmapLevel struct {
+	file  *os.File
+	data  []byte // The read-only mapping of the entire file.
+	count uint64 // The number of committed hashes.
+}
+
+// OpenMmapStore opens the MmapStore in the given directory, which is created
+// if it does not exist.
+func OpenMmapStore(dir string, hashSize int) (*MmapStore, error) {
+	if hashSize <= 0 {
+		return nil, fmt.Errorf("invalid hash size %d", hashSize)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	s := &MmapStore{dir: dir, hashSize: hashSize}
+	size, err := readSizeFile(filepath.Join(dir, mmapSizeFile))
+	if err != nil {
+		return nil, err
+	}
+	s.size = size
+	for level := uint(0); level < 64 && size>>level != 0; level++ {
+		l, err := s.level(level)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		l.count = size >> level
+		if need := l.count * uint64(hashSize); uint64(len(l.data)) < need {
+			s.Close()
+			return nil, fmt.Errorf("level %d file has %d bytes, want at least %d", level, len(l.data), need)
+		}
+	}
+	return s, nil
+}
+
+// Close unmaps and closes all the files.
+func (s *MmapStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for _, l := range s.levels {
+		if err := l.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	s.levels = nil
+	return firstErr
+}
+
+// Get returns the hashes of the given nodes. The returned hashes are copies,
+// and do not refer to the mapped memory.
+func (s *MmapStore) Get(ids []compact.NodeID) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hashes := make([][]byte, len(ids))
+	buf := make([]byte, len(ids)*s.hashSize)
+	for i, id := range ids {
+		if id.Level >= uint(len(s.levels)) || id.Index >= s.levels[id.Level].count {
+			return nil, fmt.Errorf("node %+v not found", id)
+		}
+		offset := id.Index * uint64(s.hashSize)
+		hashes[i] = buf[i*s.hashSize : (i+1)*s.hashSize : (i+1)*s.hashSize]
+		copy(hashes[i], s.levels[id.Level].data[offset:])
+	}
+	return hashes, nil
+}
+
+// Size returns the committed tree size.
+func (s *MmapStore) Size() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size, nil
+}
+
+// Write appends the given nodes to the level files, syncs them, and then
+// commits the new tree size.
+func (s *MmapStore) Write(size uint64, nodes []Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size < s.size {
+		return fmt.Errorf("tree size %d is smaller than the current %d", size, s.size)
+	}
+	// Check that the nodes extend every level exactly up to the new size.
+	next := make(map[uint]uint64)
+	for _, node := range nodes {
+		level := node.ID.Level
+		index, ok := next[level]
+		if !ok {
+			index = s.size >> level
+		}
+		if node.ID.Index != index {
+			return fmt.Errorf("node %+v is out of order, want index %d", node.ID, index)
+		}
+		if level >= 64 || index >= size>>level {
+			return fmt.Errorf("node %+v is not covered by tree size %d", node.ID, size)
+		}
+		if len(node.Hash) != s.hashSize {
+			return fmt.Errorf("node %+v has %d-byte hash, want %d", node.ID, len(node.Hash), s.hashSize)
+		}
+		next[level] = index + 1
+	}
+	for level := uint(0); level < 64 && size>>level != 0; level++ {
+		if want := size >> level; want != s.size>>level && next[level] != want {
+			return fmt.Errorf("level %d has %d nodes, want %d", level, next[level], want)
+		}
+	}
+
+	touched := make(map[uint]*mmapLevel)
+	for _, node := range nodes {
+		l, err := s.level(node.ID.Level)
+		if err != nil {
+			return err
+		}
+		if err := s.writeAt(l, node.ID.Index, node.Hash); err != nil {
+			return err
+		}
+		touched[node.ID.Level] = l
+	}
+	for _, l := range touched {
+		if err := l.file.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := writeSizeFile(s.dir, size); err != nil {
+		return err
+	}
+	s.size = size
+	for level, count := range next {
+		s.levels[level].count = count
+	}
+	return nil
+}
+
+// Compact truncates the level files to the committed tree size, releasing the
+// space reserved for growth and the data of uncommitted writes.
+func (s *MmapStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, l := range s.levels {
+		if err := l.resize(int64(l.count) * int64(s.hashSize)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// level returns the given level, opening its file if needed. Must be called
+// with the lock held.
+func (s *MmapStore) level(level uint) (*mmapLevel, error) {
+	for uint(len(s.levels)) <= level {
+		name := filepath.Join(s.dir, fmt.Sprintf("level-%02d", len(s.levels)))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		l := &mmapLevel{file: f}
+		if err := l.mmap(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.levels = append(s.levels, l)
+	}
+	return s.levels[level], nil
+}
+
+// writeAt writes the hash at the given index of the level, growing the file if
+// needed.
+func (s *MmapStore) writeAt(l *mmapLevel, index uint64, hash []byte) error {
+	offset := int64(index) * int64(s.hashSize)
+	if end := offset + int64(len(hash)); end > int64(len(l.data)) {
+		size := 2 * int64(len(l.data))
+		if size < mmapChunk {
+			size = mmapChunk
+		}
+		if size < end {
+			size = end
+		}
+		if err := l.resize(size); err != nil {
+			return err
+		}
+	}
+	_, err := l.file.WriteAt(hash, offset)
+	return err
+}
+
+// mmap maps the entire file into memory.
+func (l *mmapLevel) mmap() error {
+	fi, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == 0 {
+		l.data = nil
+		return nil
+	}
+	data, err := syscall.Mmap(int(l.file.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+	l.data = data
+	return nil
+}
+
+func (l *mmapLevel) munmap() error {
+	if l.data == nil {
+		return nil
+	}
+	data := l.data
+	l.data = nil
+	return syscall.Munmap(data)
+}
+
+// resize changes the file size, and remaps it.
+func (l *mmapLevel) resize(size int64) error {
+	if err := l.munmap(); err != nil {
+		return err
+	}
+	if err := l.file.Truncate(size); err != nil {
+		return err
+	}
+	return l.mmap()
+}
+
+func (l *mmapLevel) close() error {
+	err := l.munmap()
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readSizeFile returns the tree size stored in the given file, or 0 if the file
+// does not exist.
+func readSizeFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(data) != 12 || binary.BigEndian.Uint32(data[8:]) != crc32.ChecksumIEEE(data[:8]) {
+		return 0, fmt.Errorf("corrupt size file %s", path)
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// writeSizeFile atomically replaces the size file in the given directory.
+func writeSizeFile(dir string, size uint64) error {
+	var data [12]byte
+	binary.BigEndian.PutUint64(data[:], size)
+	binary.BigEndian.PutUint32(data[8:], crc32.ChecksumIEEE(data[:8]))
+
+	tmp := filepath.Join(dir, mmapSizeFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, mmapSizeFile)); err != nil {
+		return err
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,188 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin
+// +build linux darwin
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+func TestMmapStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenMmapStore(dir, 6)
+	if err != nil {
+		t.Fatalf("OpenMmapStore: %v", err)
+	}
+	defer s.Close()
+	batch := []Node{
+		{ID: id(0, 0), Hash: []byte("leaf:0")},
+		{ID: id(0, 1), Hash: []byte("leaf:1")},
+		{ID: id(1, 0), Hash: []byte("node:1")},
+	}
+	if err := s.Write(2, batch); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if size, err := s.Size(); err != nil || size != 2 {
+		t.Fatalf("Size: %d, %v; want 2", size, err)
+	}
+	hashes, err := s.Get([]compact.NodeID{id(1, 0), id(0, 0)})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if diff := cmp.Diff(hashes, [][]byte{batch[2].Hash, batch[0].Hash}); diff != "" {
+		t.Errorf("Get: diff(-got +want):\n%s", diff)
+	}
+	if _, err := s.Get([]compact.NodeID{id(0, 0), id(0, 2)}); err == nil {
+		t.Error("Get: want error for missing node")
+	}
+
+	for _, tc := range []struct {
+		desc  string
+		size  uint64
+		nodes []Node
+	}{
+		{desc: "smaller size", size: 1},
+		{desc: "missing nodes", size: 3},
+		{desc: "out of order", size: 3, nodes: []Node{{ID: id(0, 3), Hash: []byte("leaf:3")}}},
+		{desc: "rewrite", size: 3, nodes: []Node{{ID: id(0, 1), Hash: []byte("leaf:1")}}},
+		{desc: "wrong hash size", size: 3, nodes: []Node{{ID: id(0, 2), Hash: []byte("leaf 2")[:5]}}},
+		{desc: "beyond size", size: 3, nodes: []Node{
+			{ID: id(0, 2), Hash: []byte("leaf:2")},
+			{ID: id(1, 1), Hash: []byte("node:1")},
+		}},
+		{desc: "above root", size: 3, nodes: []Node{
+			{ID: id(0, 2), Hash: []byte("leaf:2")},
+			{ID: id(2, 0), Hash: []byte("node:2")},
+		}},
+		{desc: "level 64", size: 3, nodes: []Node{
+			{ID: id(0, 2), Hash: []byte("leaf:2")},
+			{ID: id(64, 0), Hash: []byte("node:2")},
+		}},
+		{desc: "incomplete level", size: 4, nodes: []Node{
+			{ID: id(0, 2), Hash: []byte("leaf:2")},
+			{ID: id(0, 3), Hash: []byte("leaf:3")},
+		}},
+	} {
+		if err := s.Write(tc.size, tc.nodes); err == nil {
+			t.Errorf("Write(%s): want error", tc.desc)
+		}
+	}
+	if size, _ := s.Size(); size != 2 {
+		t.Errorf("Size: got %d, want 2", size)
+	}
+	if err := s.Write(2, nil); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+}
+
+func TestMmapStoreTree(t *testing.T) {
+	const size = 30
+	leaves := testonly.GenLeaves(0, 0, size)
+	ref := testonly.GenTree(hasher, 0, size)
+
+	dir := t.TempDir()
+	store, err := OpenMmapStore(dir, hasher.Size())
+	if err != nil {
+		t.Fatalf("OpenMmapStore: %v", err)
+	}
+	tree, err := NewTree(hasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	for _, batch := range [][2]int{{0, 0}, {0, 1}, {1, 7}, {7, 8}, {8, 20}} {
+		if err := tree.AppendData(leaves[batch[0]:batch[1]]...); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+		checkTree(t, tree, ref)
+	}
+	if err := store.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "level-00"))
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if got, want := fi.Size(), int64(20*hasher.Size()); got != want {
+		t.Errorf("Compact: got file size %d, want %d", got, want)
+	}
+	checkTree(t, tree, ref)
+
+	// Simulate a crash after the level files were written, but before the tree
+	// size was committed.
+	if err := writeSizeFile(dir, 17); err != nil {
+		t.Fatalf("writeSizeFile: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if store, err = OpenMmapStore(dir, hasher.Size()); err != nil {
+		t.Fatalf("OpenMmapStore: %v", err)
+	}
+	defer store.Close()
+	if tree, err = NewTree(hasher, store); err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	if got, want := tree.Size(), uint64(17); got != want {
+		t.Fatalf("Size: got %d, want %d", got, want)
+	}
+	if err := tree.AppendData(leaves[17:]...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	checkTree(t, tree, ref)
+}
+
+func TestMmapStoreCorruption(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := OpenMmapStore(dir, 0); err == nil {
+		t.Error("OpenMmapStore: want error for zero hash size")
+	}
+
+	s, err := OpenMmapStore(dir, 4)
+	if err != nil {
+		t.Fatalf("OpenMmapStore: %v", err)
+	}
+	if err := s.Write(1, []Node{{ID: id(0, 0), Hash: []byte("leaf")}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The level file is shorter than the committed tree size.
+	if err := writeSizeFile(dir, 2); err != nil {
+		t.Fatalf("writeSizeFile: %v", err)
+	}
+	if _, err := OpenMmapStore(dir, 4); err == nil {
+		t.Error("OpenMmapStore: want error for truncated level file")
+	}
+	// The size file is corrupt.
+	if err := os.WriteFile(filepath.Join(dir, mmapSizeFile), []byte("garbage"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := OpenMmapStore(dir, 4); err == nil {
+		t.Error("OpenMmapStore: want error for corrupt size file")
+	}
+}
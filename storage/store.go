@@ -0,0 +1,97 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package storage provides persistence of Merkle tree nodes, and a tree which
+// builds proofs from the stored nodes.
+package storage
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// Node is a Merkle tree node with its hash.
+type Node struct {
+	ID   compact.NodeID
+	Hash []byte
+}
+
+// NodeStore stores the hashes of the perfect Merkle tree nodes, and the tree
+// size up to which the nodes are stored. Implementations must be safe for
+// concurrent use.
+type NodeStore interface {
+	// Get returns the hashes of the given nodes, in the same order. Returns an
+	// error if any of the nodes is missing.
+	Get(ids []compact.NodeID) ([][]byte, error)
+	// Size returns the current tree size.
+	Size() (uint64, error)
+	// Write atomically stores the given nodes, and sets the tree size to the
+	// given one, which must not be smaller than the current size. Either all
+	// the changes are persisted, or none of them.
+	Write(size uint64, nodes []Node) error
+}
+
+// MemoryStore is an in-memory NodeStore.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	size  uint64
+	nodes map[compact.NodeID][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nodes: make(map[compact.NodeID][]byte)}
+}
+
+// Get returns the hashes of the given nodes.
+func (s *MemoryStore) Get(ids []compact.NodeID) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hashes := make([][]byte, len(ids))
+	for i, id := range ids {
+		hash, ok := s.nodes[id]
+		if !ok {
+			return nil, fmt.Errorf("node %+v not found", id)
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// Size returns the current tree size.
+func (s *MemoryStore) Size() (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.size, nil
+}
+
+// Write stores the given nodes, and sets the tree size.
+func (s *MemoryStore) Write(size uint64, nodes []Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size < s.size {
+		return fmt.Errorf("tree size %d is smaller than the current %d", size, s.size)
+	}
+	s.write(size, nodes)
+	return nil
+}
+
+func (s *MemoryStore) write(size uint64, nodes []Node) {
+	for _, node := range nodes {
+		s.nodes[node.ID] = node.Hash
+	}
+	s.size = size
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/compact"
+)
+
+var (
+	id    = compact.NewNodeID
+	batch = []Node{
+		{ID: id(0, 0), Hash: []byte("leaf 0")},
+		{ID: id(0, 1), Hash: []byte("leaf 1")},
+		{ID: id(1, 0), Hash: []byte("node 1:0")},
+	}
+)
+
+// testStore runs the tests which every NodeStore implementation must pass.
+func testStore(t *testing.T, s NodeStore) {
+	t.Helper()
+	if size, err := s.Size(); err != nil || size != 0 {
+		t.Fatalf("Size: %d, %v; want 0", size, err)
+	}
+	if err := s.Write(2, batch); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if size, err := s.Size(); err != nil || size != 2 {
+		t.Fatalf("Size: %d, %v; want 2", size, err)
+	}
+	hashes, err := s.Get([]compact.NodeID{id(1, 0), id(0, 0)})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if diff := cmp.Diff(hashes, [][]byte{batch[2].Hash, batch[0].Hash}); diff != "" {
+		t.Errorf("Get: diff(-got +want):\n%s", diff)
+	}
+	if _, err := s.Get([]compact.NodeID{id(0, 0), id(0, 2)}); err == nil {
+		t.Error("Get: want error for missing node")
+	}
+	if err := s.Write(1, nil); err == nil {
+		t.Error("Write: want error for smaller size")
+	}
+	if err := s.Write(2, nil); err != nil {
+		t.Errorf("Write: %v", err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes")
+	s, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	testStore(t, s)
+	if err := s.Write(3, []Node{{ID: id(0, 2), Hash: []byte("leaf 2")}}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopen the store, and check that it has the same contents.
+	s, err = OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	defer s.Close()
+	if size, err := s.Size(); err != nil || size != 3 {
+		t.Fatalf("Size: %d, %v; want 3", size, err)
+	}
+	hashes, err := s.Get([]compact.NodeID{id(0, 0), id(0, 1), id(1, 0), id(0, 2)})
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if diff := cmp.Diff(hashes, [][]byte{batch[0].Hash, batch[1].Hash, batch[2].Hash, []byte("leaf 2")}); diff != "" {
+		t.Errorf("Get: diff(-got +want):\n%s", diff)
+	}
+}
+
+func TestFileStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	rec1, err := encodeRecord(2, batch)
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	rec2, err := encodeRecord(3, []Node{{ID: id(0, 2), Hash: []byte("leaf 2")}})
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	corrupt := append([]byte(nil), rec2...)
+	corrupt[len(corrupt)-5] ^= 1
+
+	for _, tc := range []struct {
+		desc string
+		data []byte
+	}{
+		{desc: "truncated-header", data: append(append([]byte(nil), rec1...), rec2[:5]...)},
+		{desc: "truncated-node", data: append(append([]byte(nil), rec1...), rec2[:len(rec2)-6]...)},
+		{desc: "truncated-checksum", data: append(append([]byte(nil), rec1...), rec2[:len(rec2)-1]...)},
+		{desc: "corrupt", data: append(append([]byte(nil), rec1...), corrupt...)},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(dir, tc.desc)
+			if err := os.WriteFile(path, tc.data, 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+			s, err := OpenFileStore(path)
+			if err != nil {
+				t.Fatalf("OpenFileStore: %v", err)
+			}
+			defer s.Close()
+			if size, err := s.Size(); err != nil || size != 2 {
+				t.Fatalf("Size: %d, %v; want 2", size, err)
+			}
+			if _, err := s.Get([]compact.NodeID{id(0, 2)}); err == nil {
+				t.Error("Get: want error for node from the discarded record")
+			}
+
+			// The store must keep working after recovery.
+			if err := s.Write(3, []Node{{ID: id(0, 2), Hash: []byte("leaf 2")}}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("ReadFile: %v", err)
+			}
+			if diff := cmp.Diff(data, append(append([]byte(nil), rec1...), rec2...)); diff != "" {
+				t.Errorf("file contents: diff(-got +want):\n%s", diff)
+			}
+		})
+	}
+
+	// A corrupt record followed by valid ones is not discarded with them.
+	t.Run("corrupt-middle", func(t *testing.T) {
+		path := filepath.Join(dir, "corrupt-middle")
+		want := append(append(append([]byte(nil), rec1...), corrupt...), rec1...)
+		if err := os.WriteFile(path, want, 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if s, err := OpenFileStore(path); err == nil {
+			s.Close()
+			t.Fatal("OpenFileStore: want error for corrupt record")
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if diff := cmp.Diff(data, want); diff != "" {
+			t.Errorf("file contents: diff(-got +want):\n%s", diff)
+		}
+	})
+}
+
+// faultyFile is a file which fails the next Write after writing the given
+// number of bytes, or fails the next Sync.
+type faultyFile struct {
+	file
+	failWrite int // Fail the next Write after this many bytes, if >= 0.
+	failSync  bool
+}
+
+func (f *faultyFile) Write(data []byte) (int, error) {
+	if n := f.failWrite; n >= 0 && n < len(data) {
+		f.failWrite = -1
+		written, _ := f.file.Write(data[:n])
+		return written, errors.New("write failed")
+	}
+	return f.file.Write(data)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.failSync {
+		f.failSync = false
+		return errors.New("sync failed")
+	}
+	return f.file.Sync()
+}
+
+func TestFileStoreWriteFailure(t *testing.T) {
+	leaf2 := []Node{{ID: id(0, 2), Hash: []byte("leaf 2")}}
+	leaf3 := []Node{{ID: id(0, 3), Hash: []byte("leaf 3")}, {ID: id(1, 1), Hash: []byte("node 1:1")}}
+	for _, tc := range []struct {
+		desc      string
+		failWrite int
+		failSync  bool
+		wantSize  uint64 // The size after reopening.
+	}{
+		{desc: "torn-write", failWrite: 7, wantSize: 4},
+		{desc: "empty-write", failWrite: 0, wantSize: 4},
+		{desc: "sync", failWrite: -1, failSync: true, wantSize: 2},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nodes")
+			s, err := OpenFileStore(path)
+			if err != nil {
+				t.Fatalf("OpenFileStore: %v", err)
+			}
+			if err := s.Write(2, batch); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			f := &faultyFile{file: s.file, failWrite: tc.failWrite, failSync: tc.failSync}
+			s.file = f
+			if err := s.Write(3, leaf2); err == nil {
+				t.Fatal("Write: want error")
+			}
+			if size, err := s.Size(); err != nil || size != 2 {
+				t.Fatalf("Size: %d, %v; want 2", size, err)
+			}
+			// The store either accepts the next write, or is broken by a failed sync.
+			err = s.Write(4, append(leaf2, leaf3...))
+			if got, want := err != nil, tc.failSync; got != want {
+				t.Fatalf("Write: %v, want error %v", err, want)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			// No acknowledged writes are lost after reopening.
+			s, err = OpenFileStore(path)
+			if err != nil {
+				t.Fatalf("OpenFileStore: %v", err)
+			}
+			defer s.Close()
+			if size, err := s.Size(); err != nil || size != tc.wantSize {
+				t.Fatalf("Size: %d, %v; want %d", size, err, tc.wantSize)
+			}
+			if tc.wantSize == 4 {
+				if _, err := s.Get([]compact.NodeID{id(0, 2), id(1, 1)}); err != nil {
+					t.Errorf("Get: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+// Tree is an append-only Merkle tree backed by a NodeStore. It is not safe for
+// concurrent use.
+type Tree struct {
+	hasher merkle.LogHasher
+	store  NodeStore
+	rf     *compact.RangeFactory
+	rng    *compact.Range // The compact range [0, size) of the stored tree.
+}
+
+// NewTree returns a Tree which uses the nodes in the given store.
+func NewTree(hasher merkle.LogHasher, store NodeStore) (*Tree, error) {
+	size, err := store.Size()
+	if err != nil {
+		return nil, err
+	}
+	hashes, err := store.Get(compact.RangeNodes(0, size, nil))
+	if err != nil {
+		return nil, err
+	}
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	rng, err := rf.NewRange(0, size, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{hasher: hasher, store: store, rf: rf, rng: rng}, nil
+}
+
+// Size returns the current number of leaves in the tree.
+func (t *Tree) Size() uint64 {
+	return t.rng.End()
+}
+
+// AppendData appends the leaves with the given data to the tree.
+func (t *Tree) AppendData(entries ...[]byte) error {
+	hashes := make([][]byte, len(entries))
+	for i, data := range entries {
+		hashes[i] = t.hasher.HashLeaf(data)
+	}
+	return t.Append(hashes...)
+}
+
+// Append appends the leaves with the given hashes to the tree. All the new
+// nodes are written to the store in one batch.
+func (t *Tree) Append(hashes ...[]byte) error {
+	rng := t.rng.Clone()
+	var nodes []Node
+	visit := func(id compact.NodeID, hash []byte) {
+		nodes = append(nodes, Node{ID: id, Hash: hash})
+	}
+	for _, hash := range hashes {
+		if err := rng.Append(hash, visit); err != nil {
+			return err
+		}
+	}
+	if err := t.store.Write(rng.End(), nodes); err != nil {
+		return err
+	}
+	t.rng = rng
+	return nil
+}
+
+// Hash returns the current root hash of the tree.
+func (t *Tree) Hash() ([]byte, error) {
+	return t.HashAt(t.Size())
+}
+
+// HashAt returns the root hash of the tree of the given size.
+func (t *Tree) HashAt(size uint64) ([]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("size %d exceeds the tree size %d", size, t.Size())
+	} else if size == 0 {
+		return t.hasher.EmptyRoot(), nil
+	}
+	hashes, err := t.store.Get(compact.RangeNodes(0, size, nil))
+	if err != nil {
+		return nil, err
+	}
+	rng, err := t.rf.NewRange(0, size, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return rng.GetRootHash(nil)
+}
+
+// InclusionProof returns the inclusion proof for the given leaf index in the
+// tree of the given size.
+func (t *Tree) InclusionProof(index, size uint64) ([][]byte, error) {
+	if size > t.Size() {
+		return nil, fmt.Errorf("size %d exceeds the tree size %d", size, t.Size())
+	}
+	nodes, err := proof.Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+	return t.rehash(nodes)
+}
+
+// ConsistencyProof returns the consistency proof between the two given tree
+// sizes.
+func (t *Tree) ConsistencyProof(size1, size2 uint64) ([][]byte, error) {
+	if size2 > t.Size() {
+		return nil, fmt.Errorf("size %d exceeds the tree size %d", size2, t.Size())
+	}
+	nodes, err := proof.Consistency(size1, size2)
+	if err != nil {
+		return nil, err
+	}
+	return t.rehash(nodes)
+}
+
+func (t *Tree) rehash(nodes proof.Nodes) ([][]byte, error) {
+	hashes, err := t.store.Get(nodes.IDs)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Rehash(hashes, t.hasher.HashChildren)
+}
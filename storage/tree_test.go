@@ -0,0 +1,148 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+// checkTree checks that the tree has the same roots and proofs as the
+// reference tree, up to the tree size.
+func checkTree(t *testing.T, tree *Tree, ref *testonly.Tree) {
+	t.Helper()
+	size := tree.Size()
+	for size2 := uint64(0); size2 <= size; size2++ {
+		root, err := tree.HashAt(size2)
+		if err != nil {
+			t.Fatalf("HashAt(%d): %v", size2, err)
+		}
+		if diff := cmp.Diff(root, ref.HashAt(size2)); diff != "" {
+			t.Fatalf("HashAt(%d): diff(-got +want):\n%s", size2, diff)
+		}
+		for index := uint64(0); index < size2; index++ {
+			got, err := tree.InclusionProof(index, size2)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d): %v", index, size2, err)
+			}
+			want, _ := ref.InclusionProof(index, size2)
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Fatalf("InclusionProof(%d, %d): diff(-got +want):\n%s", index, size2, diff)
+			}
+		}
+		for size1 := uint64(0); size1 <= size2; size1++ {
+			got, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", size1, size2, err)
+			}
+			want, _ := ref.ConsistencyProof(size1, size2)
+			if diff := cmp.Diff(got, want); diff != "" {
+				t.Fatalf("ConsistencyProof(%d, %d): diff(-got +want):\n%s", size1, size2, diff)
+			}
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	const size = 30
+	leaves := testonly.GenLeaves(0, 0, size)
+	ref := testonly.GenTree(hasher, 0, size)
+
+	path := filepath.Join(t.TempDir(), "nodes")
+	store, err := OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	tree, err := NewTree(hasher, store)
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	for _, batch := range [][2]int{{0, 0}, {0, 1}, {1, 7}, {7, 8}, {8, 20}} {
+		if err := tree.AppendData(leaves[batch[0]:batch[1]]...); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+		checkTree(t, tree, ref)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Reopen the tree, and continue appending.
+	if store, err = OpenFileStore(path); err != nil {
+		t.Fatalf("OpenFileStore: %v", err)
+	}
+	defer store.Close()
+	if tree, err = NewTree(hasher, store); err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	if got, want := tree.Size(), uint64(20); got != want {
+		t.Fatalf("Size: got %d, want %d", got, want)
+	}
+	if err := tree.AppendData(leaves[20:]...); err != nil {
+		t.Fatalf("AppendData: %v", err)
+	}
+	checkTree(t, tree, ref)
+
+	if _, err := tree.HashAt(size + 1); err == nil {
+		t.Error("HashAt: want error for size beyond the tree")
+	}
+	if _, err := tree.InclusionProof(0, size+1); err == nil {
+		t.Error("InclusionProof: want error for size beyond the tree")
+	}
+	if _, err := tree.ConsistencyProof(1, size+1); err == nil {
+		t.Error("ConsistencyProof: want error for size beyond the tree")
+	}
+}
+
+// failingStore is a NodeStore which fails all writes.
+type failingStore struct {
+	*MemoryStore
+}
+
+func (failingStore) Write(uint64, []Node) error {
+	return errors.New("write failed")
+}
+
+func TestTreeAppendFailure(t *testing.T) {
+	tree, err := NewTree(hasher, failingStore{NewMemoryStore()})
+	if err != nil {
+		t.Fatalf("NewTree: %v", err)
+	}
+	if err := tree.AppendData([]byte("data")); err == nil {
+		t.Fatal("AppendData: want error")
+	}
+	if got := tree.Size(); got != 0 {
+		t.Errorf("Size: got %d, want 0", got)
+	}
+	if _, err := NewTree(hasher, brokenStore{NewMemoryStore()}); err == nil {
+		t.Error("NewTree: want error for missing nodes")
+	}
+}
+
+// brokenStore is a NodeStore which claims a non-zero size without any nodes.
+type brokenStore struct {
+	*MemoryStore
+}
+
+func (brokenStore) Size() (uint64, error) {
+	return 5, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package stream computes Merkle trees over streams of leaves in bounded
+// memory.
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// MaxLeafSize is the maximum size of a leaf read by Builder.ReadLeaves.
+const MaxLeafSize = 64 << 20
+
+// Builder builds a Merkle tree from leaves appended one by one. It only keeps
+// the compact range of the tree, i.e. O(log n) hashes for n leaves. All the
+// perfect nodes of the tree, including the leaves, are passed to the visitor
+// as soon as they are computed, e.g. for writing them to storage.
+type Builder struct {
+	hasher merkle.LogHasher
+	rng    *compact.Range
+	visit  compact.VisitFn
+}
+
+// NewBuilder returns a Builder of an empty tree. The visitor can be nil.
+func NewBuilder(hasher merkle.LogHasher, visit compact.VisitFn) *Builder {
+	rf := &compact.RangeFactory{Hash: hasher.HashChildren}
+	return &Builder{hasher: hasher, rng: rf.NewEmptyRange(0), visit: visit}
+}
+
+// Size returns the number of leaves in the tree.
+func (b *Builder) Size() uint64 {
+	return b.rng.End()
+}
+
+// Append adds the leaf with the given hash to the tree.
+func (b *Builder) Append(leafHash []byte) error {
+	return b.rng.Append(leafHash, b.visit)
+}
+
+// AppendData adds the leaf with the given data to the tree.
+func (b *Builder) AppendData(data []byte) error {
+	return b.Append(b.hasher.HashLeaf(data))
+}
+
+// ReadLeaves reads leaves from the given reader until EOF, and adds them to the
+// tree. The leaves are split using the given function, such as bufio.ScanLines
+// for newline-delimited leaves, or ScanLen32 for length-prefixed ones. Leaves
+// must not exceed MaxLeafSize bytes. Returns the number of added leaves.
+func (b *Builder) ReadLeaves(r io.Reader, split bufio.SplitFunc) (uint64, error) {
+	begin := b.Size()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, MaxLeafSize)
+	scanner.Split(split)
+	for scanner.Scan() {
+		if err := b.AppendData(scanner.Bytes()); err != nil {
+			return b.Size() - begin, err
+		}
+	}
+	return b.Size() - begin, scanner.Err()
+}
+
+// Root returns the root hash of the tree.
+func (b *Builder) Root() ([]byte, error) {
+	if b.Size() == 0 {
+		return b.hasher.EmptyRoot(), nil
+	}
+	return b.rng.GetRootHash(nil)
+}
+
+// Root returns the size and the root hash of the tree with the leaves read from
+// the given reader. See Builder.ReadLeaves for the arguments.
+func Root(hasher merkle.LogHasher, r io.Reader, split bufio.SplitFunc) (uint64, []byte, error) {
+	b := NewBuilder(hasher, nil)
+	if _, err := b.ReadLeaves(r, split); err != nil {
+		return 0, nil, err
+	}
+	root, err := b.Root()
+	if err != nil {
+		return 0, nil, err
+	}
+	return b.Size(), root, nil
+}
+
+// ScanLen32 is a bufio.SplitFunc which splits the input into leaves prefixed
+// with a 4-byte big-endian length. Returns io.ErrUnexpectedEOF if the input
+// ends in the middle of a leaf.
+func ScanLen32(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if len(data) >= 4 {
+		if end := 4 + uint64(binary.BigEndian.Uint32(data)); uint64(len(data)) >= end {
+			return int(end), data[4:end], nil
+		}
+	}
+	if atEOF {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return 0, nil, nil // Request more data.
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func TestRoot(t *testing.T) {
+	for _, size := range []int{0, 1, 2, 7, 8, 100} {
+		t.Run(fmt.Sprintf("%d", size), func(t *testing.T) {
+			ref := testonly.New(hasher)
+			var lines strings.Builder
+			var len32 bytes.Buffer
+			for i := 0; i < size; i++ {
+				leaf := fmt.Sprintf("leaf %d", i)
+				ref.AppendData([]byte(leaf))
+				lines.WriteString(leaf + "\n")
+				var l [4]byte
+				binary.BigEndian.PutUint32(l[:], uint32(len(leaf)))
+				len32.Write(l[:])
+				len32.WriteString(leaf)
+			}
+			for _, tc := range []struct {
+				desc  string
+				r     io.Reader
+				split bufio.SplitFunc
+			}{
+				{desc: "lines", r: strings.NewReader(lines.String()), split: bufio.ScanLines},
+				{desc: "len32", r: &len32, split: ScanLen32},
+			} {
+				gotSize, root, err := Root(hasher, tc.r, tc.split)
+				if err != nil {
+					t.Fatalf("%s: Root: %v", tc.desc, err)
+				}
+				if gotSize != uint64(size) {
+					t.Errorf("%s: Root: got size %d, want %d", tc.desc, gotSize, size)
+				}
+				if want := ref.Hash(); !bytes.Equal(root, want) {
+					t.Errorf("%s: Root: got %x, want %x", tc.desc, root, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilderVisitor(t *testing.T) {
+	const size = 21
+	nodes := make(map[compact.NodeID][]byte)
+	b := NewBuilder(hasher, func(id compact.NodeID, hash []byte) {
+		nodes[id] = hash
+	})
+	ref := testonly.New(hasher)
+	for i := 0; i < size; i++ {
+		data := []byte{byte(i)}
+		if err := b.AppendData(data); err != nil {
+			t.Fatalf("AppendData: %v", err)
+		}
+		ref.AppendData(data)
+	}
+	// All the perfect nodes are visited.
+	if got, want := len(nodes), 2*size-3; got != want { // 21 = 16 + 4 + 1.
+		t.Errorf("got %d nodes, want %d", got, want)
+	}
+	for index := uint64(0); index < size; index++ {
+		if got, want := nodes[compact.NewNodeID(0, index)], ref.LeafHash(index); !bytes.Equal(got, want) {
+			t.Errorf("leaf %d: got %x, want %x", index, got, want)
+		}
+	}
+	if got, want := nodes[compact.NewNodeID(4, 0)], ref.HashAt(16); !bytes.Equal(got, want) {
+		t.Errorf("node 4:0: got %x, want %x", got, want)
+	}
+}
+
+func TestScanLen32Errors(t *testing.T) {
+	for _, input := range []string{"\x00", "\x00\x00\x00\x02a", "\x00\x00\x00\x00\x00\x00"} {
+		if _, _, err := Root(hasher, strings.NewReader(input), ScanLen32); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("Root(%q): got %v, want %v", input, err, io.ErrUnexpectedEOF)
+		}
+	}
+	// Empty leaves are allowed.
+	if size, _, err := Root(hasher, strings.NewReader("\x00\x00\x00\x00\x00\x00\x00\x00"), ScanLen32); err != nil || size != 2 {
+		t.Errorf("Root: got size %d, err %v; want 2, nil", size, err)
+	}
+}
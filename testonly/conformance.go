@@ -0,0 +1,173 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"bytes"
+	_ "embed" // For the golden vectors.
+	"encoding/json"
+	"fmt"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+//go:embed testdata/rfc6962_vectors.json
+var rfc6962Vectors []byte
+
+// Profile describes the expected behaviour of a LogHasher implementation, such
+// as the RFC 6962 tree hashing with SHA-256.
+type Profile struct {
+	// Name is the human-readable name of the profile, used in error messages.
+	Name string
+	// EmptyRoot is the expected root hash of the empty tree. Not checked if nil.
+	EmptyRoot []byte
+	// Vectors are the golden vectors which the hasher must reproduce, e.g.
+	// produced by GenVectors with a reference implementation. Not checked if
+	// nil.
+	Vectors *Vectors
+}
+
+// RFC6962Profile returns the Profile of the RFC 6962 tree hashing with
+// SHA-256, e.g. rfc6962.DefaultHasher.
+func RFC6962Profile() Profile {
+	var v Vectors
+	if err := json.Unmarshal(rfc6962Vectors, &v); err != nil {
+		panic(fmt.Sprintf("bad embedded vectors: %v", err))
+	}
+	return Profile{Name: "RFC6962-SHA256", EmptyRoot: EmptyRootHash(), Vectors: &v}
+}
+
+// CheckLogHasher checks that the LogHasher implementation has the properties
+// required by this module, and conforms to the given profile. Returns all the
+// found violations, or nil if there are none. This allows the authors of
+// custom hashers to check them in their tests before deployment.
+//
+// The required properties are:
+//   - All hashes have the size returned by hasher.Size().
+//   - Hashing is deterministic, and does not modify or retain the inputs.
+//   - Leaf hashes are separated from node hashes, and from the empty root hash,
+//     i.e. a leaf can not be disguised as a node, or an empty tree.
+//   - The order of children matters for node hashes.
+func CheckLogHasher(hasher merkle.LogHasher, p Profile) []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", p.Name, fmt.Sprintf(format, args...)))
+	}
+
+	size := hasher.Size()
+	if size <= 0 {
+		fail("Size() = %d, want > 0", size)
+		return errs
+	}
+	leaves := GenLeaves(0, 0, 4)
+	left, right := hasher.HashLeaf(leaves[0]), hasher.HashLeaf(leaves[1])
+	node := hasher.HashChildren(left, right)
+	empty := hasher.EmptyRoot()
+	for _, h := range []struct {
+		name string
+		hash []byte
+	}{
+		{name: "EmptyRoot", hash: empty},
+		{name: "HashLeaf", hash: left},
+		{name: "HashChildren", hash: node},
+	} {
+		if got := len(h.hash); got != size {
+			fail("%s returned %d bytes, want %d", h.name, got, size)
+		}
+	}
+
+	// Determinism, and no aliasing between the inputs and the outputs.
+	saved := append([]byte(nil), node...)
+	leafCopy := append([]byte(nil), leaves[0]...)
+	if !bytes.Equal(hasher.HashLeaf(leaves[0]), left) {
+		fail("HashLeaf is not deterministic")
+	}
+	if !bytes.Equal(leaves[0], leafCopy) {
+		fail("HashLeaf modified its input")
+	}
+	if !bytes.Equal(hasher.HashChildren(left, right), node) {
+		fail("HashChildren is not deterministic")
+	}
+	if !bytes.Equal(hasher.EmptyRoot(), empty) {
+		fail("EmptyRoot is not deterministic")
+	}
+	hasher.HashChildren(node, node)
+	hasher.HashLeaf(leaves[2])
+	if !bytes.Equal(node, saved) {
+		fail("HashChildren result was modified by subsequent calls")
+	}
+
+	// Domain separation.
+	if bytes.Equal(hasher.HashLeaf(append(append([]byte(nil), left...), right...)), node) {
+		fail("HashLeaf(l || r) = HashChildren(l, r)")
+	}
+	if bytes.Equal(hasher.HashLeaf(nil), empty) {
+		fail("HashLeaf(nil) = EmptyRoot()")
+	}
+	if bytes.Equal(hasher.HashChildren(right, left), node) {
+		fail("HashChildren(r, l) = HashChildren(l, r)")
+	}
+	if bytes.Equal(left, right) {
+		fail("HashLeaf returned the same hash for different leaves")
+	}
+
+	if p.EmptyRoot != nil && !bytes.Equal(empty, p.EmptyRoot) {
+		fail("EmptyRoot() = %x, want %x", empty, p.EmptyRoot)
+	}
+	if p.Vectors != nil {
+		for _, err := range checkVectors(hasher, p.Vectors) {
+			fail("%v", err)
+		}
+	}
+	return errs
+}
+
+// checkVectors checks that the hasher reproduces the given test vectors.
+func checkVectors(hasher merkle.LogHasher, v *Vectors) []error {
+	var errs []error
+	if got, want := len(v.Roots), len(v.Leaves)+1; got != want {
+		return append(errs, fmt.Errorf("vectors: got %d roots, want %d", got, want))
+	}
+	tree := New(hasher)
+	for size, root := range v.Roots {
+		if size > 0 {
+			tree.AppendData(v.Leaves[size-1])
+		}
+		if got := tree.Hash(); !bytes.Equal(got, root) {
+			errs = append(errs, fmt.Errorf("root of size %d: got %x, want %x", size, got, root))
+		}
+	}
+	for _, p := range v.Inclusion {
+		if p.Index >= p.Size || p.Size > tree.Size() {
+			errs = append(errs, fmt.Errorf("bad inclusion vector (%d, %d)", p.Index, p.Size))
+			continue
+		}
+		leafHash := hasher.HashLeaf(v.Leaves[p.Index])
+		if err := proof.VerifyInclusion(hasher, p.Index, p.Size, leafHash, p.Proof, v.Roots[p.Size]); err != nil {
+			errs = append(errs, fmt.Errorf("inclusion proof (%d, %d): %v", p.Index, p.Size, err))
+		}
+	}
+	for _, p := range v.Consistency {
+		if p.Size1 > p.Size2 || p.Size2 > tree.Size() {
+			errs = append(errs, fmt.Errorf("bad consistency vector (%d, %d)", p.Size1, p.Size2))
+			continue
+		}
+		if err := proof.VerifyConsistency(hasher, p.Size1, p.Size2, p.Proof, v.Roots[p.Size1], v.Roots[p.Size2]); err != nil {
+			errs = append(errs, fmt.Errorf("consistency proof (%d, %d): %v", p.Size1, p.Size2, err))
+		}
+	}
+	return errs
+}
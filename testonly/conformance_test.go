@@ -0,0 +1,87 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+// sha256Hasher is a LogHasher with no domain separation between leaves and
+// nodes, which makes second preimage attacks possible.
+type sha256Hasher struct{}
+
+func (sha256Hasher) EmptyRoot() []byte { return sha256Sum(nil) }
+func (sha256Hasher) HashLeaf(leaf []byte) []byte {
+	return sha256Sum(leaf)
+}
+func (sha256Hasher) HashChildren(l, r []byte) []byte {
+	return sha256Sum(append(append([]byte(nil), l...), r...))
+}
+func (sha256Hasher) Size() int { return sha256.Size }
+
+func sha256Sum(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func TestCheckLogHasher(t *testing.T) {
+	for _, tc := range []struct {
+		desc    string
+		hasher  merkle.LogHasher
+		profile Profile
+		wantErr []string
+	}{
+		{desc: "rfc6962", hasher: rfc6962.DefaultHasher, profile: RFC6962Profile()},
+		{desc: "hmac", hasher: rfc6962.NewHMAC(crypto.SHA256, []byte("key")), profile: Profile{Name: "HMAC"}},
+		{
+			desc:    "hmac-as-rfc6962",
+			hasher:  rfc6962.NewHMAC(crypto.SHA256, []byte("key")),
+			profile: RFC6962Profile(),
+			wantErr: []string{"EmptyRoot() =", "root of size 1:", "inclusion proof (0, 1):"},
+		},
+		{
+			desc:    "no-domain-separation",
+			hasher:  sha256Hasher{},
+			profile: Profile{Name: "SHA256"},
+			wantErr: []string{"HashLeaf(l || r) = HashChildren(l, r)", "HashLeaf(nil) = EmptyRoot()"},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			errs := CheckLogHasher(tc.hasher, tc.profile)
+			if tc.wantErr == nil {
+				for _, err := range errs {
+					t.Errorf("CheckLogHasher: %v", err)
+				}
+				return
+			}
+			var msgs []string
+			for _, err := range errs {
+				msgs = append(msgs, err.Error())
+			}
+			all := strings.Join(msgs, "\n")
+			for _, want := range tc.wantErr {
+				if !strings.Contains(all, want) {
+					t.Errorf("CheckLogHasher: no error containing %q in:\n%s", want, all)
+				}
+			}
+		})
+	}
+}
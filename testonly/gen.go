@@ -0,0 +1,51 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/transparency-dev/merkle"
+)
+
+// GenLeaf returns the pseudo-random data of the leaf with the given index. The
+// data is derived deterministically from the seed and the index, and is stable
+// across versions of this package, so it can be used in golden tests. Its
+// length varies from 0 to 32 bytes.
+func GenLeaf(seed, index uint64) []byte {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], seed)
+	binary.BigEndian.PutUint64(buf[8:], index)
+	hash := sha256.Sum256(buf[:])
+	return hash[:hash[0]%(sha256.Size+1)]
+}
+
+// GenLeaves returns the data of leaves [begin, end) generated by GenLeaf.
+func GenLeaves(seed, begin, end uint64) [][]byte {
+	leaves := make([][]byte, 0, end-begin)
+	for i := begin; i < end; i++ {
+		leaves = append(leaves, GenLeaf(seed, i))
+	}
+	return leaves
+}
+
+// GenTree returns a tree of the given size, with leaves generated by GenLeaf.
+// The tree can then be used for obtaining the expected root hashes and proofs.
+func GenTree(hasher merkle.LogHasher, seed, size uint64) *Tree {
+	tree := New(hasher)
+	tree.AppendData(GenLeaves(seed, 0, size)...)
+	return tree
+}
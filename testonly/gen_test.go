@@ -0,0 +1,79 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestGenLeaf(t *testing.T) {
+	// The generated data must never change, because it is used in golden tests.
+	for _, tc := range []struct {
+		seed, index uint64
+		want        string
+	}{
+		{seed: 0, index: 0, want: "374708fff7719dd5979ec875d56cd2286f6d3cf7ec31"},
+		{seed: 1, index: 2, want: "8c7654ecfd7b0b62"},
+	} {
+		if got := hex.EncodeToString(GenLeaf(tc.seed, tc.index)); got != tc.want {
+			t.Errorf("GenLeaf(%d, %d): got %s, want %s", tc.seed, tc.index, got, tc.want)
+		}
+	}
+
+	lens := make(map[int]bool)
+	for i := uint64(0); i < 1000; i++ {
+		leaf := GenLeaf(7, i)
+		if l := len(leaf); l > 32 {
+			t.Fatalf("GenLeaf(7, %d): got %d bytes, want at most 32", i, l)
+		}
+		lens[len(leaf)] = true
+		if bytes.Equal(leaf, GenLeaf(8, i)) && len(leaf) != 0 {
+			t.Errorf("GenLeaf(%d): same data for different seeds", i)
+		}
+	}
+	if !lens[0] || !lens[32] {
+		t.Errorf("GenLeaf: lengths 0 and 32 are not generated")
+	}
+}
+
+func TestGenTree(t *testing.T) {
+	tree := GenTree(rfc6962.DefaultHasher, 42, 100)
+	if got, want := tree.Size(), uint64(100); got != want {
+		t.Fatalf("Size: got %d, want %d", got, want)
+	}
+	want := "844688287768a6ce9c9ebe90cfa1f8043f974cf6776bc52d88154093397c79f8"
+	if got := hex.EncodeToString(tree.Hash()); got != want {
+		t.Errorf("Hash: got %s, want %s", got, want)
+	}
+
+	leaves := GenLeaves(42, 10, 20)
+	if got, want := len(leaves), 10; got != want {
+		t.Fatalf("GenLeaves: got %d leaves, want %d", got, want)
+	}
+	for i, leaf := range leaves {
+		index := uint64(10 + i)
+		if diff := cmp.Diff(leaf, GenLeaf(42, index)); diff != "" {
+			t.Errorf("GenLeaves: leaf %d: diff(-got +want):\n%s", index, diff)
+		}
+		if got, want := tree.LeafHash(index), rfc6962.DefaultHasher.HashLeaf(leaf); !bytes.Equal(got, want) {
+			t.Errorf("LeafHash(%d): got %x, want %x", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// InclusionProbe is a parameter set for inclusion proof verification.
+type InclusionProbe struct {
+	Index    uint64
+	Size     uint64
+	Root     []byte
+	LeafHash []byte
+	Proof    [][]byte
+
+	Desc string // Describes how the original parameters were corrupted.
+}
+
+// ConsistencyProbe is a parameter set for consistency proof verification.
+type ConsistencyProbe struct {
+	Size1 uint64
+	Size2 uint64
+	Root1 []byte
+	Root2 []byte
+	Proof [][]byte
+
+	Desc string // Describes how the original parameters were corrupted.
+}
+
+// CorruptInclusionProof returns systematically corrupted variants of the given
+// valid inclusion proof parameters. A correct verifier must reject all of them.
+// The passed in slices are not modified.
+func CorruptInclusionProof(index, size uint64, proof [][]byte, root, leafHash []byte) []InclusionProbe {
+	probe := func(desc string, index, size uint64, proof [][]byte, root, leafHash []byte) InclusionProbe {
+		return InclusionProbe{Index: index, Size: size, Root: root, LeafHash: leafHash, Proof: proof, Desc: desc}
+	}
+	ret := []InclusionProbe{
+		// Wrong leaf index.
+		probe("index - 1", index-1, size, proof, root, leafHash),
+		probe("index + 1", index+1, size, proof, root, leafHash),
+		probe("index ^ 1", index^1, size, proof, root, leafHash),
+		probe("index ^ 2", index^2, size, proof, root, leafHash),
+		probe("index = size", size, size, proof, root, leafHash),
+		// Wrong tree height.
+		probe("size * 2", index, size*2, proof, root, leafHash),
+		probe("size / 2", index, size/2, proof, root, leafHash),
+		// Wrong leaf or root.
+		probe("wrong leaf", index, size, proof, root, flipBit(leafHash, 0)),
+		probe("wrong root", index, size, proof, flipBit(root, 0), leafHash),
+	}
+	if ln := len(leafHash); ln != 0 {
+		ret = append(ret, probe("short leaf", index, size, proof, root, leafHash[:ln-1]))
+	}
+	for _, p := range corruptProof(proof, root, leafHash) {
+		ret = append(ret, probe(p.desc, index, size, p.proof, root, leafHash))
+	}
+	return ret
+}
+
+// CorruptConsistencyProof returns systematically corrupted variants of the
+// given valid consistency proof parameters. A correct verifier must reject all
+// of them. Requires 0 < size1 < size2, i.e. the proof must be non-empty. The
+// passed in slices are not modified.
+func CorruptConsistencyProof(size1, size2 uint64, proof [][]byte, root1, root2 []byte) []ConsistencyProbe {
+	probe := func(desc string, size1, size2 uint64, proof [][]byte, root1, root2 []byte) ConsistencyProbe {
+		return ConsistencyProbe{Size1: size1, Size2: size2, Root1: root1, Root2: root2, Proof: proof, Desc: desc}
+	}
+	ret := []ConsistencyProbe{
+		// Wrong size1.
+		probe("size1 - 1", size1-1, size2, proof, root1, root2),
+		probe("size1 + 1", size1+1, size2, proof, root1, root2),
+		probe("size1 ^ 2", size1^2, size2, proof, root1, root2),
+		probe("size1 = size2", size2, size2, proof, root1, root2),
+		// Wrong tree height.
+		probe("size2 * 2", size1, size2*2, proof, root1, root2),
+		probe("size2 / 2", size1, size2/2, proof, root1, root2),
+		// Wrong roots.
+		probe("wrong root1", size1, size2, proof, flipBit(root1, 0), root2),
+		probe("wrong root2", size1, size2, proof, root1, flipBit(root2, 0)),
+		probe("swapped roots", size1, size2, proof, root2, root1),
+		probe("empty proof", size1, size2, [][]byte{}, root1, root2),
+	}
+	for _, p := range corruptProof(proof, root1, root2) {
+		ret = append(ret, probe(p.desc, size1, size2, p.proof, root1, root2))
+	}
+	return ret
+}
+
+type corruptedProof struct {
+	proof [][]byte
+	desc  string
+}
+
+// corruptProof returns corrupted variants of the given proof: truncated,
+// extended, with elements removed, duplicated, swapped, or modified. The
+// extra hashes are used for extending the proof.
+func corruptProof(proof [][]byte, extra ...[]byte) []corruptedProof {
+	var ret []corruptedProof
+	add := func(proof [][]byte, format string, args ...interface{}) {
+		ret = append(ret, corruptedProof{proof: proof, desc: fmt.Sprintf(format, args...)})
+	}
+	ln := len(proof)
+
+	// Truncate to all shorter lengths.
+	for l := 0; l < ln; l++ {
+		add(proof[:l:l], "truncated to %d", l)
+	}
+	// Add garbage at the end and at the front.
+	for i, hash := range append([][]byte{{}}, extra...) {
+		add(splice(proof, ln, hash), "appended extra[%d]", i)
+		add(splice(proof, 0, hash), "prepended extra[%d]", i)
+	}
+	for i := 0; i < ln; i++ {
+		// Remove or duplicate one element.
+		add(splice(proof[:i:i], i, proof[i+1:]...), "removed proof[%d]", i)
+		add(splice(proof, i, proof[i]), "duplicated proof[%d]", i)
+		// Swap two different elements.
+		for j := i + 1; j < ln; j++ {
+			if bytes.Equal(proof[i], proof[j]) {
+				continue
+			}
+			swapped := splice(proof, 0)
+			swapped[i], swapped[j] = swapped[j], swapped[i]
+			add(swapped, "swapped proof[%d] and proof[%d]", i, j)
+		}
+		// Flip one bit in each byte, cycling through bit positions.
+		for b := range proof[i] {
+			modified := splice(proof, 0)
+			modified[i] = flipBit(proof[i], b)
+			add(modified, "flipped proof[%d] byte %d bit %d", i, b, b%8)
+		}
+	}
+	return ret
+}
+
+// splice returns a copy of the given proof, with the given hashes inserted at
+// the specified position.
+func splice(proof [][]byte, pos int, hashes ...[]byte) [][]byte {
+	res := make([][]byte, 0, len(proof)+len(hashes))
+	res = append(res, proof[:pos]...)
+	res = append(res, hashes...)
+	return append(res, proof[pos:]...)
+}
+
+// flipBit returns a copy of the given hash, with bit number b%8 of byte number b
+// flipped. Returns a non-empty hash if the input is empty.
+func flipBit(hash []byte, b int) []byte {
+	if len(hash) == 0 {
+		return []byte{1}
+	}
+	res := append([]byte(nil), hash...)
+	res[b] ^= 1 << (b % 8)
+	return res
+}
@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestCorruptInclusionProof(t *testing.T) {
+	const maxSize = 20
+	tree := newTree(genEntries(maxSize))
+	for size := uint64(1); size <= maxSize; size++ {
+		root := tree.HashAt(size)
+		for index := uint64(0); index < size; index++ {
+			t.Run(fmt.Sprintf("%d:%d", index, size), func(t *testing.T) {
+				p, err := tree.InclusionProof(index, size)
+				if err != nil {
+					t.Fatalf("InclusionProof: %v", err)
+				}
+				leafHash := tree.LeafHash(index)
+				orig := clone(p)
+				for _, pr := range CorruptInclusionProof(index, size, p, root, leafHash) {
+					if err := proof.VerifyInclusion(rfc6962.DefaultHasher, pr.Index, pr.Size, pr.LeafHash, pr.Proof, pr.Root); err == nil {
+						t.Errorf("VerifyInclusion: %s: want error", pr.Desc)
+					}
+				}
+				if diff := cmp.Diff(p, orig); diff != "" {
+					t.Errorf("CorruptInclusionProof modified the proof: diff(-got +want):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func TestCorruptConsistencyProof(t *testing.T) {
+	const maxSize = 20
+	tree := newTree(genEntries(maxSize))
+	for size2 := uint64(2); size2 <= maxSize; size2++ {
+		root2 := tree.HashAt(size2)
+		for size1 := uint64(1); size1 < size2; size1++ {
+			t.Run(fmt.Sprintf("%d:%d", size1, size2), func(t *testing.T) {
+				p, err := tree.ConsistencyProof(size1, size2)
+				if err != nil {
+					t.Fatalf("ConsistencyProof: %v", err)
+				}
+				root1 := tree.HashAt(size1)
+				orig := clone(p)
+				for _, pr := range CorruptConsistencyProof(size1, size2, p, root1, root2) {
+					if err := proof.VerifyConsistency(rfc6962.DefaultHasher, pr.Size1, pr.Size2, pr.Proof, pr.Root1, pr.Root2); err == nil {
+						t.Errorf("VerifyConsistency: %s: want error", pr.Desc)
+					}
+				}
+				if diff := cmp.Diff(p, orig); diff != "" {
+					t.Errorf("CorruptConsistencyProof modified the proof: diff(-got +want):\n%s", diff)
+				}
+			})
+		}
+	}
+}
+
+func clone(proof [][]byte) [][]byte {
+	res := make([][]byte, len(proof))
+	for i, hash := range proof {
+		res[i] = append([]byte(nil), hash...)
+	}
+	return res
+}
@@ -105,6 +105,17 @@ func (t *Tree) InclusionProof(index, size uint64) ([][]byte, error) {
 	return nodes.Rehash(t.getNodes(nodes.IDs), t.hasher.HashChildren)
 }
 
+// NodeInclusionProof returns the inclusion proof for the given perfect node in
+// the tree of the given size. Requires that the node is fully covered by the
+// tree, and size <= Size(), otherwise may panic.
+func (t *Tree) NodeInclusionProof(id compact.NodeID, size uint64) ([][]byte, error) {
+	nodes, err := proof.NodeInclusion(id, size)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Rehash(t.getNodes(nodes.IDs), t.hasher.HashChildren)
+}
+
 // ConsistencyProof returns the consistency proof between the two given tree
 // sizes. Requires 0 <= size1 <= size2 <= Size(), otherwise may panic.
 func (t *Tree) ConsistencyProof(size1, size2 uint64) ([][]byte, error) {
@@ -115,6 +126,24 @@ func (t *Tree) ConsistencyProof(size1, size2 uint64) ([][]byte, error) {
 	return nodes.Rehash(t.getNodes(nodes.IDs), t.hasher.HashChildren)
 }
 
+// CombinedProof returns the combined proof that the given leaf index is
+// included into the tree of size1, which is consistent with the tree of size2.
+// Requires 0 <= index < size1 <= size2 <= Size(), otherwise may panic.
+func (t *Tree) CombinedProof(index, size1, size2 uint64) (proof.Combined, error) {
+	incl, cons, err := proof.InclusionAndConsistency(index, size1, size2)
+	if err != nil {
+		return proof.Combined{}, err
+	}
+	var p proof.Combined
+	if p.Inclusion, err = incl.Rehash(t.getNodes(incl.IDs), t.hasher.HashChildren); err != nil {
+		return proof.Combined{}, err
+	}
+	if p.Consistency, err = cons.Rehash(t.getNodes(cons.IDs), t.hasher.HashChildren); err != nil {
+		return proof.Combined{}, err
+	}
+	return p, nil
+}
+
 func (t *Tree) getNodes(ids []compact.NodeID) [][]byte {
 	hashes := make([][]byte, len(ids))
 	for i, id := range ids {
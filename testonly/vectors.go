@@ -0,0 +1,80 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import "github.com/transparency-dev/merkle"
+
+//go:generate go run ../cmd/genvectors --seed=0 --size=16 --out=testdata/rfc6962_vectors.json
+
+// Vectors is a set of test vectors for a Merkle tree. It can be encoded as
+// JSON, and used as a conformance corpus for other implementations. All binary
+// values are encoded in base64 by the encoding/json package.
+type Vectors struct {
+	Seed   uint64   `json:"seed"`   // The seed passed to GenLeaf.
+	Leaves [][]byte `json:"leaves"` // The leaf data.
+	// Roots contains the root hashes of all the tree sizes, i.e. Roots[size] is
+	// the root hash of the tree of this size, for 0 <= size <= len(Leaves).
+	Roots       [][]byte            `json:"roots"`
+	Inclusion   []InclusionVector   `json:"inclusion"`
+	Consistency []ConsistencyVector `json:"consistency"`
+}
+
+// InclusionVector is an inclusion proof for the given leaf index in the tree
+// of the given size.
+type InclusionVector struct {
+	Index uint64   `json:"index"`
+	Size  uint64   `json:"size"`
+	Proof [][]byte `json:"proof"`
+}
+
+// ConsistencyVector is a consistency proof between the two tree sizes.
+type ConsistencyVector struct {
+	Size1 uint64   `json:"size1"`
+	Size2 uint64   `json:"size2"`
+	Proof [][]byte `json:"proof"`
+}
+
+// GenVectors returns the test vectors for the tree of the given size, with
+// leaves generated by GenLeaf. The vectors contain the inclusion proofs for all
+// the leaves in all the tree sizes, and the consistency proofs between all the
+// pairs of tree sizes, so their number is quadratic in the size.
+func GenVectors(hasher merkle.LogHasher, seed, size uint64) (*Vectors, error) {
+	tree := GenTree(hasher, seed, size)
+	v := &Vectors{
+		Seed:        seed,
+		Leaves:      GenLeaves(seed, 0, size),
+		Roots:       make([][]byte, 0, size+1),
+		Inclusion:   []InclusionVector{},
+		Consistency: []ConsistencyVector{},
+	}
+	for size2 := uint64(0); size2 <= size; size2++ {
+		v.Roots = append(v.Roots, tree.HashAt(size2))
+		for index := uint64(0); index < size2; index++ {
+			proof, err := tree.InclusionProof(index, size2)
+			if err != nil {
+				return nil, err
+			}
+			v.Inclusion = append(v.Inclusion, InclusionVector{Index: index, Size: size2, Proof: proof})
+		}
+		for size1 := uint64(0); size1 <= size2; size1++ {
+			proof, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				return nil, err
+			}
+			v.Consistency = append(v.Consistency, ConsistencyVector{Size1: size1, Size2: size2, Proof: proof})
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testonly
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+)
+
+func TestGenVectorsGolden(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc6962_vectors.json")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	var golden Vectors
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	v, err := GenVectors(rfc6962.DefaultHasher, golden.Seed, uint64(len(golden.Leaves)))
+	if err != nil {
+		t.Fatalf("GenVectors: %v", err)
+	}
+	if diff := cmp.Diff(v, &golden); diff != "" {
+		t.Errorf("GenVectors differs from golden vectors, run go generate: diff(-got +want):\n%s", diff)
+	}
+}
+
+func TestGenVectors(t *testing.T) {
+	const size = 10
+	hasher := rfc6962.DefaultHasher
+	v, err := GenVectors(hasher, 1, size)
+	if err != nil {
+		t.Fatalf("GenVectors: %v", err)
+	}
+	if got, want := len(v.Roots), size+1; got != want {
+		t.Fatalf("got %d roots, want %d", got, want)
+	}
+	if got, want := len(v.Inclusion), size*(size+1)/2; got != want {
+		t.Errorf("got %d inclusion proofs, want %d", got, want)
+	}
+	if got, want := len(v.Consistency), (size+1)*(size+2)/2; got != want {
+		t.Errorf("got %d consistency proofs, want %d", got, want)
+	}
+	for _, p := range v.Inclusion {
+		leafHash := hasher.HashLeaf(v.Leaves[p.Index])
+		if err := proof.VerifyInclusion(hasher, p.Index, p.Size, leafHash, p.Proof, v.Roots[p.Size]); err != nil {
+			t.Errorf("VerifyInclusion(%d, %d): %v", p.Index, p.Size, err)
+		}
+	}
+	for _, p := range v.Consistency {
+		if err := proof.VerifyConsistency(hasher, p.Size1, p.Size2, p.Proof, v.Roots[p.Size1], v.Roots[p.Size2]); err != nil {
+			t.Errorf("VerifyConsistency(%d, %d): %v", p.Size1, p.Size2, err)
+		}
+	}
+}
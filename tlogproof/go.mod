@@ -0,0 +1,13 @@
+module github.com/transparency-dev/merkle/tlogproof
+
+go 1.17
+
+require (
+	github.com/google/go-cmp v0.5.9
+	github.com/transparency-dev/merkle v0.0.2
+	golang.org/x/mod v0.12.0
+)
+
+// The package is developed together with the merkle module, and tested against
+// its version in the same repository.
+replace github.com/transparency-dev/merkle => ../
@@ -0,0 +1,92 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlogproof converts proofs between the form used by the proof package
+// with the RFC 6962 hasher, and the form used by the golang.org/x/mod/sumdb/tlog
+// package, i.e. by the Go checksum database.
+//
+// Both packages build the proofs as defined in RFC 6962, section 2.1, with the
+// same hash function, and the same order of the hashes: from the lowest level
+// of the tree to the root. For example, tlog.CheckRecord accepts an inclusion
+// proof for leaf index n in the tree of size t exactly when
+// proof.VerifyInclusion does, and same for tlog.CheckTree and
+// proof.VerifyConsistency. Therefore, no reordering is needed, and the
+// conversion only changes the representation of hashes. The tlog package only
+// supports the consistency proofs from non-empty trees.
+//
+// The package is a separate Go module, so that the merkle module itself does
+// not depend on golang.org/x/mod.
+package tlogproof
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+// FromRecordProof returns the inclusion proof equivalent to the given tlog
+// record proof.
+func FromRecordProof(p tlog.RecordProof) [][]byte {
+	return fromHashes(p)
+}
+
+// ToRecordProof returns the tlog record proof equivalent to the given
+// inclusion proof. Returns an error if any of the hashes has a wrong size.
+func ToRecordProof(proof [][]byte) (tlog.RecordProof, error) {
+	return toHashes(proof)
+}
+
+// FromTreeProof returns the consistency proof equivalent to the given tlog
+// tree proof.
+func FromTreeProof(p tlog.TreeProof) [][]byte {
+	return fromHashes(p)
+}
+
+// ToTreeProof returns the tlog tree proof equivalent to the given consistency
+// proof. Returns an error if any of the hashes has a wrong size.
+func ToTreeProof(proof [][]byte) (tlog.TreeProof, error) {
+	return toHashes(proof)
+}
+
+// ToHash converts the given hash to the tlog format.
+func ToHash(hash []byte) (tlog.Hash, error) {
+	var h tlog.Hash
+	if got, want := len(hash), len(h); got != want {
+		return h, fmt.Errorf("hash has size %d, want %d", got, want)
+	}
+	copy(h[:], hash)
+	return h, nil
+}
+
+// fromHashes converts the tlog hashes to a proof. The hashes are copied, so
+// that the proof does not alias the tlog one.
+func fromHashes(hashes []tlog.Hash) [][]byte {
+	res := make([][]byte, len(hashes))
+	for i := range hashes {
+		res[i] = append([]byte(nil), hashes[i][:]...)
+	}
+	return res
+}
+
+func toHashes(proof [][]byte) ([]tlog.Hash, error) {
+	res := make([]tlog.Hash, len(proof))
+	for i, hash := range proof {
+		h, err := ToHash(hash)
+		if err != nil {
+			return nil, fmt.Errorf("proof[%d]: %v", i, err)
+		}
+		res[i] = h
+	}
+	return res, nil
+}
@@ -0,0 +1,153 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlogproof
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"github.com/transparency-dev/merkle/testonly"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+const maxSize = 70
+
+var hasher = rfc6962.DefaultHasher
+
+// trees returns the test tree built by this module, and the stored hashes of
+// the same tree built by the tlog package.
+func trees(t *testing.T) (*testonly.Tree, tlog.HashReader) {
+	t.Helper()
+	tree := testonly.New(hasher)
+	var stored []tlog.Hash
+	r := tlog.HashReaderFunc(func(indices []int64) ([]tlog.Hash, error) {
+		res := make([]tlog.Hash, len(indices))
+		for i, index := range indices {
+			res[i] = stored[index]
+		}
+		return res, nil
+	})
+	for i := int64(0); i < maxSize; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		tree.AppendData(data)
+		hashes, err := tlog.StoredHashes(i, data, r)
+		if err != nil {
+			t.Fatalf("StoredHashes: %v", err)
+		}
+		stored = append(stored, hashes...)
+	}
+	return tree, r
+}
+
+func toHash(t *testing.T, hash []byte) tlog.Hash {
+	t.Helper()
+	h, err := ToHash(hash)
+	if err != nil {
+		t.Fatalf("ToHash: %v", err)
+	}
+	return h
+}
+
+func TestRecordProof(t *testing.T) {
+	tree, r := trees(t)
+	for size := uint64(1); size <= maxSize; size++ {
+		root := tree.HashAt(size)
+		if th, err := tlog.TreeHash(int64(size), r); err != nil {
+			t.Fatalf("TreeHash: %v", err)
+		} else if diff := cmp.Diff(th[:], root); diff != "" {
+			t.Fatalf("TreeHash(%d): diff(-got +want):\n%s", size, diff)
+		}
+		for index := uint64(0); index < size; index++ {
+			leafHash := tree.LeafHash(index)
+			// This module's proof must be accepted by tlog.
+			p, err := tree.InclusionProof(index, size)
+			if err != nil {
+				t.Fatalf("InclusionProof: %v", err)
+			}
+			rp, err := ToRecordProof(p)
+			if err != nil {
+				t.Fatalf("ToRecordProof: %v", err)
+			}
+			if err := tlog.CheckRecord(rp, int64(size), toHash(t, root), int64(index), toHash(t, leafHash)); err != nil {
+				t.Errorf("CheckRecord(%d, %d): %v", index, size, err)
+			}
+
+			// The tlog proof must be accepted by this module.
+			rp, err = tlog.ProveRecord(int64(size), int64(index), r)
+			if err != nil {
+				t.Fatalf("ProveRecord: %v", err)
+			}
+			if err := proof.VerifyInclusion(hasher, index, size, leafHash, FromRecordProof(rp), root); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d): %v", index, size, err)
+			}
+		}
+	}
+}
+
+func TestTreeProof(t *testing.T) {
+	tree, r := trees(t)
+	for size2 := uint64(1); size2 <= maxSize; size2++ {
+		root2 := tree.HashAt(size2)
+		for size1 := uint64(1); size1 <= size2; size1++ {
+			root1 := tree.HashAt(size1)
+			p, err := tree.ConsistencyProof(size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof: %v", err)
+			}
+			tp, err := ToTreeProof(p)
+			if err != nil {
+				t.Fatalf("ToTreeProof: %v", err)
+			}
+			if err := tlog.CheckTree(tp, int64(size2), toHash(t, root2), int64(size1), toHash(t, root1)); err != nil {
+				t.Errorf("CheckTree(%d, %d): %v", size1, size2, err)
+			}
+
+			tp, err = tlog.ProveTree(int64(size2), int64(size1), r)
+			if err != nil {
+				t.Fatalf("ProveTree: %v", err)
+			}
+			if err := proof.VerifyConsistency(hasher, size1, size2, FromTreeProof(tp), root1, root2); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d): %v", size1, size2, err)
+			}
+		}
+	}
+}
+
+func TestFromProofCopies(t *testing.T) {
+	rp := tlog.RecordProof{{1}, {2}}
+	p := FromRecordProof(rp)
+	p[0][0] = 3
+	if got, want := rp[0][0], byte(1); got != want {
+		t.Errorf("FromRecordProof: modifying the proof changed the record proof hash to %d, want %d", got, want)
+	}
+	tp := tlog.TreeProof{{1}}
+	FromTreeProof(tp)[0][0] = 3
+	if got, want := tp[0][0], byte(1); got != want {
+		t.Errorf("FromTreeProof: modifying the proof changed the tree proof hash to %d, want %d", got, want)
+	}
+}
+
+func TestWrongHashSize(t *testing.T) {
+	p := [][]byte{make([]byte, 32), make([]byte, 31)}
+	if _, err := ToRecordProof(p); err == nil {
+		t.Error("ToRecordProof: want error")
+	}
+	if _, err := ToTreeProof(p); err == nil {
+		t.Error("ToTreeProof: want error")
+	}
+}
@@ -0,0 +1,339 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlogtiles provides a client which reads a Merkle tree from an
+// endpoint implementing the C2SP tlog-tiles specification, see
+// https://c2sp.org/tlog-tiles, and assembles inclusion and consistency proofs
+// locally, without relying on server-side proof endpoints.
+//
+// A tile at level L and index N contains the hashes of the tree nodes at level
+// 8*L with indices [256*N, 256*N+W), where the width W is 256 for full tiles.
+// The hashes of the nodes at levels between 8*L and 8*(L+1) are computed from
+// them. Full tiles never change, so the client caches the fetched tiles.
+package tlogtiles
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/transparency-dev/merkle"
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+)
+
+const (
+	// TileHeight is the height of tiles, i.e. the number of tree levels that
+	// each tile spans.
+	TileHeight = 8
+	// TileWidth is the number of hashes in a full tile.
+	TileWidth = 1 << TileHeight
+)
+
+// TilePath returns the path of the tile with the given level, index and width,
+// relative to the log prefix. The width must be in [1, TileWidth]; tiles of
+// width less than TileWidth are partial.
+func TilePath(level uint, index uint64, width uint) string {
+	// The index is encoded as 3-digit path elements, all but the last one are
+	// prefixed with "x".
+	n := fmt.Sprintf("%03d", index%1000)
+	for index /= 1000; index != 0; index /= 1000 {
+		n = fmt.Sprintf("x%03d/%s", index%1000, n)
+	}
+	path := fmt.Sprintf("tile/%d/%s", level, n)
+	if width < TileWidth {
+		path += fmt.Sprintf(".p/%d", width)
+	}
+	return path
+}
+
+// FetchFn returns the contents of the resource at the given path relative to
+// the log prefix. It must fail if the resource is bigger than maxSize bytes,
+// without reading much more than that.
+type FetchFn func(ctx context.Context, path string, maxSize int) ([]byte, error)
+
+// HTTPFetcher returns a FetchFn which fetches the resources from the given log
+// prefix URL using the given HTTP client. If client is nil,
+// http.DefaultClient is used.
+func HTTPFetcher(prefix string, client *http.Client) FetchFn {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	return func(ctx context.Context, path string, maxSize int) ([]byte, error) {
+		url := prefix + "/" + path
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxSize)+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxSize {
+			return nil, fmt.Errorf("GET %s: response exceeds %d bytes", url, maxSize)
+		}
+		return data, nil
+	}
+}
+
+// Client reads tiles from a tlog-tiles endpoint, and computes the node hashes
+// and proofs of the tree. It is safe for concurrent use.
+//
+// Concurrent requests for the same tile share a single fetch, which is made
+// without holding the lock, so requests for other tiles are not blocked.
+type Client struct {
+	hasher merkle.LogHasher
+	fetch  FetchFn
+
+	mu      sync.Mutex
+	tiles   *tileCache           // The cached tiles, keyed by path.
+	pending map[string]*tileCall // The tiles being fetched, keyed by path.
+}
+
+// tileCall is a tile fetch shared by the concurrent requests for the tile.
+type tileCall struct {
+	done chan struct{} // Closed when the fetch is complete.
+	tile [][]byte
+	err  error
+}
+
+// NewClient returns a Client which reads the tiles using the given fetch
+// function, and caches up to cacheSize most recently used tiles. The hasher
+// must match the log, which is RFC 6962 with SHA-256 for tlog-tiles compliant
+// logs.
+func NewClient(hasher merkle.LogHasher, fetch FetchFn, cacheSize int) *Client {
+	return &Client{
+		hasher:  hasher,
+		fetch:   fetch,
+		tiles:   newTileCache(cacheSize),
+		pending: make(map[string]*tileCall),
+	}
+}
+
+// InclusionProof returns the inclusion proof for the given leaf index in the
+// tree of the given size.
+func (c *Client) InclusionProof(ctx context.Context, index, size uint64) ([][]byte, error) {
+	nodes, err := proof.Inclusion(index, size)
+	if err != nil {
+		return nil, err
+	}
+	return c.rehash(ctx, nodes, size)
+}
+
+// ConsistencyProof returns the consistency proof between the two given tree
+// sizes.
+func (c *Client) ConsistencyProof(ctx context.Context, size1, size2 uint64) ([][]byte, error) {
+	nodes, err := proof.Consistency(size1, size2)
+	if err != nil {
+		return nil, err
+	}
+	return c.rehash(ctx, nodes, size2)
+}
+
+// RootHash returns the root hash of the tree of the given size. The caller
+// should compare it with the root hash of a checkpoint which they trust.
+func (c *Client) RootHash(ctx context.Context, size uint64) ([]byte, error) {
+	if size == 0 {
+		return c.hasher.EmptyRoot(), nil
+	}
+	ids := compact.RangeNodes(0, size, nil)
+	hashes, err := c.hashes(ctx, ids, size)
+	if err != nil {
+		return nil, err
+	}
+	rng, err := (&compact.RangeFactory{Hash: c.hasher.HashChildren}).NewRange(0, size, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return rng.GetRootHash(nil)
+}
+
+// NodeHash returns the hash of the given perfect node of the tree of the given
+// size. The node must be fully contained in the tree.
+func (c *Client) NodeHash(ctx context.Context, id compact.NodeID, size uint64) ([]byte, error) {
+	if begin, end := id.Coverage(); end <= begin || end > size {
+		return nil, fmt.Errorf("node %+v not in tree of size %d", id, size)
+	}
+	// The node is the root of a subtree of the tile at level id.Level/8, and it
+	// covers 2^k hashes of the tile's bottom row.
+	level, k := id.Level/TileHeight, id.Level%TileHeight
+	begin, end := id.Index<<k, (id.Index+1)<<k
+	index := begin / TileWidth
+	width := uint(TileWidth)
+	if rowSize := size >> (level * TileHeight); rowSize/TileWidth == index {
+		width = uint(rowSize % TileWidth)
+	}
+	tile, err := c.tile(ctx, level, index, width)
+	if err != nil {
+		return nil, err
+	}
+	hashes := tile[begin-index*TileWidth : end-index*TileWidth]
+	for len(hashes) > 1 {
+		next := make([][]byte, len(hashes)/2)
+		for i := range next {
+			next[i] = c.hasher.HashChildren(hashes[2*i], hashes[2*i+1])
+		}
+		hashes = next
+	}
+	return hashes[0], nil
+}
+
+// rehash fetches the hashes of the given proof nodes in the tree of the given
+// size, and returns the proof.
+func (c *Client) rehash(ctx context.Context, nodes proof.Nodes, size uint64) ([][]byte, error) {
+	hashes, err := c.hashes(ctx, nodes.IDs, size)
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Rehash(hashes, c.hasher.HashChildren)
+}
+
+// hashes returns the hashes of the given nodes in the tree of the given size.
+func (c *Client) hashes(ctx context.Context, ids []compact.NodeID, size uint64) ([][]byte, error) {
+	hashes := make([][]byte, len(ids))
+	for i, id := range ids {
+		hash, err := c.NodeHash(ctx, id, size)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// tile returns the hashes of the given tile, fetching it if necessary. A
+// cached full tile is used for serving partial tiles too.
+func (c *Client) tile(ctx context.Context, level uint, index uint64, width uint) ([][]byte, error) {
+	path := TilePath(level, index, width)
+	for {
+		c.mu.Lock()
+		if tile, ok := c.tiles.get(TilePath(level, index, TileWidth)); ok {
+			c.mu.Unlock()
+			return tile[:width], nil
+		}
+		if tile, ok := c.tiles.get(path); ok {
+			c.mu.Unlock()
+			return tile, nil
+		}
+		call, ok := c.pending[path]
+		if !ok {
+			call = &tileCall{done: make(chan struct{})}
+			c.pending[path] = call
+		}
+		c.mu.Unlock()
+
+		if !ok { // This request makes the fetch.
+			call.tile, call.err = c.load(ctx, path, width)
+			c.mu.Lock()
+			delete(c.pending, path)
+			if call.err == nil {
+				c.tiles.put(path, call.tile)
+			}
+			c.mu.Unlock()
+			close(call.done)
+			return call.tile, call.err
+		}
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// Retry if the fetch failed only because its request was cancelled.
+		if call.err == nil || ctx.Err() != nil || !isContextError(call.err) {
+			return call.tile, call.err
+		}
+	}
+}
+
+// load fetches and parses the tile at the given path.
+func (c *Client) load(ctx context.Context, path string, width uint) ([][]byte, error) {
+	size := c.hasher.Size()
+	data, err := c.fetch(ctx, path, int(width)*size)
+	if err != nil {
+		return nil, err
+	}
+	if got, want := len(data), int(width)*size; got != want {
+		return nil, fmt.Errorf("tile %s: got %d bytes, want %d", path, got, want)
+	}
+	tile := make([][]byte, width)
+	for i := range tile {
+		tile[i] = data[i*size : (i+1)*size : (i+1)*size]
+	}
+	return tile, nil
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// tileCache is a bounded map from tile paths to tiles, which evicts the least
+// recently used tiles. It is not safe for concurrent use.
+type tileCache struct {
+	size  int
+	order *list.List // Ordered from most to least recently used.
+	items map[string]*list.Element
+}
+
+// cachedTile is an element of the tileCache order list.
+type cachedTile struct {
+	path string
+	tile [][]byte
+}
+
+func newTileCache(size int) *tileCache {
+	return &tileCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns the tile with the given path, and marks it as recently used.
+func (c *tileCache) get(path string) ([][]byte, bool) {
+	el, ok := c.items[path]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cachedTile).tile, true
+}
+
+// put adds the tile with the given path, and evicts the least recently used
+// tile if the cache is full.
+func (c *tileCache) put(path string, tile [][]byte) {
+	if el, ok := c.items[path]; ok {
+		el.Value.(*cachedTile).tile = tile
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.size <= 0 {
+		return
+	}
+	if c.order.Len() >= c.size {
+		last := c.order.Back()
+		delete(c.items, last.Value.(*cachedTile).path)
+		c.order.Remove(last)
+	}
+	c.items[path] = c.order.PushFront(&cachedTile{path: path, tile: tile})
+}
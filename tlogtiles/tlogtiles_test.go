@@ -0,0 +1,275 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlogtiles
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/transparency-dev/merkle/compact"
+	"github.com/transparency-dev/merkle/proof"
+	"github.com/transparency-dev/merkle/rfc6962"
+	"golang.org/x/mod/sumdb/tlog"
+)
+
+var hasher = rfc6962.DefaultHasher
+
+func TestTilePath(t *testing.T) {
+	for _, tc := range []struct {
+		level uint
+		index uint64
+		width uint
+		want  string
+	}{
+		{level: 0, index: 0, width: 256, want: "tile/0/000"},
+		{level: 0, index: 7, width: 1, want: "tile/0/007.p/1"},
+		{level: 1, index: 1000, width: 256, want: "tile/1/x001/000"},
+		{level: 2, index: 1234067, width: 255, want: "tile/2/x001/x234/067.p/255"},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := TilePath(tc.level, tc.index, tc.width); got != tc.want {
+				t.Errorf("TilePath: got %q, want %q", got, tc.want)
+			}
+			// Check against the Go checksum database tiles, which have the same
+			// layout, but also encode the tile height in the path.
+			tile := tlog.Tile{H: TileHeight, L: int(tc.level), N: int64(tc.index), W: int(tc.width)}
+			if got, want := "tile/8/"+strings.TrimPrefix(tc.want, "tile/"), tile.Path(); got != want {
+				t.Errorf("tlog path: got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// newTileServer returns an HTTP server serving the tiles of the tree with the
+// given number of leaves, using the tlog package as the reference. Also returns
+// the reader of the tree hashes, and a counter of the served requests.
+func newTileServer(t *testing.T, size int64) (*httptest.Server, tlog.HashReader, *int32) {
+	t.Helper()
+	var hashes []tlog.Hash
+	reader := tlog.HashReaderFunc(func(indices []int64) ([]tlog.Hash, error) {
+		res := make([]tlog.Hash, len(indices))
+		for i, index := range indices {
+			if index >= int64(len(hashes)) {
+				return nil, fmt.Errorf("hash %d not found", index)
+			}
+			res[i] = hashes[index]
+		}
+		return res, nil
+	})
+	for i := int64(0); i < size; i++ {
+		stored, err := tlog.StoredHashes(i, []byte(fmt.Sprintf("leaf: %d", i)), reader)
+		if err != nil {
+			t.Fatalf("StoredHashes: %v", err)
+		}
+		hashes = append(hashes, stored...)
+	}
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		tile, err := tlog.ParseTilePath("tile/8/" + strings.TrimPrefix(r.URL.Path, "/tile/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := tlog.ReadTileData(tile, reader)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+	return server, reader, &requests
+}
+
+func TestClient(t *testing.T) {
+	// The tree has 3 levels of tiles, the top one partial.
+	const size = 256*256 + 300
+	server, reader, requests := newTileServer(t, size)
+	ctx := context.Background()
+	c := NewClient(hasher, HTTPFetcher(server.URL+"/", nil), 100)
+
+	roots := make(map[uint64][]byte)
+	for _, s := range []uint64{1, 2, 255, 256, 257, 1000, 256 * 256, size} {
+		root, err := c.RootHash(ctx, s)
+		if err != nil {
+			t.Fatalf("RootHash(%d): %v", s, err)
+		}
+		want, err := tlog.TreeHash(int64(s), reader)
+		if err != nil {
+			t.Fatalf("TreeHash(%d): %v", s, err)
+		}
+		if !bytes.Equal(root, want[:]) {
+			t.Errorf("RootHash(%d): got %x, want %x", s, root, want)
+		}
+		roots[s] = root
+	}
+	for _, index := range []uint64{0, 1, 255, 256, 999, 256 * 256, size - 1} {
+		for s := range roots {
+			if index >= s {
+				continue
+			}
+			hashes, err := c.InclusionProof(ctx, index, s)
+			if err != nil {
+				t.Fatalf("InclusionProof(%d, %d): %v", index, s, err)
+			}
+			leafHash := hasher.HashLeaf([]byte(fmt.Sprintf("leaf: %d", index)))
+			if err := proof.VerifyInclusion(hasher, index, s, leafHash, hashes, roots[s]); err != nil {
+				t.Errorf("VerifyInclusion(%d, %d): %v", index, s, err)
+			}
+		}
+	}
+	for size1 := range roots {
+		for size2 := range roots {
+			if size1 > size2 {
+				continue
+			}
+			hashes, err := c.ConsistencyProof(ctx, size1, size2)
+			if err != nil {
+				t.Fatalf("ConsistencyProof(%d, %d): %v", size1, size2, err)
+			}
+			if err := proof.VerifyConsistency(hasher, size1, size2, hashes, roots[size1], roots[size2]); err != nil {
+				t.Errorf("VerifyConsistency(%d, %d): %v", size1, size2, err)
+			}
+		}
+	}
+
+	// All the tiles are cached by now.
+	before := atomic.LoadInt32(requests)
+	if _, err := c.RootHash(ctx, size); err != nil {
+		t.Fatalf("RootHash: %v", err)
+	}
+	if got := atomic.LoadInt32(requests); got != before {
+		t.Errorf("RootHash: made %d requests, want 0", got-before)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	server, _, _ := newTileServer(t, 10)
+	c := NewClient(hasher, HTTPFetcher(server.URL, nil), 100)
+	if _, err := c.InclusionProof(ctx, 0, 20); err == nil {
+		t.Error("InclusionProof: expected error for missing tile")
+	}
+	if _, err := c.InclusionProof(ctx, 10, 10); err == nil {
+		t.Error("InclusionProof: expected error for index out of range")
+	}
+
+	// The tile of a wrong size is rejected.
+	c = NewClient(hasher, func(ctx context.Context, path string, maxSize int) ([]byte, error) {
+		return bytes.Repeat([]byte{1}, 31), nil
+	}, 100)
+	if _, err := c.RootHash(ctx, 1); err == nil {
+		t.Error("RootHash: expected error for malformed tile")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	c = NewClient(hasher, HTTPFetcher(server.URL, nil), 100)
+	if _, err := c.RootHash(cancelled, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("RootHash: got %v, want context.Canceled", err)
+	}
+}
+
+func TestHTTPFetcherLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(make([]byte, 1<<20))
+	}))
+	defer server.Close()
+	fetch := HTTPFetcher(server.URL, nil)
+	if _, err := fetch(context.Background(), "tile/0/000", 64); err == nil {
+		t.Error("fetch: expected error for oversized response")
+	}
+	if data, err := fetch(context.Background(), "tile/0/000", 1<<20); err != nil || len(data) != 1<<20 {
+		t.Errorf("fetch: got %d bytes, %v; want %d bytes", len(data), err, 1<<20)
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	const size = 3 * 256
+	server, _, requests := newTileServer(t, size)
+	ctx := context.Background()
+	// The cache holds one tile, so alternating between two tiles refetches them.
+	c := NewClient(hasher, HTTPFetcher(server.URL, nil), 1)
+	for i, index := range []uint64{0, 0, 256, 256, 0} {
+		if _, err := c.NodeHash(ctx, compact.NewNodeID(0, index), size); err != nil {
+			t.Fatalf("NodeHash: %v", err)
+		}
+		if got, want := atomic.LoadInt32(requests), []int32{1, 1, 2, 2, 3}[i]; got != want {
+			t.Errorf("NodeHash(%d): %d requests in total, want %d", index, got, want)
+		}
+	}
+}
+
+func TestClientConcurrentFetch(t *testing.T) {
+	server, _, _ := newTileServer(t, 256)
+	fetch := HTTPFetcher(server.URL, nil)
+	var fetches int32
+	release := make(chan struct{})
+	c := NewClient(hasher, func(ctx context.Context, path string, maxSize int) ([]byte, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		return fetch(ctx, path, maxSize)
+	}, 10)
+
+	const requests = 10
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func(index uint64) {
+			_, err := c.NodeHash(context.Background(), compact.NewNodeID(0, index), 256)
+			errs <- err
+		}(uint64(i))
+	}
+	// Wait for the first fetch to start, and give the other requests time to
+	// join it before releasing.
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	for i := 0; i < requests; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("NodeHash: %v", err)
+		}
+	}
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("got %d fetches, want 1", got)
+	}
+
+	// A request which is cancelled while waiting for the shared fetch returns.
+	started, block := make(chan struct{}), make(chan struct{})
+	defer close(block)
+	c = NewClient(hasher, func(ctx context.Context, path string, maxSize int) ([]byte, error) {
+		close(started)
+		<-block
+		return nil, errors.New("blocked")
+	}, 10)
+	go c.NodeHash(context.Background(), compact.NewNodeID(0, 0), 256)
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := c.NodeHash(ctx, compact.NewNodeID(0, 1), 256); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NodeHash: got %v, want context.DeadlineExceeded", err)
+	}
+}
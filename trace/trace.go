@@ -0,0 +1,84 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package trace provides optional hooks through which the proof builders and
+// verifiers of this module report the details of their computations. This
+// helps debugging proof mismatches without stepping through the index
+// arithmetic in a debugger.
+//
+// By default, nothing is reported. To trace the computations, implement the
+// Tracer interface, or use NewWriter which logs them as text, and pass it to
+// the components which report them, e.g. proof.Verifier and proof.Fetcher.
+// Tracing is slow, and is meant for debugging only.
+package trace
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+// Tracer receives the details of proof computations. Its methods can be called
+// concurrently.
+type Tracer interface {
+	// Nodes is called when a proof is built from the node hashes, see
+	// proof.Nodes.Trace. It receives the IDs of the proof nodes, and the
+	// [begin, end) window of the IDs which are rehashed into the ephemeral node
+	// ephem. No rehashing is done if the window has less than 2 IDs.
+	Nodes(ids []compact.NodeID, begin, end int, ephem compact.NodeID)
+	// Hash is called for each node hash computed from the left and right child
+	// hashes while building or verifying proofs.
+	Hash(left, right, hash []byte)
+	// Verified is called when a proof verification completes, with its result.
+	Verified(err error)
+}
+
+// NewWriter returns a Tracer which writes the events to w, one per line.
+func NewWriter(w io.Writer) Tracer {
+	return &writer{w: w}
+}
+
+// writer is a Tracer which writes the events as text.
+type writer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *writer) Nodes(ids []compact.NodeID, begin, end int, ephem compact.NodeID) {
+	if end-begin > 1 {
+		w.printf("nodes %v: ephemeral %v from %v", ids, ephem, ids[begin:end])
+	} else {
+		w.printf("nodes %v", ids)
+	}
+}
+
+func (w *writer) Hash(left, right, hash []byte) {
+	w.printf("hash %x %x -> %x", left, right, hash)
+}
+
+func (w *writer) Verified(err error) {
+	if err != nil {
+		w.printf("verify: %v", err)
+	} else {
+		w.printf("verify: ok")
+	}
+}
+
+func (w *writer) printf(format string, args ...interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(w.w, format+"\n", args...)
+}
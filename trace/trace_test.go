@@ -0,0 +1,44 @@
+// Copyright 2022 Google LLC. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trace
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/transparency-dev/merkle/compact"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	ids := []compact.NodeID{compact.NewNodeID(0, 1), compact.NewNodeID(0, 6), compact.NewNodeID(1, 2)}
+	w.Nodes(ids, 1, 3, compact.NewNodeID(2, 1))
+	w.Nodes(ids[:1], 1, 1, compact.NodeID{})
+	w.Hash([]byte{1}, []byte{2}, []byte{3})
+	w.Verified(nil)
+	w.Verified(errors.New("mismatch"))
+
+	want := `nodes [0:1 0:6 1:2]: ephemeral 2:1 from [0:6 1:2]
+nodes [0:1]
+hash 01 02 -> 03
+verify: ok
+verify: mismatch
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}